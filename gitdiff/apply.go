@@ -1,6 +1,7 @@
 package gitdiff
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -16,7 +17,17 @@ import (
 //	    if errors.Is(err, &Conflict{}) {
 //		       // handle conflict
 //	    }
+//
+// Conflicts caused by a line that does not match the source also report the
+// location and content of the line. Line is the one-indexed line number in
+// the source, Expected is the line from the fragment, and Actual is the line
+// from the source. These fields are empty for other conflicts and are not
+// considered when matching errors with Is.
 type Conflict struct {
+	Line     int64
+	Expected string
+	Actual   string
+
 	msg string
 }
 
@@ -42,6 +53,9 @@ type ApplyError struct {
 	Fragment int
 	// FragmentLine is the one-indexed line number in the fragment
 	FragmentLine int
+	// File is the name of the file that failed to apply, if it is known. It
+	// is the new name of the file, or the old name if the file is deleted.
+	File string
 
 	err error
 }
@@ -58,6 +72,7 @@ func (e *ApplyError) Error() string {
 type lineNum int
 type fragNum int
 type fragLineNum int
+type fileName string
 
 // applyError creates a new *ApplyError wrapping err or augments the information
 // in err with args if it is already an *ApplyError. Returns nil if err is nil.
@@ -81,23 +96,155 @@ func applyError(err error, args ...interface{}) error {
 			e.Fragment = int(v) + 1
 		case fragLineNum:
 			e.FragmentLine = int(v) + 1
+		case fileName:
+			e.File = string(v)
 		}
 	}
 	return e
 }
 
+// An ApplyOption modifies the behavior of Apply and the appliers.
+type ApplyOption func(*applyOptions)
+
+// WithMaxOffset allows text fragments to apply up to n lines before or after
+// their stated position if the content at the stated position does not
+// match, similar to the offset behavior of GNU patch. Context and deleted
+// lines must still match exactly. By default, fragments must apply at their
+// stated position.
+func WithMaxOffset(n int64) ApplyOption {
+	return func(opts *applyOptions) {
+		opts.maxOffset = n
+	}
+}
+
+// WithIgnoreWhitespace ignores changes in whitespace when comparing context
+// and deleted lines with the source, like the --ignore-whitespace flag of
+// `git apply`. Runs of spaces and tabs are treated as a single space and
+// whitespace at the end of lines is ignored. Added lines are written exactly
+// as they appear in the patch, while context lines keep the whitespace of the
+// source. By default, lines must match exactly.
+func WithIgnoreWhitespace() ApplyOption {
+	return func(opts *applyOptions) {
+		opts.ignoreWhitespace = true
+	}
+}
+
+// WithLineEndingNormalization treats LF and CRLF line endings as equal when
+// comparing context and deleted lines with the source. Context lines keep the
+// line endings of the source and added lines are written with the line ending
+// of the first line of the source, so the result uses a consistent convention
+// even if the patch was created on a different platform. By default, line
+// endings must match exactly.
+func WithLineEndingNormalization() ApplyOption {
+	return func(opts *applyOptions) {
+		opts.normalizeLineEndings = true
+	}
+}
+
+// WithTrailingWhitespaceWarnings allows context and deleted lines to match
+// source lines that differ only in trailing spaces and tabs. Instead of a
+// conflict, each such line produces a Warning, which is available from the
+// Warnings method of TextApplier or in the result of File.ApplyReport.
+// Context lines keep the whitespace of the source. Other differences are
+// still conflicts. By default, lines must match exactly.
+func WithTrailingWhitespaceWarnings() ApplyOption {
+	return func(opts *applyOptions) {
+		opts.warnTrailingWhitespace = true
+	}
+}
+
+type applyOptions struct {
+	maxOffset              int64
+	ignoreWhitespace       bool
+	normalizeLineEndings   bool
+	warnTrailingWhitespace bool
+}
+
+// Warning describes a line of a fragment that applied even though it does
+// not exactly match the source, like a line that differs only in trailing
+// whitespace when using WithTrailingWhitespaceWarnings.
+type Warning struct {
+	// Line is the one-indexed line number in the source data
+	Line int64
+	// FragmentLine is the one-indexed line number in the fragment
+	FragmentLine int
+	// Expected is the line from the fragment
+	Expected string
+	// Actual is the line from the source
+	Actual string
+
+	msg string
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("line %d: %s", w.Line, w.msg)
+}
+
 var (
 	errApplyInProgress = errors.New("gitdiff: incompatible apply in progress")
 	errApplierClosed   = errors.New("gitdiff: applier is closed")
 )
 
 // Apply applies the changes in f to src, writing the result to dst. It can
-// apply both text and binary changes.
+// apply both text and binary changes. Apply reads src as needed and streams
+// the result to dst; it does not load the full source into memory.
 //
 // If an error occurs while applying, Apply returns an *ApplyError that
-// annotates the error with additional information. If the error is because of
-// a conflict with the source, the wrapped error will be a *Conflict.
-func Apply(dst io.Writer, src io.ReaderAt, f *File) error {
+// annotates the error with additional information, including the name of the
+// file. If the error is because of a conflict with the source, the wrapped
+// error will be a *Conflict.
+//
+// By default, Apply operates in "strict" mode. Use options to apply fragments
+// to modified sources.
+func Apply(dst io.Writer, src io.ReaderAt, f *File, options ...ApplyOption) error {
+	return applyError(applyFile(dst, src, f, nil, options...), fileName(patchFileName(f)))
+}
+
+// FragmentResult describes how a text fragment applied to a source.
+type FragmentResult struct {
+	// Index is the zero-indexed position of the fragment in the
+	// TextFragments field of the file
+	Index int
+	// Applied is true if the fragment applied successfully
+	Applied bool
+	// Offset is the number of lines between the stated position of the
+	// fragment and the position where it applied. It is positive if the
+	// fragment applied after its stated position and negative if it applied
+	// before.
+	Offset int64
+	// Fuzz is the number of context lines that were ignored to apply the
+	// fragment. Appliers in this package require all context lines to match,
+	// so Fuzz is currently always zero.
+	Fuzz int
+	// Warnings lists the lines of the fragment that applied without exactly
+	// matching the source
+	Warnings []Warning
+}
+
+// ApplyResult reports the outcome of applying each fragment in a file, like
+// the output of `git apply --verbose`.
+type ApplyResult struct {
+	// Fragments contains a result for each text fragment in the file, in the
+	// order the fragments were applied. Fragments that were not applied
+	// because an earlier fragment failed are also included. It is empty for
+	// binary files.
+	Fragments []FragmentResult
+}
+
+// ApplyReport applies the changes in f to src, writing the result to dst, and
+// reports where each text fragment applied. Options and errors are handled in
+// the same way as Apply. If an error occurs, the result still describes the
+// fragments that applied before the error.
+func (f *File) ApplyReport(dst io.Writer, src io.ReaderAt, options ...ApplyOption) (ApplyResult, error) {
+	var res ApplyResult
+	err := applyFile(dst, src, f, &res, options...)
+	return res, applyError(err, fileName(patchFileName(f)))
+}
+
+func applyFile(dst io.Writer, src io.ReaderAt, f *File, res *ApplyResult, options ...ApplyOption) error {
+	if f.IsCombined {
+		return applyError(errors.New("cannot apply combined diff"))
+	}
 	if f.IsBinary {
 		if len(f.TextFragments) > 0 {
 			return applyError(errors.New("binary file contains text fragments"))
@@ -111,6 +258,17 @@ func Apply(dst io.Writer, src io.ReaderAt, f *File) error {
 		}
 	}
 
+	// new files must have an empty source, even if no fragment depends on it
+	if f.IsNew {
+		ok, err := isLen(src, 0)
+		if err != nil {
+			return applyError(err)
+		}
+		if !ok {
+			return applyError(&Conflict{msg: "cannot create new file from non-empty src"})
+		}
+	}
+
 	switch {
 	case f.BinaryFragment != nil:
 		applier := NewBinaryApplier(dst, src)
@@ -120,22 +278,36 @@ func Apply(dst io.Writer, src io.ReaderAt, f *File) error {
 		return applier.Close()
 
 	case len(f.TextFragments) > 0:
-		frags := make([]*TextFragment, len(f.TextFragments))
-		copy(frags, f.TextFragments)
+		order := make([]int, len(f.TextFragments))
+		for i := range order {
+			order[i] = i
+		}
 
-		sort.Slice(frags, func(i, j int) bool {
-			return frags[i].OldPosition < frags[j].OldPosition
+		sort.SliceStable(order, func(i, j int) bool {
+			return f.TextFragments[order[i]].OldPosition < f.TextFragments[order[j]].OldPosition
 		})
 
+		if res != nil {
+			res.Fragments = make([]FragmentResult, len(order))
+			for i, idx := range order {
+				res.Fragments[i] = FragmentResult{Index: idx}
+			}
+		}
+
 		// TODO(bkeyes): consider merging overlapping fragments
 		// right now, the application fails if fragments overlap, but it should be
 		// possible to precompute the result of applying them in order
 
-		applier := NewTextApplier(dst, src)
-		for i, frag := range frags {
-			if err := applier.ApplyFragment(frag); err != nil {
+		applier := NewTextApplier(dst, src, options...)
+		for i, idx := range order {
+			if err := applier.ApplyFragment(f.TextFragments[idx]); err != nil {
 				return applyError(err, fragNum(i))
 			}
+			if res != nil {
+				res.Fragments[i].Applied = true
+				res.Fragments[i].Offset = applier.Offset()
+				res.Fragments[i].Warnings = applier.Warnings()
+			}
 		}
 		return applier.Close()
 
@@ -145,3 +317,135 @@ func Apply(dst io.Writer, src io.ReaderAt, f *File) error {
 		return err
 	}
 }
+
+// patchFileName returns the name of the file changed by f: the new name, or
+// the old name if the file is deleted.
+func patchFileName(f *File) string {
+	if f.NewName != "" {
+		return f.NewName
+	}
+	return f.OldName
+}
+
+// ApplyReverse reverts the changes in f, reading the modified content from src
+// and writing the original content to dst. It is the inverse of Apply and can
+// revert both text and binary changes. Reverting binary changes requires a
+// reverse binary fragment, which is only present if the patch was generated
+// with the --full-index and --binary flags.
+//
+// Options and errors are handled in the same way as Apply. If the error is
+// because of a conflict with the source, the wrapped error will be a
+// *Conflict.
+func ApplyReverse(dst io.Writer, src io.ReaderAt, f *File, options ...ApplyOption) error {
+	if f.IsCombined {
+		return applyError(errors.New("cannot reverse combined diff"), fileName(patchFileName(f)))
+	}
+	if f.BinaryFragment != nil && f.ReverseBinaryFragment == nil {
+		return applyError(errors.New("binary file does not contain a reverse binary fragment"), fileName(patchFileName(f)))
+	}
+
+	return Apply(dst, src, f.Reverse(), options...)
+}
+
+// FragmentConflict describes a fragment that does not apply to a source.
+type FragmentConflict struct {
+	// Fragment is the one-indexed number of the fragment in the file. For
+	// binary files, it is always 1.
+	Fragment int
+	// Line is the one-indexed line number in the source data where the
+	// fragment failed to apply, if it is known
+	Line int64
+	// FragmentLine is the one-indexed line number in the fragment that did
+	// not match the source, if it is known
+	FragmentLine int
+
+	// Err is the error returned when applying the fragment. If the error is
+	// because of a conflict with the source, it wraps a *Conflict.
+	Err error
+}
+
+// Check tests whether each fragment in f applies to src without writing any
+// output. Unlike Apply, which stops at the first error, Check applies every
+// fragment independently and returns a FragmentConflict for each fragment
+// that fails. It returns nil if all fragments apply. Options are handled in
+// the same way as Apply.
+//
+// Because fragments are checked independently, Check does not detect
+// fragments that overlap each other.
+func (f *File) Check(src io.ReaderAt, options ...ApplyOption) []FragmentConflict {
+	newConflict := func(i int, err error) FragmentConflict {
+		c := FragmentConflict{Fragment: i + 1, Err: err}
+		var aerr *ApplyError
+		if errors.As(err, &aerr) {
+			c.Line = aerr.Line
+			c.FragmentLine = aerr.FragmentLine
+		}
+		return c
+	}
+
+	if f.BinaryFragment != nil {
+		err := NewBinaryApplier(io.Discard, src).ApplyFragment(f.BinaryFragment)
+		if err != nil {
+			return []FragmentConflict{newConflict(0, err)}
+		}
+		return nil
+	}
+
+	var conflicts []FragmentConflict
+	for i, frag := range f.TextFragments {
+		if err := NewTextApplier(io.Discard, src, options...).ApplyFragment(frag); err != nil {
+			conflicts = append(conflicts, newConflict(i, err))
+		}
+	}
+	return conflicts
+}
+
+// ApplyBytes applies the changes in f to src and returns the result. It is a
+// convenience wrapper for Apply for content that is already in memory and
+// handles options and errors in the same way. src is not modified.
+func ApplyBytes(src []byte, f *File, options ...ApplyOption) ([]byte, error) {
+	var dst bytes.Buffer
+	dst.Grow(len(src))
+	if err := Apply(&dst, NewBytesLineReaderAt(src), f, options...); err != nil {
+		return nil, err
+	}
+	return dst.Bytes(), nil
+}
+
+// NewContent returns the content of the file after applying the patch to src,
+// the original content of the file. It is a convenience wrapper for Apply and
+// handles errors in the same way.
+func (f *File) NewContent(src io.ReaderAt, options ...ApplyOption) ([]byte, error) {
+	var dst bytes.Buffer
+	if err := Apply(&dst, src, f, options...); err != nil {
+		return nil, err
+	}
+	return dst.Bytes(), nil
+}
+
+// BinaryContent returns the content of a binary file after applying the
+// patch. If the binary fragment is a literal, BinaryContent returns the data
+// of the fragment and ignores src. If the fragment is a delta, BinaryContent
+// applies the delta to src, the original content of the file.
+//
+// BinaryContent returns an error if f is not a binary file or does not
+// include binary data. Errors from applying a delta are handled in the same
+// way as Apply.
+func (f *File) BinaryContent(src io.ReaderAt) ([]byte, error) {
+	if !f.IsBinary {
+		return nil, applyError(errors.New("file is not a binary file"))
+	}
+	if f.BinaryFragment == nil {
+		return nil, applyError(errors.New("binary file does not contain a binary fragment"))
+	}
+
+	if f.BinaryFragment.Method == BinaryPatchLiteral {
+		return f.BinaryFragment.Data, nil
+	}
+
+	var dst bytes.Buffer
+	if err := NewBinaryApplier(&dst, src).ApplyFragment(f.BinaryFragment); err != nil {
+		return nil, err
+	}
+	return dst.Bytes(), nil
+}
@@ -4,8 +4,21 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"sync"
 )
 
+// copyBufferPool holds buffers for copy operations in delta-encoded binary
+// fragments. Patches often contain many copies, so reusing buffers avoids a
+// lot of allocation.
+var copyBufferPool = sync.Pool{
+	New: func() any {
+		b := make([]byte, 0, binaryDeltaDefaultCopySize)
+		return &b
+	},
+}
+
+const binaryDeltaDefaultCopySize = 0x10000
+
 // BinaryApplier applies binary changes described in a fragment to source data.
 // The applier must be closed after use.
 type BinaryApplier struct {
@@ -78,6 +91,19 @@ func (a *BinaryApplier) Close() (err error) {
 	return err
 }
 
+// ApplyAt applies the changes in the fragment to src and writes the result to
+// dst, starting at offset 0. Unlike a BinaryApplier, dst only needs to support
+// random-access writes, which allows writing directly to files or other
+// pre-allocated storage. ApplyAt returns errors in the same way as
+// [BinaryApplier.ApplyFragment].
+func (f *BinaryFragment) ApplyAt(dst io.WriterAt, src io.ReaderAt) error {
+	a := NewBinaryApplier(io.NewOffsetWriter(dst, 0), src)
+	if err := a.ApplyFragment(f); err != nil {
+		return err
+	}
+	return a.Close()
+}
+
 func applyBinaryDeltaFragment(dst io.Writer, src io.ReaderAt, frag []byte) error {
 	srcSize, delta := readBinaryDeltaSize(frag)
 	if err := checkBinarySrcSize(src, srcSize); err != nil {
@@ -158,8 +184,30 @@ func applyBinaryDeltaAdd(w io.Writer, op byte, delta []byte) (n int64, rest []by
 // present, etc. If no offset or size bytes are present, offset is 0 and size
 // is 0x10000. See also pack-format.txt in the Git source.
 func applyBinaryDeltaCopy(w io.Writer, op byte, delta []byte, src io.ReaderAt) (n int64, rest []byte, err error) {
-	const defaultSize = 0x10000
+	offset, size, delta, err := parseBinaryDeltaCopy(op, delta)
+	if err != nil {
+		return 0, delta, err
+	}
+
+	bp := copyBufferPool.Get().(*[]byte)
+	defer copyBufferPool.Put(bp)
+	if int64(cap(*bp)) < size {
+		*bp = make([]byte, size)
+	}
 
+	b := (*bp)[:size]
+	if _, err := src.ReadAt(b, offset); err != nil {
+		return 0, delta, err
+	}
+
+	_, err = w.Write(b)
+	return size, delta, err
+}
+
+// parseBinaryDeltaCopy parses the offset and size of a copy opcode in a
+// delta-encoded binary fragment, returning the unused part of the fragment.
+// See applyBinaryDeltaCopy for the format of the operation.
+func parseBinaryDeltaCopy(op byte, delta []byte) (offset, size int64, rest []byte, err error) {
 	unpack := func(start, bits uint) (v int64) {
 		for i := uint(0); i < bits; i++ {
 			mask := byte(1 << (i + start))
@@ -175,23 +223,77 @@ func applyBinaryDeltaCopy(w io.Writer, op byte, delta []byte, src io.ReaderAt) (
 		return
 	}
 
-	offset := unpack(0, 4)
-	size := unpack(4, 3)
+	offset = unpack(0, 4)
+	size = unpack(4, 3)
 	if err != nil {
-		return 0, delta, err
+		return 0, 0, delta, err
 	}
 	if size == 0 {
-		size = defaultSize
+		size = binaryDeltaDefaultCopySize
 	}
+	return offset, size, delta, nil
+}
 
-	// TODO(bkeyes): consider pooling these buffers
-	b := make([]byte, size)
-	if _, err := src.ReadAt(b, offset); err != nil {
-		return 0, delta, err
+// DeltaOp is an operation in a delta-encoded binary fragment. A copy
+// operation copies Size bytes starting at Offset in the source to the
+// destination. An add operation writes Data to the destination.
+type DeltaOp struct {
+	Copy   bool
+	Offset int64
+	Size   int64
+	Data   []byte
+}
+
+// DeltaOps decodes the operations in a fragment that uses the
+// BinaryPatchDelta method without applying them. It also returns the sizes of
+// the source and destination data recorded in the fragment. The Data of add
+// operations shares memory with the Data of the fragment.
+//
+// DeltaOps returns an error if the fragment does not use the delta method or
+// if the delta is corrupt, including when an operation copies data outside of
+// the source or the operations do not produce data of the destination size.
+func (f *BinaryFragment) DeltaOps() (ops []DeltaOp, srcSize, dstSize int64, err error) {
+	if f.Method != BinaryPatchDelta {
+		return nil, 0, 0, errors.New("binary fragment does not use the delta method")
 	}
 
-	_, err = w.Write(b)
-	return size, delta, err
+	srcSize, delta := readBinaryDeltaSize(f.Data)
+	dstSize, delta = readBinaryDeltaSize(delta)
+
+	var n int64
+	for len(delta) > 0 {
+		op := delta[0]
+		if op == 0 {
+			return nil, 0, 0, errors.New("invalid delta opcode 0")
+		}
+
+		var dop DeltaOp
+		switch op & 0x80 {
+		case 0x80:
+			dop.Copy = true
+			dop.Offset, dop.Size, delta, err = parseBinaryDeltaCopy(op, delta[1:])
+			if err != nil {
+				return nil, 0, 0, err
+			}
+			if dop.Offset+dop.Size > srcSize {
+				return nil, 0, 0, errors.New("corrupt binary delta: copy outside of source")
+			}
+		case 0x00:
+			dop.Size = int64(op)
+			if int64(len(delta)-1) < dop.Size {
+				return nil, 0, 0, errors.New("corrupt binary delta: incomplete add")
+			}
+			dop.Data = delta[1 : 1+dop.Size]
+			delta = delta[1+dop.Size:]
+		}
+		ops = append(ops, dop)
+		n += dop.Size
+	}
+
+	if n != dstSize {
+		return nil, 0, 0, errors.New("corrupt binary delta: insufficient or extra data")
+	}
+	return ops, srcSize, dstSize, nil
 }
 
 func checkBinarySrcSize(r io.ReaderAt, size int64) error {
@@ -200,7 +302,7 @@ func checkBinarySrcSize(r io.ReaderAt, size int64) error {
 		return err
 	}
 	if !ok {
-		return &Conflict{"fragment src size does not match actual src size"}
+		return &Conflict{msg: "fragment src size does not match actual src size"}
 	}
 	return nil
 }
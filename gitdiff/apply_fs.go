@@ -0,0 +1,248 @@
+package gitdiff
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	gitModeTypeMask = 0o170000
+	gitModeRegular  = 0o100000
+	gitModeSymlink  = 0o120000
+	gitModePermMask = 0o777
+
+	defaultFilePerm = 0o644
+)
+
+// WriteFS is a file system that supports the operations needed to apply
+// patches. Names follow the same rules as in fs.FS: they are slash-separated
+// and relative to the root of the file system.
+type WriteFS interface {
+	fs.FS
+
+	// WriteFile writes data to the named file, creating the file and any
+	// missing parent directories if necessary. If the file already exists,
+	// WriteFile replaces its content and sets its permissions to perm.
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+
+	// Remove removes the named file.
+	Remove(name string) error
+}
+
+var (
+	errBeyondSymlink = errors.New("path is beyond a symbolic link")
+	errSymlink       = errors.New("file is a symbolic link")
+)
+
+// DirFS returns a WriteFS for the files in the directory dir. Removing a file
+// also removes any parent directories that become empty, like `git apply`.
+//
+// Like `git apply`, the file system does not follow symbolic links in the
+// directory: operations on names with a symbolic link as a parent directory
+// fail, and Stat reports symbolic links instead of their targets.
+func DirFS(dir string) WriteFS {
+	return dirFS{FS: os.DirFS(dir), dir: dir}
+}
+
+type dirFS struct {
+	fs.FS
+	dir string
+}
+
+// path returns the path in the directory for name. It returns an error if a
+// parent directory of name is a symbolic link or, if noLink is true, if name
+// is a symbolic link.
+func (d dirFS) path(op, name string, noLink bool) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		return d.dir, nil
+	}
+
+	parts := strings.Split(name, "/")
+	path := d.dir
+	for i, part := range parts {
+		path = filepath.Join(path, part)
+
+		info, err := os.Lstat(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&fs.ModeSymlink == 0 {
+			continue
+		}
+
+		switch {
+		case i < len(parts)-1:
+			return "", &fs.PathError{Op: op, Path: name, Err: errBeyondSymlink}
+		case noLink:
+			return "", &fs.PathError{Op: op, Path: name, Err: errSymlink}
+		}
+	}
+	return filepath.Join(d.dir, filepath.FromSlash(name)), nil
+}
+
+func (d dirFS) Open(name string) (fs.File, error) {
+	if _, err := d.path("open", name, true); err != nil {
+		return nil, err
+	}
+	return d.FS.Open(name)
+}
+
+func (d dirFS) Stat(name string) (fs.FileInfo, error) {
+	path, err := d.path("stat", name, false)
+	if err != nil {
+		return nil, err
+	}
+	return os.Lstat(path)
+}
+
+func (d dirFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	path, err := d.path("write", name, true)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o777); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, perm); err != nil {
+		return err
+	}
+	// WriteFile does not change the permissions of existing files
+	return os.Chmod(path, perm)
+}
+
+func (d dirFS) Remove(name string) error {
+	path, err := d.path("remove", name, false)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+
+	root := filepath.Clean(d.dir)
+	for dir := filepath.Dir(path); dir != root && dir != "."; dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+	return nil
+}
+
+// ApplyToDir applies the changes in files to the directory root. It is a
+// shortcut for calling ApplyToFS with DirFS(root).
+func ApplyToDir(root string, files []*File, options ...ApplyOption) error {
+	return ApplyToFS(DirFS(root), files, options...)
+}
+
+// ApplyToFS applies the changes in files to the file system fsys. Files are
+// processed in order: new files are created with their new mode, deleted
+// files are removed, renamed and copied files are written with their new
+// names, and the fragments of modified files are applied to the existing
+// content. Options are passed to Apply for each file.
+//
+// ApplyToFS stops at the first file that fails and returns an *fs.PathError
+// wrapping the error and identifying the file. Changes to files before the
+// failing file are not reverted.
+func ApplyToFS(fsys WriteFS, files []*File, options ...ApplyOption) error {
+	for _, f := range files {
+		if err := applyFileToFS(fsys, f, options...); err != nil {
+			return &fs.PathError{Op: "apply", Path: patchFileName(f), Err: err}
+		}
+	}
+	return nil
+}
+
+func applyFileToFS(fsys WriteFS, f *File, options ...ApplyOption) error {
+	var src []byte
+	var srcMode fs.FileMode
+
+	if !f.IsNew {
+		if f.OldName == "" {
+			return errors.New("patch does not include the name of the old file")
+		}
+
+		info, err := fs.Stat(fsys, f.OldName)
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return fmt.Errorf("%s is not a regular file", f.OldName)
+		}
+		srcMode = info.Mode().Perm()
+
+		if src, err = fs.ReadFile(fsys, f.OldName); err != nil {
+			return err
+		}
+	}
+
+	if f.IsDelete {
+		var dst bytes.Buffer
+		if err := Apply(&dst, bytes.NewReader(src), f, options...); err != nil {
+			return err
+		}
+		if dst.Len() > 0 {
+			return errors.New("deleted file still has contents after applying patch")
+		}
+		return fsys.Remove(f.OldName)
+	}
+
+	if f.NewName == "" {
+		return errors.New("patch does not include the name of the new file")
+	}
+	if f.IsNew || f.NewName != f.OldName {
+		if _, err := fs.Stat(fsys, f.NewName); err == nil {
+			return fmt.Errorf("%s already exists", f.NewName)
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	perm, err := applyFilePerm(f, srcMode)
+	if err != nil {
+		return err
+	}
+
+	var dst bytes.Buffer
+	if err := Apply(&dst, bytes.NewReader(src), f, options...); err != nil {
+		return err
+	}
+	if err := fsys.WriteFile(f.NewName, dst.Bytes(), perm); err != nil {
+		return err
+	}
+
+	if f.IsRename && f.OldName != f.NewName {
+		return fsys.Remove(f.OldName)
+	}
+	return nil
+}
+
+// applyFilePerm returns the permissions for the new version of f. Only
+// regular files are supported.
+func applyFilePerm(f *File, srcMode fs.FileMode) (fs.FileMode, error) {
+	mode := f.NewMode
+	if mode == 0 {
+		mode = f.OldMode
+	}
+	if mode == 0 {
+		if srcMode != 0 {
+			return srcMode, nil
+		}
+		return defaultFilePerm, nil
+	}
+
+	if mode&gitModeTypeMask != gitModeRegular {
+		return 0, fmt.Errorf("unsupported file mode: %o", mode)
+	}
+	return mode & gitModePermMask, nil
+}
@@ -0,0 +1,355 @@
+package gitdiff
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+const applyFSPatch = `diff --git a/modify.txt b/modify.txt
+index 0b1f5f1..e58a9e1 100644
+--- a/modify.txt
++++ b/modify.txt
+@@ -1,2 +1,2 @@
+ line 1
+-line 2
++line two
+diff --git a/new.txt b/new.txt
+new file mode 100755
+index 0000000..2c2e5d1
+--- /dev/null
++++ b/new.txt
+@@ -0,0 +1 @@
++new file
+diff --git a/delete.txt b/delete.txt
+deleted file mode 100644
+index 9f4d96d..0000000
+--- a/delete.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-deleted file
+diff --git a/dir/old.txt b/dir/new.txt
+similarity index 80%
+rename from dir/old.txt
+rename to dir/new.txt
+index 3d7f2b1..5a2f2c9 100644
+--- a/dir/old.txt
++++ b/dir/new.txt
+@@ -1,2 +1,2 @@
+-renamed file
++renamed and modified file
+ line 2
+diff --git a/copy.txt b/copy2.txt
+similarity index 100%
+copy from copy.txt
+copy to copy2.txt
+diff --git a/mode.txt b/mode.txt
+old mode 100644
+new mode 100755
+`
+
+type memFS struct {
+	fstest.MapFS
+}
+
+func (m memFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	m.MapFS[name] = &fstest.MapFile{Data: data, Mode: perm}
+	return nil
+}
+
+func (m memFS) Remove(name string) error {
+	if _, ok := m.MapFS[name]; !ok {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	delete(m.MapFS, name)
+	return nil
+}
+
+func TestApplyToFS(t *testing.T) {
+	files, _, err := Parse(strings.NewReader(applyFSPatch))
+	if err != nil {
+		t.Fatalf("unexpected error parsing patch: %v", err)
+	}
+
+	fsys := memFS{fstest.MapFS{
+		"modify.txt":  {Data: []byte("line 1\nline 2\n"), Mode: 0o644},
+		"delete.txt":  {Data: []byte("deleted file\n"), Mode: 0o644},
+		"dir/old.txt": {Data: []byte("renamed file\nline 2\n"), Mode: 0o644},
+		"copy.txt":    {Data: []byte("copied file\n"), Mode: 0o644},
+		"mode.txt":    {Data: []byte("mode file\n"), Mode: 0o644},
+	}}
+
+	if err := ApplyToFS(fsys, files); err != nil {
+		t.Fatalf("unexpected error applying patch: %v", err)
+	}
+
+	expected := fstest.MapFS{
+		"modify.txt":  {Data: []byte("line 1\nline two\n"), Mode: 0o644},
+		"new.txt":     {Data: []byte("new file\n"), Mode: 0o755},
+		"dir/new.txt": {Data: []byte("renamed and modified file\nline 2\n"), Mode: 0o644},
+		"copy.txt":    {Data: []byte("copied file\n"), Mode: 0o644},
+		"copy2.txt":   {Data: []byte("copied file\n"), Mode: 0o644},
+		"mode.txt":    {Data: []byte("mode file\n"), Mode: 0o755},
+	}
+	if !reflect.DeepEqual(expected, fsys.MapFS) {
+		for name, f := range fsys.MapFS {
+			t.Logf("%s (%o): %q", name, f.Mode, f.Data)
+		}
+		t.Fatalf("incorrect file system content after applying patch")
+	}
+}
+
+func TestApplyToFSErrors(t *testing.T) {
+	tests := map[string]struct {
+		Patch string
+		Files fstest.MapFS
+		Path  string
+		Err   interface{}
+	}{
+		"conflict": {
+			Patch: applyFSPatch,
+			Files: fstest.MapFS{
+				"modify.txt": {Data: []byte("line 1\nline 3\n")},
+			},
+			Path: "modify.txt",
+			Err:  &Conflict{},
+		},
+		"missingFile": {
+			Patch: applyFSPatch,
+			Files: fstest.MapFS{},
+			Path:  "modify.txt",
+			Err:   fs.ErrNotExist,
+		},
+		"newFileExists": {
+			Patch: `diff --git a/new.txt b/new.txt
+new file mode 100644
+--- /dev/null
++++ b/new.txt
+@@ -0,0 +1 @@
++new file
+`,
+			Files: fstest.MapFS{
+				"new.txt": {Data: []byte("existing file\n")},
+			},
+			Path: "new.txt",
+			Err:  "already exists",
+		},
+		"deletedFileHasContent": {
+			Patch: `diff --git a/delete.txt b/delete.txt
+deleted file mode 100644
+--- a/delete.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-deleted file
+`,
+			Files: fstest.MapFS{
+				"delete.txt": {Data: []byte("deleted file\nextra line\n")},
+			},
+			Path: "delete.txt",
+			Err:  &Conflict{},
+		},
+		"unsupportedMode": {
+			Patch: `diff --git a/link b/link
+new file mode 120000
+--- /dev/null
++++ b/link
+@@ -0,0 +1 @@
++target
+\ No newline at end of file
+`,
+			Files: fstest.MapFS{},
+			Path:  "link",
+			Err:   "unsupported file mode",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			files, _, err := Parse(strings.NewReader(test.Patch))
+			if err != nil {
+				t.Fatalf("unexpected error parsing patch: %v", err)
+			}
+
+			err = ApplyToFS(memFS{test.Files}, files)
+			assertError(t, test.Err, err, "applying patch")
+
+			var perr *fs.PathError
+			if !errors.As(err, &perr) {
+				t.Fatalf("expected *fs.PathError, but got %T", err)
+			}
+			if perr.Path != test.Path {
+				t.Errorf("incorrect path in error: expected %q, actual %q", test.Path, perr.Path)
+			}
+		})
+	}
+}
+
+func TestApplyToDir(t *testing.T) {
+	root := t.TempDir()
+
+	write := func(name, data string) {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+	write("modify.txt", "line 1\nline 2\n")
+	write("delete.txt", "deleted file\n")
+	write("dir/old.txt", "renamed file\nline 2\n")
+	write("copy.txt", "copied file\n")
+	write("mode.txt", "mode file\n")
+
+	files, _, err := Parse(strings.NewReader(applyFSPatch))
+	if err != nil {
+		t.Fatalf("unexpected error parsing patch: %v", err)
+	}
+	if err := ApplyToDir(root, files); err != nil {
+		t.Fatalf("unexpected error applying patch: %v", err)
+	}
+
+	expected := map[string]string{
+		"modify.txt":  "line 1\nline two\n",
+		"new.txt":     "new file\n",
+		"dir/new.txt": "renamed and modified file\nline 2\n",
+		"copy.txt":    "copied file\n",
+		"copy2.txt":   "copied file\n",
+		"mode.txt":    "mode file\n",
+	}
+	for name, content := range expected {
+		b, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(name)))
+		if err != nil {
+			t.Errorf("failed to read %s: %v", name, err)
+			continue
+		}
+		if string(b) != content {
+			t.Errorf("incorrect content for %s: expected %q, actual %q", name, content, string(b))
+		}
+	}
+
+	for _, name := range []string{"delete.txt", "dir/old.txt"} {
+		if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(name))); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("expected %s to be removed, but got: %v", name, err)
+		}
+	}
+
+	info, err := os.Stat(filepath.Join(root, "mode.txt"))
+	if err != nil {
+		t.Fatalf("failed to stat mode.txt: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o755 {
+		t.Errorf("incorrect mode for mode.txt: expected %o, actual %o", 0o755, perm)
+	}
+}
+
+func TestApplyToDirInvalidPath(t *testing.T) {
+	patch := `diff --git a/../escape.txt b/../escape.txt
+new file mode 100644
+--- /dev/null
++++ b/../escape.txt
+@@ -0,0 +1 @@
++escaped
+`
+	files, _, err := Parse(strings.NewReader(patch))
+	if err != nil {
+		t.Fatalf("unexpected error parsing patch: %v", err)
+	}
+
+	err = ApplyToDir(filepath.Join(t.TempDir(), "root"), files)
+	assertError(t, fs.ErrInvalid, err, "applying patch")
+}
+
+func TestApplyToDirSymlink(t *testing.T) {
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "file.txt"), []byte("line 1\n"), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	root := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(root, "link")); err != nil {
+		t.Skipf("symbolic links are not supported: %v", err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "file.txt"), filepath.Join(root, "file.txt")); err != nil {
+		t.Fatalf("failed to create symbolic link: %v", err)
+	}
+
+	tests := map[string]struct {
+		Patch string
+		Err   interface{}
+	}{
+		"modifyBeyondLink": {
+			Patch: `diff --git a/link/file.txt b/link/file.txt
+--- a/link/file.txt
++++ b/link/file.txt
+@@ -1 +1 @@
+-line 1
++escaped
+`,
+			Err: errBeyondSymlink,
+		},
+		"createBeyondLink": {
+			Patch: `diff --git a/link/new.txt b/link/new.txt
+new file mode 100644
+--- /dev/null
++++ b/link/new.txt
+@@ -0,0 +1 @@
++escaped
+`,
+			Err: errBeyondSymlink,
+		},
+		"deleteBeyondLink": {
+			Patch: `diff --git a/link/file.txt b/link/file.txt
+deleted file mode 100644
+--- a/link/file.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-line 1
+`,
+			Err: errBeyondSymlink,
+		},
+		"modifyLink": {
+			Patch: `diff --git a/file.txt b/file.txt
+--- a/file.txt
++++ b/file.txt
+@@ -1 +1 @@
+-line 1
++escaped
+`,
+			Err: "not a regular file",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			files, _, err := Parse(strings.NewReader(test.Patch))
+			if err != nil {
+				t.Fatalf("unexpected error parsing patch: %v", err)
+			}
+
+			err = ApplyToDir(root, files)
+			assertError(t, test.Err, err, "applying patch")
+
+			entries, err := os.ReadDir(outside)
+			if err != nil {
+				t.Fatalf("failed to read directory: %v", err)
+			}
+			if len(entries) != 1 {
+				t.Errorf("incorrect number of files outside the root: expected 1, actual %d", len(entries))
+			}
+			b, err := os.ReadFile(filepath.Join(outside, "file.txt"))
+			if err != nil {
+				t.Fatalf("failed to read file: %v", err)
+			}
+			if string(b) != "line 1\n" {
+				t.Errorf("file outside the root was modified: %q", b)
+			}
+		})
+	}
+}
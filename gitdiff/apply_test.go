@@ -3,9 +3,14 @@ package gitdiff
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
+	"math"
 	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
 	"testing"
 )
 
@@ -122,6 +127,275 @@ func TestApplyBinaryFragment(t *testing.T) {
 	}
 }
 
+func TestBinaryFragmentDeltaOps(t *testing.T) {
+	tests := map[string]struct {
+		Files    applyFiles
+		Fragment *BinaryFragment
+		Err      string
+	}{
+		"deltaModify":      {Files: getApplyFiles("bin_fragment_delta_modify")},
+		"deltaModifyLarge": {Files: getApplyFiles("bin_fragment_delta_modify_large")},
+		"errorIncompleteAdd": {
+			Files: applyFiles{Patch: "bin_fragment_delta_error_incomplete_add.patch"},
+			Err:   "incomplete add",
+		},
+		"errorIncompleteCopy": {
+			Files: applyFiles{Patch: "bin_fragment_delta_error_incomplete_copy.patch"},
+			Err:   "incomplete copy",
+		},
+		"errorDstSize": {
+			Files: applyFiles{Patch: "bin_fragment_delta_error_dst_size.patch"},
+			Err:   "insufficient or extra data",
+		},
+		"errorCopyOutsideSource": {
+			Fragment: &BinaryFragment{
+				Method: BinaryPatchDelta,
+				Data:   []byte{0x04, 0x04, 0x91, 0x02, 0x04},
+			},
+			Err: "copy outside of source",
+		},
+		"errorLiteral": {
+			Fragment: &BinaryFragment{Method: BinaryPatchLiteral},
+			Err:      "does not use the delta method",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			src, patch, out := test.Files.Load(t)
+
+			frag := test.Fragment
+			if frag == nil {
+				files, _, err := Parse(bytes.NewReader(patch))
+				if err != nil {
+					t.Fatalf("failed to parse patch: %v", err)
+				}
+				frag = files[0].BinaryFragment
+			}
+
+			ops, srcSize, dstSize, err := frag.DeltaOps()
+			if test.Err != "" {
+				assertError(t, test.Err, err, "decoding delta")
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error decoding delta: %v", err)
+			}
+
+			if srcSize != int64(len(src)) {
+				t.Errorf("incorrect source size: expected %d, actual %d", len(src), srcSize)
+			}
+			if dstSize != int64(len(out)) {
+				t.Errorf("incorrect destination size: expected %d, actual %d", len(out), dstSize)
+			}
+
+			// replaying the operations must produce the same result as applying
+			var dst []byte
+			for _, op := range ops {
+				if op.Copy {
+					dst = append(dst, src[op.Offset:op.Offset+op.Size]...)
+				} else {
+					dst = append(dst, op.Data...)
+				}
+			}
+			if !bytes.Equal(out, dst) {
+				t.Errorf("replayed operations produced incorrect output")
+			}
+		})
+	}
+}
+
+func TestBinaryFragmentApplyAt(t *testing.T) {
+	tests := map[string]applyTest{
+		"literalCreate":    {Files: getApplyFiles("bin_fragment_literal_create")},
+		"literalModify":    {Files: getApplyFiles("bin_fragment_literal_modify")},
+		"deltaModify":      {Files: getApplyFiles("bin_fragment_delta_modify")},
+		"deltaModifyLarge": {Files: getApplyFiles("bin_fragment_delta_modify_large")},
+		"errorSrcSize": {
+			Files: applyFiles{
+				Src:   "bin_fragment_delta_error.src",
+				Patch: "bin_fragment_delta_error_src_size.patch",
+			},
+			Err: &Conflict{},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			test.run(t, func(dst io.Writer, src io.ReaderAt, file *File) error {
+				var w writerAtBuffer
+				if err := file.BinaryFragment.ApplyAt(&w, src); err != nil {
+					return err
+				}
+				_, err := dst.Write(w.b)
+				return err
+			})
+		})
+	}
+}
+
+// writerAtBuffer is an in-memory io.WriterAt that grows to fit writes.
+type writerAtBuffer struct {
+	b []byte
+}
+
+func (w *writerAtBuffer) WriteAt(p []byte, off int64) (int, error) {
+	if end := int(off) + len(p); end > len(w.b) {
+		w.b = append(w.b, make([]byte, end-len(w.b))...)
+	}
+	return copy(w.b[off:], p), nil
+}
+
+func TestFileBinaryContent(t *testing.T) {
+	tests := map[string]applyTest{
+		"literalCreate": {Files: getApplyFiles("bin_fragment_literal_create")},
+		"literalModify": {Files: getApplyFiles("bin_fragment_literal_modify")},
+		"deltaModify":   {Files: getApplyFiles("bin_fragment_delta_modify")},
+		"errorSrcSize": {
+			Files: applyFiles{
+				Src:   "bin_fragment_delta_error.src",
+				Patch: "bin_fragment_delta_error_src_size.patch",
+			},
+			Err: &Conflict{},
+		},
+		"errorTextFile": {
+			Files: applyFiles{
+				Src:   "file_text.src",
+				Patch: "file_text_modify.patch",
+			},
+			Err: "not a binary file",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			test.run(t, func(dst io.Writer, src io.ReaderAt, file *File) error {
+				data, err := file.BinaryContent(src)
+				if err != nil {
+					return err
+				}
+				_, err = dst.Write(data)
+				return err
+			})
+		})
+	}
+}
+
+func TestFileNewContent(t *testing.T) {
+	tests := map[string]applyTest{
+		"textModify": {
+			Files: applyFiles{
+				Src:   "file_text.src",
+				Patch: "file_text_modify.patch",
+				Out:   "file_text_modify.out",
+			},
+		},
+		"literalCreate": {Files: getApplyFiles("bin_fragment_literal_create")},
+		"errorConflict": {
+			Files: applyFiles{
+				Src:   "text_fragment_error.src",
+				Patch: "text_fragment_error_context_conflict.patch",
+			},
+			Err: &Conflict{},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			test.run(t, func(dst io.Writer, src io.ReaderAt, file *File) error {
+				data, err := file.NewContent(src)
+				if err != nil {
+					return err
+				}
+				_, err = dst.Write(data)
+				return err
+			})
+		})
+	}
+}
+
+func TestApplyBytes(t *testing.T) {
+	tests := map[string]applyTest{
+		"textModify": {
+			Files: applyFiles{
+				Src:   "file_text.src",
+				Patch: "file_text_modify.patch",
+				Out:   "file_text_modify.out",
+			},
+		},
+		"textCreateNoEOL": {Files: getApplyFiles("file_text_new_noeol")},
+		"binaryModify":    {Files: getApplyFiles("file_bin_modify")},
+		"errorConflict": {
+			Files: applyFiles{
+				Src:   "text_fragment_error.src",
+				Patch: "text_fragment_error_context_conflict.patch",
+			},
+			Err: &Conflict{},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			test.run(t, func(dst io.Writer, src io.ReaderAt, file *File) error {
+				b, err := io.ReadAll(io.NewSectionReader(src, 0, math.MaxInt64))
+				if err != nil {
+					t.Fatalf("unexpected error reading source: %v", err)
+				}
+
+				data, err := ApplyBytes(b, file)
+				if err != nil {
+					var aerr *ApplyError
+					if !errors.As(err, &aerr) {
+						t.Errorf("expected *ApplyError, but got %T", err)
+					}
+					return err
+				}
+				_, err = dst.Write(data)
+				return err
+			})
+		})
+	}
+}
+
+func BenchmarkApplyBinaryDeltaCopy(b *testing.B) {
+	const (
+		chunkSize = 128
+		chunks    = 4096
+	)
+
+	src := make([]byte, chunkSize*chunks)
+	for i := range src {
+		src[i] = byte(i * 7)
+	}
+
+	appendSize := func(d []byte, size int) []byte {
+		for size > 0x7F {
+			d = append(d, byte(size&0x7F)|0x80)
+			size >>= 7
+		}
+		return append(d, byte(size))
+	}
+
+	// copy the source chunks in reverse order
+	delta := appendSize(nil, len(src))
+	delta = appendSize(delta, len(src))
+	for i := chunks - 1; i >= 0; i-- {
+		offset := i * chunkSize
+		delta = append(delta, 0x80|0x01|0x02|0x04|0x10, byte(offset), byte(offset>>8), byte(offset>>16), chunkSize)
+	}
+	frag := &BinaryFragment{Method: BinaryPatchDelta, Size: int64(len(src)), Data: delta}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		applier := NewBinaryApplier(io.Discard, bytes.NewReader(src))
+		if err := applier.ApplyFragment(frag); err != nil {
+			b.Fatalf("unexpected error applying fragment: %v", err)
+		}
+	}
+}
+
 func TestApplyFile(t *testing.T) {
 	tests := map[string]applyTest{
 		"textModify": {
@@ -145,6 +419,20 @@ func TestApplyFile(t *testing.T) {
 			},
 			Err: &Conflict{},
 		},
+		"textErrorNewFile": {
+			Files: applyFiles{
+				Src:   "text_fragment_error.src",
+				Patch: "text_fragment_error_new_file.patch",
+			},
+			Err: &Conflict{},
+		},
+		"textErrorNewEmptyFile": {
+			Files: applyFiles{
+				Src:   "file_text.src",
+				Patch: "file_text_error_new_empty.patch",
+			},
+			Err: "cannot create new file from non-empty src",
+		},
 		"binaryModify": {
 			Files: getApplyFiles("file_bin_modify"),
 		},
@@ -162,6 +450,403 @@ func TestApplyFile(t *testing.T) {
 	}
 }
 
+func TestApplyEmptyFiles(t *testing.T) {
+	tests := map[string]applyTest{
+		"addToEmptyNoEOL":     {Files: getApplyFiles("file_text_add_to_empty_noeol")},
+		"createNoEOL":         {Files: getApplyFiles("file_text_new_noeol")},
+		"deleteSoleLine":      {Files: getApplyFiles("file_text_delete_sole_line")},
+		"deleteSoleLineNoEOL": {Files: getApplyFiles("file_text_delete_sole_line_noeol")},
+		"deleteFileNoEOL":     {Files: getApplyFiles("file_text_delete_file_noeol")},
+		"errorAddToNonEmpty":  {Files: applyFiles{Src: "file_text_delete_sole_line.src", Patch: "file_text_add_to_empty_noeol.patch"}, Err: &Conflict{}},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			test.run(t, func(dst io.Writer, src io.ReaderAt, file *File) error {
+				for i, frag := range file.TextFragments {
+					if err := frag.Validate(); err != nil {
+						t.Fatalf("fragment %d is not valid: %v", i+1, err)
+					}
+				}
+
+				reparsed := assertParseSingleFile(t, []byte(file.String()), "formatted patch")
+				assertFilesEqual(t, file, reparsed)
+
+				return Apply(dst, src, file)
+			})
+		})
+	}
+}
+
+func TestApplyTrailingLines(t *testing.T) {
+	// use more lines than the buffer used to copy lines after the last fragment
+	const lines = 3*lineBufferSize + 5
+
+	var src strings.Builder
+	for i := 1; i <= lines; i++ {
+		fmt.Fprintf(&src, "line %d\n", i)
+	}
+
+	tests := map[string]struct {
+		Patch    string
+		Expected func(lines []string) []string
+	}{
+		"trailingLines": {
+			Patch: `@@ -2,3 +2,3 @@
+ line 2
+-line 3
++line 3 changed
+ line 4
+`,
+			Expected: func(lines []string) []string {
+				lines[2] = "line 3 changed\n"
+				return lines
+			},
+		},
+		"deleteToEnd": {
+			Patch: fmt.Sprintf(`@@ -%d,3 +%d,1 @@
+ line %d
+-line %d
+-line %d
+`, lines-2, lines-2, lines-2, lines-1, lines),
+			Expected: func(lines []string) []string {
+				return lines[:len(lines)-2]
+			},
+		},
+		"noNewlineAtEnd": {
+			Patch: fmt.Sprintf(`@@ -%d,2 +%d,2 @@
+ line %d
+-line %d
++line %d
+\ No newline at end of file
+`, lines-1, lines-1, lines-1, lines, lines),
+			Expected: func(lines []string) []string {
+				lines[len(lines)-1] = strings.TrimSuffix(lines[len(lines)-1], "\n")
+				return lines
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			patch := "diff --git a/file.txt b/file.txt\n--- a/file.txt\n+++ b/file.txt\n" + test.Patch
+			file := assertParseSingleFile(t, []byte(patch), "patch")
+
+			var dst bytes.Buffer
+			if err := Apply(&dst, strings.NewReader(src.String()), file); err != nil {
+				t.Fatalf("unexpected error applying patch: %v", err)
+			}
+
+			srcLines := strings.SplitAfter(src.String(), "\n")
+			expected := strings.Join(test.Expected(srcLines[:len(srcLines)-1]), "")
+			if dst.String() != expected {
+				t.Errorf("incorrect result after apply\nexpected:\n%q\nactual:\n%q", expected, dst.String())
+			}
+		})
+	}
+}
+
+func TestApplyErrorFile(t *testing.T) {
+	frag := &TextFragment{
+		OldPosition:  1,
+		OldLines:     1,
+		NewPosition:  1,
+		NewLines:     0,
+		LinesDeleted: 1,
+		Lines: []Line{
+			{Op: OpDelete, Line: "line 1\n"},
+		},
+	}
+
+	tests := map[string]struct {
+		File *File
+		Name string
+	}{
+		"modify": {
+			File: &File{OldName: "dir/old.txt", NewName: "dir/new.txt", TextFragments: []*TextFragment{frag}},
+			Name: "dir/new.txt",
+		},
+		"delete": {
+			File: &File{OldName: "dir/old.txt", IsDelete: true, TextFragments: []*TextFragment{frag}},
+			Name: "dir/old.txt",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var dst bytes.Buffer
+			err := Apply(&dst, strings.NewReader("different line\n"), test.File)
+
+			var aerr *ApplyError
+			if !errors.As(err, &aerr) {
+				t.Fatalf("expected *ApplyError, but got %T: %v", err, err)
+			}
+			if aerr.File != test.Name {
+				t.Errorf("incorrect file: expected %q, actual %q", test.Name, aerr.File)
+			}
+			if aerr.Fragment != 1 {
+				t.Errorf("incorrect fragment: expected 1, actual %d", aerr.Fragment)
+			}
+		})
+	}
+}
+
+func TestApplyReverse(t *testing.T) {
+	reverse := func(name string) applyFiles {
+		return applyFiles{
+			Src:   name + ".out",
+			Patch: name + ".patch",
+			Out:   name + ".src",
+		}
+	}
+
+	tests := map[string]applyTest{
+		"textCreate":      {Files: reverse("text_fragment_new")},
+		"textDeleteAll":   {Files: reverse("text_fragment_delete_all")},
+		"textAddMiddle":   {Files: reverse("text_fragment_add_middle")},
+		"textAddEndNoEOL": {Files: reverse("text_fragment_add_end_noeol")},
+		"textModifyFile": {
+			Files: applyFiles{
+				Src:   "file_text_modify.out",
+				Patch: "file_text_modify.patch",
+				Out:   "file_text.src",
+			},
+		},
+		"binaryLiteralModify": {Files: reverse("bin_fragment_literal_modify")},
+		"binaryDeltaModify":   {Files: reverse("file_bin_modify")},
+		"modeChange":          {Files: reverse("file_mode_change")},
+
+		"errorTextConflict": {
+			Files: applyFiles{
+				Src:   "text_fragment_change_middle.src",
+				Patch: "text_fragment_change_middle.patch",
+			},
+			Err: &Conflict{},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			test.run(t, func(dst io.Writer, src io.ReaderAt, file *File) error {
+				return ApplyReverse(dst, src, file)
+			})
+		})
+	}
+}
+
+func TestTextFragmentReverse(t *testing.T) {
+	frag := &TextFragment{
+		OldPosition:     3,
+		OldLines:        4,
+		NewPosition:     3,
+		NewLines:        5,
+		LinesAdded:      2,
+		LinesDeleted:    1,
+		LeadingContext:  1,
+		TrailingContext: 2,
+		Lines: []Line{
+			{Op: OpContext, Line: "context 1\n"},
+			{Op: OpDelete, Line: "old line\n"},
+			{Op: OpAdd, Line: "new line 1\n"},
+			{Op: OpAdd, Line: "new line 2\n"},
+			{Op: OpContext, Line: "context 2\n"},
+			{Op: OpContext, Line: "context 3"},
+		},
+	}
+
+	expected := &TextFragment{
+		OldPosition:     3,
+		OldLines:        5,
+		NewPosition:     3,
+		NewLines:        4,
+		LinesAdded:      1,
+		LinesDeleted:    2,
+		LeadingContext:  1,
+		TrailingContext: 2,
+		Lines: []Line{
+			{Op: OpContext, Line: "context 1\n"},
+			{Op: OpDelete, Line: "new line 1\n"},
+			{Op: OpDelete, Line: "new line 2\n"},
+			{Op: OpAdd, Line: "old line\n"},
+			{Op: OpContext, Line: "context 2\n"},
+			{Op: OpContext, Line: "context 3"},
+		},
+	}
+
+	actual := frag.Reverse()
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("incorrect reversed fragment\nexpected: %+v\n  actual: %+v", expected, actual)
+	}
+	if err := actual.Validate(); err != nil {
+		t.Errorf("reversed fragment is not valid: %v", err)
+	}
+}
+
+func TestApplyWithOffset(t *testing.T) {
+	const patch = `diff --git a/file.txt b/file.txt
+--- a/file.txt
++++ b/file.txt
+@@ -4,3 +4,3 @@
+ line 4
+-line 5
++line 5 changed
+ line 6
+`
+
+	lines := func(names ...string) string {
+		var b strings.Builder
+		for _, name := range names {
+			b.WriteString(name + "\n")
+		}
+		return b.String()
+	}
+
+	tests := map[string]struct {
+		Src       string
+		MaxOffset int64
+		Out       string
+		Offset    int64
+		Err       interface{}
+	}{
+		"exact": {
+			Src:       lines("line 1", "line 2", "line 3", "line 4", "line 5", "line 6"),
+			MaxOffset: 2,
+			Out:       lines("line 1", "line 2", "line 3", "line 4", "line 5 changed", "line 6"),
+		},
+		"after": {
+			Src:       lines("line 0", "line 1", "line 2", "line 3", "line 4", "line 5", "line 6"),
+			MaxOffset: 2,
+			Out:       lines("line 0", "line 1", "line 2", "line 3", "line 4", "line 5 changed", "line 6"),
+			Offset:    1,
+		},
+		"before": {
+			Src:       lines("line 2", "line 3", "line 4", "line 5", "line 6"),
+			MaxOffset: 2,
+			Out:       lines("line 2", "line 3", "line 4", "line 5 changed", "line 6"),
+			Offset:    -1,
+		},
+		"outsideWindow": {
+			Src:       lines("line -2", "line -1", "line 0", "line 1", "line 2", "line 3", "line 4", "line 5", "line 6"),
+			MaxOffset: 2,
+			Err:       &Conflict{},
+		},
+		"strict": {
+			Src: lines("line 0", "line 1", "line 2", "line 3", "line 4", "line 5", "line 6"),
+			Err: &Conflict{},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			files, _, err := Parse(strings.NewReader(patch))
+			if err != nil {
+				t.Fatalf("failed to parse patch: %v", err)
+			}
+
+			var dst bytes.Buffer
+			applier := NewTextApplier(&dst, strings.NewReader(test.Src), WithMaxOffset(test.MaxOffset))
+
+			err = applier.ApplyFragment(files[0].TextFragments[0])
+			if err == nil {
+				err = applier.Close()
+			}
+			if test.Err != nil {
+				assertError(t, test.Err, err, "applying fragment")
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error applying fragment: %v", err)
+			}
+
+			if test.Out != dst.String() {
+				t.Errorf("incorrect result after apply\nexpected: %q\n  actual: %q", test.Out, dst.String())
+			}
+			if test.Offset != applier.Offset() {
+				t.Errorf("incorrect offset: expected %d, actual %d", test.Offset, applier.Offset())
+			}
+		})
+	}
+}
+
+func TestApplyFragmentReadsBoundedLines(t *testing.T) {
+	const lines = 10000
+
+	var src strings.Builder
+	for i := 1; i <= lines; i++ {
+		fmt.Fprintf(&src, "line %d\n", i)
+	}
+	patch := fmt.Sprintf("diff --git a/file.txt b/file.txt\n"+
+		"--- a/file.txt\n"+
+		"+++ b/file.txt\n"+
+		"@@ -%d,2 +%d,2 @@\n"+
+		" line %d\n"+
+		"-line %d\n"+
+		"+line %d changed\n", lines-1, lines-1, lines-1, lines, lines)
+
+	files, _, err := Parse(strings.NewReader(patch))
+	if err != nil {
+		t.Fatalf("failed to parse patch: %v", err)
+	}
+
+	r := &maxLinesReaderAt{BytesLineReaderAt: NewBytesLineReaderAt([]byte(src.String()))}
+
+	var dst bytes.Buffer
+	if err := Apply(&dst, r, files[0]); err != nil {
+		t.Fatalf("unexpected error applying fragment: %v", err)
+	}
+
+	expected := strings.Replace(src.String(), fmt.Sprintf("line %d\n", lines), fmt.Sprintf("line %d changed\n", lines), 1)
+	if dst.String() != expected {
+		t.Errorf("incorrect result after apply")
+	}
+	if r.max > lineBufferSize {
+		t.Errorf("applier read %d lines at once, but should read at most %d", r.max, lineBufferSize)
+	}
+}
+
+func TestApplyMemory(t *testing.T) {
+	const lines = 100000
+
+	line := strings.Repeat("x", 55) + "\n"
+	src := strings.Repeat(line, lines)
+	patch := fmt.Sprintf("diff --git a/file.txt b/file.txt\n"+
+		"--- a/file.txt\n"+
+		"+++ b/file.txt\n"+
+		"@@ -%d +%d @@\n"+
+		"-%s"+
+		"+changed\n", lines, lines, line)
+
+	files, _, err := Parse(strings.NewReader(patch))
+	if err != nil {
+		t.Fatalf("failed to parse patch: %v", err)
+	}
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	err = Apply(io.Discard, strings.NewReader(src), files[0])
+	runtime.ReadMemStats(&after)
+
+	if err != nil {
+		t.Fatalf("unexpected error applying patch: %v", err)
+	}
+	if alloc := after.TotalAlloc - before.TotalAlloc; alloc > uint64(len(src)/16) {
+		t.Errorf("applying a change to the last line of %d bytes allocated %d bytes", len(src), alloc)
+	}
+}
+
+// maxLinesReaderAt records the largest number of lines read at once.
+type maxLinesReaderAt struct {
+	*BytesLineReaderAt
+	max int
+}
+
+func (r *maxLinesReaderAt) ReadLinesAt(lines [][]byte, offset int64) (int, error) {
+	if len(lines) > r.max {
+		r.max = len(lines)
+	}
+	return r.BytesLineReaderAt.ReadLinesAt(lines, offset)
+}
+
 type applyTest struct {
 	Files applyFiles
 	Err   interface{}
@@ -232,3 +917,398 @@ func (f applyFiles) Load(t *testing.T) (src []byte, patch []byte, out []byte) {
 	}
 	return
 }
+
+func TestApplyOptions(t *testing.T) {
+	const (
+		goPatch = `diff --git a/file.go b/file.go
+--- a/file.go
++++ b/file.go
+@@ -1,4 +1,4 @@
+ func main() {
+-	x := 1 + 2
++	x := 1 + 3
+ 	fmt.Println(x)
+ }
+`
+
+		lfPatch = "diff --git a/file.txt b/file.txt\n" +
+			"--- a/file.txt\n" +
+			"+++ b/file.txt\n" +
+			"@@ -1,3 +1,4 @@\n" +
+			" line 1\n" +
+			"-line 2\n" +
+			"+line 2 changed\n" +
+			"+line 2.5\n" +
+			" line 3\n"
+
+		crlfPatch = "diff --git a/file.txt b/file.txt\n" +
+			"--- a/file.txt\n" +
+			"+++ b/file.txt\n" +
+			"@@ -1,3 +1,4 @@\n" +
+			" line 1\r\n" +
+			"-line 2\r\n" +
+			"+line 2 changed\r\n" +
+			"+line 2.5\r\n" +
+			" line 3\r\n"
+	)
+
+	tests := map[string]struct {
+		Patch    string
+		Src      string
+		Options  []ApplyOption
+		Out      string
+		Warnings []Warning
+		Err      interface{}
+	}{
+		"ignoreWhitespaceExact": {
+			Patch:   goPatch,
+			Src:     "func main() {\n\tx := 1 + 2\n\tfmt.Println(x)\n}\n",
+			Options: []ApplyOption{WithIgnoreWhitespace()},
+			Out:     "func main() {\n\tx := 1 + 3\n\tfmt.Println(x)\n}\n",
+		},
+		"ignoreWhitespaceReflowed": {
+			Patch:   goPatch,
+			Src:     "func main()  {\n    x  :=  1 + 2  \n    fmt.Println(x)\r\n}\n",
+			Options: []ApplyOption{WithIgnoreWhitespace()},
+			Out:     "func main()  {\n\tx := 1 + 3\n    fmt.Println(x)\r\n}\n",
+		},
+		"ignoreWhitespaceRemoved": {
+			Patch:   goPatch,
+			Src:     "func main() {\n\tx := 1+2\n\tfmt.Println(x)\n}\n",
+			Options: []ApplyOption{WithIgnoreWhitespace()},
+			Err:     &Conflict{},
+		},
+		"strictWhitespace": {
+			Patch: goPatch,
+			Src:   "func main()  {\n    x  :=  1 + 2  \n    fmt.Println(x)\r\n}\n",
+			Err:   &Conflict{},
+		},
+
+		"trailingWhitespaceExact": {
+			Patch:   goPatch,
+			Src:     "func main() {\n\tx := 1 + 2\n\tfmt.Println(x)\n}\n",
+			Options: []ApplyOption{WithTrailingWhitespaceWarnings()},
+			Out:     "func main() {\n\tx := 1 + 3\n\tfmt.Println(x)\n}\n",
+		},
+		"trailingWhitespaceContext": {
+			Patch:   goPatch,
+			Src:     "func main() { \t\n\tx := 1 + 2\n\tfmt.Println(x)\n}\n",
+			Options: []ApplyOption{WithTrailingWhitespaceWarnings()},
+			Out:     "func main() { \t\n\tx := 1 + 3\n\tfmt.Println(x)\n}\n",
+			Warnings: []Warning{
+				{Line: 1, FragmentLine: 1, Expected: "func main() {\n", Actual: "func main() { \t\n"},
+			},
+		},
+		"trailingWhitespaceDeleted": {
+			Patch:   goPatch,
+			Src:     "func main() {\n\tx := 1 + 2  \n\tfmt.Println(x)\n}\n",
+			Options: []ApplyOption{WithTrailingWhitespaceWarnings()},
+			Out:     "func main() {\n\tx := 1 + 3\n\tfmt.Println(x)\n}\n",
+			Warnings: []Warning{
+				{Line: 2, FragmentLine: 2, Expected: "\tx := 1 + 2\n", Actual: "\tx := 1 + 2  \n"},
+			},
+		},
+		"trailingWhitespaceWithOffset": {
+			Patch:   goPatch,
+			Src:     "package main\n\nfunc main() {\n\tx := 1 + 2\n\tfmt.Println(x) \n}\n",
+			Options: []ApplyOption{WithTrailingWhitespaceWarnings(), WithMaxOffset(2)},
+			Out:     "package main\n\nfunc main() {\n\tx := 1 + 3\n\tfmt.Println(x) \n}\n",
+			Warnings: []Warning{
+				{Line: 5, FragmentLine: 4, Expected: "\tfmt.Println(x)\n", Actual: "\tfmt.Println(x) \n"},
+			},
+		},
+		"trailingWhitespaceCRLF": {
+			Patch:   goPatch,
+			Src:     "func main() {\r\n\tx := 1 + 2\r\n\tfmt.Println(x) \r\n}\r\n",
+			Options: []ApplyOption{WithTrailingWhitespaceWarnings(), WithLineEndingNormalization()},
+			Out:     "func main() {\r\n\tx := 1 + 3\r\n\tfmt.Println(x) \r\n}\r\n",
+			Warnings: []Warning{
+				{Line: 3, FragmentLine: 4, Expected: "\tfmt.Println(x)\n", Actual: "\tfmt.Println(x) \r\n"},
+			},
+		},
+		"trailingWhitespaceLineEnding": {
+			Patch:   goPatch,
+			Src:     "func main() {\r\n\tx := 1 + 2\n\tfmt.Println(x)\n}\n",
+			Options: []ApplyOption{WithTrailingWhitespaceWarnings()},
+			Err:     &Conflict{},
+		},
+		"trailingWhitespaceLeading": {
+			Patch:   goPatch,
+			Src:     "func main() {\n    x := 1 + 2\n\tfmt.Println(x)\n}\n",
+			Options: []ApplyOption{WithTrailingWhitespaceWarnings()},
+			Err:     &Conflict{},
+		},
+		"trailingWhitespaceContent": {
+			Patch:   goPatch,
+			Src:     "func main() {\n\tx := 1 + 2 \n\tfmt.Println(y)\n}\n",
+			Options: []ApplyOption{WithTrailingWhitespaceWarnings()},
+			Err:     &Conflict{},
+		},
+		"strictTrailingWhitespace": {
+			Patch: goPatch,
+			Src:   "func main() { \n\tx := 1 + 2\n\tfmt.Println(x)\n}\n",
+			Err:   &Conflict{},
+		},
+
+		"normalizeLFPatchCRLFSrc": {
+			Patch:   lfPatch,
+			Src:     "line 1\r\nline 2\r\nline 3\r\n",
+			Options: []ApplyOption{WithLineEndingNormalization()},
+			Out:     "line 1\r\nline 2 changed\r\nline 2.5\r\nline 3\r\n",
+		},
+		"normalizeCRLFPatchLFSrc": {
+			Patch:   crlfPatch,
+			Src:     "line 1\nline 2\nline 3\n",
+			Options: []ApplyOption{WithLineEndingNormalization()},
+			Out:     "line 1\nline 2 changed\nline 2.5\nline 3\n",
+		},
+		"normalizeMixedSrc": {
+			Patch:   lfPatch,
+			Src:     "line 1\r\nline 2\nline 3\n",
+			Options: []ApplyOption{WithLineEndingNormalization()},
+			Out:     "line 1\r\nline 2 changed\r\nline 2.5\r\nline 3\n",
+		},
+		"normalizeMatchingEndings": {
+			Patch:   lfPatch,
+			Src:     "line 1\nline 2\nline 3\n",
+			Options: []ApplyOption{WithLineEndingNormalization()},
+			Out:     "line 1\nline 2 changed\nline 2.5\nline 3\n",
+		},
+		"normalizeDifferentContent": {
+			Patch:   lfPatch,
+			Src:     "line 1\r\nline two\r\nline 3\r\n",
+			Options: []ApplyOption{WithLineEndingNormalization()},
+			Err:     &Conflict{},
+		},
+		"strictLineEndings": {
+			Patch: lfPatch,
+			Src:   "line 1\r\nline 2\r\nline 3\r\n",
+			Err:   &Conflict{},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			files, _, err := Parse(strings.NewReader(test.Patch))
+			if err != nil {
+				t.Fatalf("failed to parse patch: %v", err)
+			}
+
+			var dst bytes.Buffer
+			res, err := files[0].ApplyReport(&dst, strings.NewReader(test.Src), test.Options...)
+			if test.Err != nil {
+				assertError(t, test.Err, err, "applying fragment")
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error applying fragment: %v", err)
+			}
+			if dst.String() != test.Out {
+				t.Errorf("incorrect result\nexpected: %q\n  actual: %q", test.Out, dst.String())
+			}
+
+			warnings := res.Fragments[0].Warnings
+			if len(warnings) != len(test.Warnings) {
+				t.Fatalf("incorrect number of warnings: expected %d, actual %d: %+v", len(test.Warnings), len(warnings), warnings)
+			}
+			for i, w := range warnings {
+				exp := test.Warnings[i]
+				if w.Line != exp.Line || w.FragmentLine != exp.FragmentLine || w.Expected != exp.Expected || w.Actual != exp.Actual {
+					t.Errorf("incorrect warning %d\nexpected: %+v\n  actual: %+v", i, exp, w)
+				}
+				if w.String() == "" {
+					t.Errorf("warning %d has no message", i)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyConflictDetails(t *testing.T) {
+	const patch = `diff --git a/file.txt b/file.txt
+--- a/file.txt
++++ b/file.txt
+@@ -2,3 +2,3 @@
+ line 2
+-line 3
++line 3 changed
+ line 4
+`
+	const src = "line 1\nline 2\nline three\nline 4\n"
+
+	files, _, err := Parse(strings.NewReader(patch))
+	if err != nil {
+		t.Fatalf("failed to parse patch: %v", err)
+	}
+
+	var dst bytes.Buffer
+	err = Apply(&dst, strings.NewReader(src), files[0])
+	if !errors.Is(err, &Conflict{}) {
+		t.Fatalf("expected conflict, but got: %v", err)
+	}
+
+	var c *Conflict
+	if !errors.As(err, &c) {
+		t.Fatalf("expected *Conflict, but got %T", err)
+	}
+	if c.Line != 3 {
+		t.Errorf("incorrect conflict line: expected 3, actual %d", c.Line)
+	}
+	if c.Expected != "line 3\n" {
+		t.Errorf("incorrect expected content: %q", c.Expected)
+	}
+	if c.Actual != "line three\n" {
+		t.Errorf("incorrect actual content: %q", c.Actual)
+	}
+	if err.Error() != "conflict: fragment line does not match src line" {
+		t.Errorf("incorrect error message: %v", err)
+	}
+}
+
+func TestFileCheck(t *testing.T) {
+	const patch = `diff --git a/file.txt b/file.txt
+--- a/file.txt
++++ b/file.txt
+@@ -1,3 +1,3 @@
+ line 1
+-line 2
++line 2 changed
+ line 3
+@@ -5,3 +5,3 @@
+ line 5
+-line 6
++line 6 changed
+ line 7
+@@ -9,2 +9,2 @@
+ line 9
+-line 10
++line 10 changed
+`
+
+	tests := map[string]struct {
+		Src       string
+		Conflicts []FragmentConflict
+	}{
+		"noConflicts": {
+			Src: "line 1\nline 2\nline 3\nline 4\nline 5\nline 6\nline 7\nline 8\nline 9\nline 10\n",
+		},
+		"multipleConflicts": {
+			Src: "line 1\nline two\nline 3\nline 4\nline 5\nline 6\nline 7\nline 8\nline 9\nline ten\n",
+			Conflicts: []FragmentConflict{
+				{Fragment: 1, Line: 2, FragmentLine: 2},
+				{Fragment: 3, Line: 10, FragmentLine: 2},
+			},
+		},
+		"shortSource": {
+			Src: "line 1\nline 2\nline 3\nline 4\nline 5\nline 6\nline 7\n",
+			Conflicts: []FragmentConflict{
+				{Fragment: 3, Line: 8},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			files, _, err := Parse(strings.NewReader(patch))
+			if err != nil {
+				t.Fatalf("failed to parse patch: %v", err)
+			}
+
+			conflicts := files[0].Check(strings.NewReader(test.Src))
+			if len(conflicts) != len(test.Conflicts) {
+				t.Fatalf("incorrect number of conflicts: expected %d, actual %d: %+v", len(test.Conflicts), len(conflicts), conflicts)
+			}
+			for i, c := range conflicts {
+				exp := test.Conflicts[i]
+				if c.Err == nil {
+					t.Errorf("conflict %d has no error", i)
+				}
+				c.Err = nil
+				if c != exp {
+					t.Errorf("incorrect conflict %d\nexpected: %+v\n  actual: %+v", i, exp, c)
+				}
+			}
+		})
+	}
+}
+
+func TestFileApplyReport(t *testing.T) {
+	const patch = `diff --git a/file.txt b/file.txt
+--- a/file.txt
++++ b/file.txt
+@@ -5,3 +5,3 @@
+ line 5
+-line 6
++line 6 changed
+ line 7
+@@ -1,3 +1,3 @@
+ line 1
+-line 2
++line 2 changed
+ line 3
+`
+
+	tests := map[string]struct {
+		Src     string
+		Out     string
+		Results []FragmentResult
+		Err     interface{}
+	}{
+		"exact": {
+			Src: "line 1\nline 2\nline 3\nline 4\nline 5\nline 6\nline 7\n",
+			Out: "line 1\nline 2 changed\nline 3\nline 4\nline 5\nline 6 changed\nline 7\n",
+			Results: []FragmentResult{
+				{Index: 1, Applied: true},
+				{Index: 0, Applied: true},
+			},
+		},
+		"offset": {
+			Src: "line 1\nline 2\nline 3\nline 4\nline 4.5\nline 5\nline 6\nline 7\n",
+			Out: "line 1\nline 2 changed\nline 3\nline 4\nline 4.5\nline 5\nline 6 changed\nline 7\n",
+			Results: []FragmentResult{
+				{Index: 1, Applied: true},
+				{Index: 0, Applied: true, Offset: 1},
+			},
+		},
+		"conflict": {
+			Src: "line 1\nline 2\nline 3\nline 4\nline 5\nline six\nline 7\n",
+			Results: []FragmentResult{
+				{Index: 1, Applied: true},
+				{Index: 0},
+			},
+			Err: &Conflict{},
+		},
+		"earlyConflict": {
+			Src: "line 1\nline two\nline 3\nline 4\nline 5\nline 6\nline 7\n",
+			Results: []FragmentResult{
+				{Index: 1},
+				{Index: 0},
+			},
+			Err: &Conflict{},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			files, _, err := Parse(strings.NewReader(patch))
+			if err != nil {
+				t.Fatalf("failed to parse patch: %v", err)
+			}
+
+			var dst bytes.Buffer
+			res, err := files[0].ApplyReport(&dst, strings.NewReader(test.Src), WithMaxOffset(2))
+			if test.Err != nil {
+				assertError(t, test.Err, err, "applying file")
+			} else if err != nil {
+				t.Fatalf("unexpected error applying file: %v", err)
+			}
+
+			if !reflect.DeepEqual(test.Results, res.Fragments) {
+				t.Errorf("incorrect results\nexpected: %+v\n  actual: %+v", test.Results, res.Fragments)
+			}
+			if test.Err == nil && test.Out != dst.String() {
+				t.Errorf("incorrect result after apply\nexpected: %q\n  actual: %q", test.Out, dst.String())
+			}
+		})
+	}
+}
@@ -1,7 +1,10 @@
 package gitdiff
 
 import (
+	"bytes"
+	"errors"
 	"io"
+	"strings"
 )
 
 // TextApplier applies changes described in text fragments to source data. If
@@ -9,12 +12,19 @@ import (
 // in order. The applier must be closed after use.
 //
 // By default, TextApplier operates in "strict" mode, where fragment content
-// and positions must exactly match those of the source.
+// and positions must exactly match those of the source. Use the
+// WithMaxOffset option to allow fragments to apply at other positions.
 type TextApplier struct {
 	dst      io.Writer
 	src      io.ReaderAt
 	lineSrc  LineReaderAt
 	nextLine int64
+	offset   int64
+	eol      string
+	eolFound bool
+	warnings []Warning
+
+	opts applyOptions
 
 	closed bool
 	dirty  bool
@@ -22,11 +32,14 @@ type TextApplier struct {
 
 // NewTextApplier creates a TextApplier that reads data from src and writes
 // modified data to dst. If src implements LineReaderAt, it is used directly.
-func NewTextApplier(dst io.Writer, src io.ReaderAt) *TextApplier {
+func NewTextApplier(dst io.Writer, src io.ReaderAt, options ...ApplyOption) *TextApplier {
 	a := TextApplier{
 		dst: dst,
 		src: src,
 	}
+	for _, optFn := range options {
+		optFn(&a.opts)
+	}
 
 	if lineSrc, ok := src.(LineReaderAt); ok {
 		a.lineSrc = lineSrc
@@ -43,6 +56,12 @@ func NewTextApplier(dst io.Writer, src io.ReaderAt) *TextApplier {
 // called in order of increasing start position. As a result, each fragment can
 // be applied at most once.
 //
+// If the applier allows offsets and the fragment does not match the source
+// at its stated position, ApplyFragment searches for a nearby position where
+// all context and deleted lines match. Positions are tried in order of
+// increasing distance, preferring later positions at equal distance. After a
+// successful apply, Offset returns the offset that was used.
+//
 // If an error occurs while applying, ApplyFragment returns an *ApplyError that
 // annotates the error with additional information. If the error is because of
 // a conflict between the fragment and the source, the wrapped error will be a
@@ -59,6 +78,9 @@ func (a *TextApplier) ApplyFragment(f *TextFragment) error {
 	if err := f.Validate(); err != nil {
 		return applyError(err)
 	}
+	if len(f.ParentRanges) > 0 {
+		return applyError(errors.New("cannot apply combined diff fragment"))
+	}
 
 	// lines are 0-indexed, positions are 1-indexed (but new files have position = 0)
 	fragStart := f.OldPosition - 1
@@ -69,7 +91,25 @@ func (a *TextApplier) ApplyFragment(f *TextFragment) error {
 
 	start := a.nextLine
 	if fragStart < start {
-		return applyError(&Conflict{"fragment overlaps with an applied fragment"})
+		return applyError(&Conflict{msg: "fragment overlaps with an applied fragment"})
+	}
+
+	if a.opts.normalizeLineEndings && !a.eolFound {
+		if err := a.detectLineEnding(); err != nil {
+			return applyError(err)
+		}
+	}
+
+	a.offset = 0
+	a.warnings = nil
+	if f.OldPosition > 0 && a.opts.maxOffset > 0 {
+		offset, err := a.findOffset(f, fragStart)
+		if err != nil {
+			return applyError(err)
+		}
+		fragStart += offset
+		fragEnd += offset
+		a.offset = offset
 	}
 
 	if f.OldPosition == 0 {
@@ -78,29 +118,31 @@ func (a *TextApplier) ApplyFragment(f *TextFragment) error {
 			return applyError(err)
 		}
 		if !ok {
-			return applyError(&Conflict{"cannot create new file from non-empty src"})
+			return applyError(&Conflict{msg: "cannot create new file from non-empty src"})
 		}
 	}
 
-	preimage := make([][]byte, fragEnd-start)
-	n, err := a.lineSrc.ReadLinesAt(preimage, start)
+	// copy leading data before the fragment starts
+	copied, err := copyLines(a.dst, a.lineSrc, start, fragStart-start)
+	if err == nil && copied < fragStart-start {
+		err = io.EOF
+	}
 	if err != nil {
-		return applyError(err, lineNum(start+int64(n)))
+		a.nextLine = start + copied
+		return applyError(err, lineNum(a.nextLine))
 	}
+	a.nextLine = fragStart
 
-	// copy leading data before the fragment starts
-	for i, line := range preimage[:fragStart-start] {
-		if _, err := a.dst.Write(line); err != nil {
-			a.nextLine = start + int64(i)
-			return applyError(err, lineNum(a.nextLine))
-		}
+	preimage := make([][]byte, fragEnd-fragStart)
+	n, err := a.lineSrc.ReadLinesAt(preimage, fragStart)
+	if err != nil {
+		return applyError(err, lineNum(fragStart+int64(n)))
 	}
-	preimage = preimage[fragStart-start:]
 
 	// apply the changes in the fragment
 	used := int64(0)
 	for i, line := range f.Lines {
-		if err := applyTextLine(a.dst, line, preimage, used); err != nil {
+		if err := a.applyTextLine(line, preimage, used, fragStart+used+1, i+1); err != nil {
 			a.nextLine = fragStart + used
 			return applyError(err, lineNum(a.nextLine), fragLineNum(i))
 		}
@@ -118,23 +160,207 @@ func (a *TextApplier) ApplyFragment(f *TextFragment) error {
 			return applyError(err, lineNum(a.nextLine))
 		}
 		if n > 0 {
-			return applyError(&Conflict{"src still has content after full delete"}, lineNum(a.nextLine))
+			return applyError(&Conflict{msg: "src still has content after full delete"}, lineNum(a.nextLine))
 		}
 	}
 
 	return nil
 }
 
-func applyTextLine(dst io.Writer, line Line, preimage [][]byte, i int64) (err error) {
-	if line.Old() && string(preimage[i]) != line.Line {
-		return &Conflict{"fragment line does not match src line"}
+// Offset returns the number of lines between the stated position of the most
+// recently applied fragment and the position where it was applied. It is
+// positive if the fragment applied after its stated position and negative if
+// it applied before. Offset is always zero in strict mode.
+func (a *TextApplier) Offset() int64 {
+	return a.offset
+}
+
+// Warnings returns the warnings for lines of the most recently applied
+// fragment that did not exactly match the source. It is always empty unless
+// the applier allows soft mismatches, like with WithTrailingWhitespaceWarnings.
+func (a *TextApplier) Warnings() []Warning {
+	return a.warnings
+}
+
+// findOffset searches for a position near start where the old lines of f
+// match the source and returns the offset from start to that position. The
+// search is limited to positions after the last applied fragment. If there is
+// no match, findOffset returns zero so that applying the fragment reports a
+// conflict at the stated position.
+func (a *TextApplier) findOffset(f *TextFragment, start int64) (int64, error) {
+	for d := int64(0); d <= a.opts.maxOffset; d++ {
+		offsets := []int64{d, -d}
+		if d == 0 {
+			offsets = offsets[:1]
+		}
+		for _, offset := range offsets {
+			if start+offset < a.nextLine {
+				continue
+			}
+			ok, err := a.matchesAt(f, start+offset)
+			if err != nil {
+				return 0, err
+			}
+			if ok {
+				return offset, nil
+			}
+		}
+	}
+	return 0, nil
+}
+
+// matchesAt returns true if the old lines of f match the source starting at
+// line pos.
+func (a *TextApplier) matchesAt(f *TextFragment, pos int64) (bool, error) {
+	preimage := make([][]byte, f.OldLines)
+	n, err := a.lineSrc.ReadLinesAt(preimage, pos)
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	if int64(n) < f.OldLines {
+		return false, nil
+	}
+
+	i := 0
+	for _, line := range f.Lines {
+		if line.Old() {
+			if ok, _ := a.lineMatchesSoft(preimage[i], line.Line); !ok {
+				return false, nil
+			}
+			i++
+		}
+	}
+	return true, nil
+}
+
+// applyTextLine applies a line of a fragment, where i is the index of the
+// matching line in preimage, lineno is its one-indexed line number in the
+// source, and fragLine is the one-indexed line number in the fragment.
+func (a *TextApplier) applyTextLine(line Line, preimage [][]byte, i, lineno int64, fragLine int) (err error) {
+	if line.Old() {
+		ok, soft := a.lineMatchesSoft(preimage[i], line.Line)
+		if !ok {
+			return &Conflict{
+				Line:     lineno,
+				Expected: line.Line,
+				Actual:   string(preimage[i]),
+				msg:      "fragment line does not match src line",
+			}
+		}
+		if soft {
+			a.warnings = append(a.warnings, Warning{
+				Line:         lineno,
+				FragmentLine: fragLine,
+				Expected:     line.Line,
+				Actual:       string(preimage[i]),
+				msg:          "trailing whitespace differs from fragment line",
+			})
+		}
 	}
-	if line.New() {
-		_, err = io.WriteString(dst, line.Line)
+	switch {
+	case line.Op == OpContext && (a.opts.ignoreWhitespace || a.opts.normalizeLineEndings || a.opts.warnTrailingWhitespace):
+		_, err = a.dst.Write(preimage[i])
+	case line.New() && a.opts.normalizeLineEndings:
+		_, err = io.WriteString(a.dst, convertEOL(line.Line, a.eol))
+	case line.New():
+		_, err = io.WriteString(a.dst, line.Line)
 	}
 	return err
 }
 
+// lineMatches returns true if the source line matches the fragment line,
+// ignoring whitespace or line ending differences if the applier allows them.
+func (a *TextApplier) lineMatches(src []byte, line string) bool {
+	switch {
+	case a.opts.ignoreWhitespace:
+		return normalizeWhitespace(string(src)) == normalizeWhitespace(line)
+	case a.opts.normalizeLineEndings:
+		return normalizeEOL(string(src)) == normalizeEOL(line)
+	}
+	return string(src) == line
+}
+
+// lineMatchesSoft is like lineMatches, but also allows differences in
+// trailing whitespace if the applier warns about them. It returns true as the
+// second value if the lines only match because of this.
+func (a *TextApplier) lineMatchesSoft(src []byte, line string) (ok bool, soft bool) {
+	if a.lineMatches(src, line) {
+		return true, false
+	}
+	if !a.opts.warnTrailingWhitespace {
+		return false, false
+	}
+
+	srcLine := string(src)
+	if a.opts.normalizeLineEndings {
+		srcLine, line = normalizeEOL(srcLine), normalizeEOL(line)
+	}
+	ok = trimTrailingWhitespace(srcLine) == trimTrailingWhitespace(line)
+	return ok, ok
+}
+
+// trimTrailingWhitespace removes spaces and tabs before the line ending of s.
+// The line ending is not changed.
+func trimTrailingWhitespace(s string) string {
+	var eol string
+	switch {
+	case strings.HasSuffix(s, "\r\n"):
+		eol = "\r\n"
+	case strings.HasSuffix(s, "\n"):
+		eol = "\n"
+	}
+	return strings.TrimRight(strings.TrimSuffix(s, eol), " \t") + eol
+}
+
+// detectLineEnding sets the line ending used for added lines from the first
+// line of the source. If the first line has no line ending, added lines keep
+// the line endings from the patch. The source is only checked once.
+func (a *TextApplier) detectLineEnding() error {
+	var b [1][]byte
+	n, err := a.lineSrc.ReadLinesAt(b[:], 0)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	switch {
+	case n == 0 || !bytes.HasSuffix(b[0], []byte("\n")):
+		a.eol = ""
+	case bytes.HasSuffix(b[0], []byte("\r\n")):
+		a.eol = "\r\n"
+	default:
+		a.eol = "\n"
+	}
+	a.eolFound = true
+	return nil
+}
+
+// convertEOL replaces the line ending of line with eol. If line has no line
+// ending or eol is empty, it returns line unchanged.
+func convertEOL(line, eol string) string {
+	if eol == "" || !strings.HasSuffix(line, "\n") {
+		return line
+	}
+	return strings.TrimSuffix(normalizeEOL(line), "\n") + eol
+}
+
+// normalizeWhitespace replaces runs of spaces and tabs in s with a single
+// space and removes whitespace and line endings from the end of s.
+func normalizeWhitespace(s string) string {
+	var b strings.Builder
+	space := false
+	for _, c := range []byte(strings.TrimRight(s, " \t\r\n")) {
+		if c == ' ' || c == '\t' {
+			space = true
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
 // Close writes any data following the last applied fragment and prevents
 // future calls to ApplyFragment.
 func (a *TextApplier) Close() (err error) {
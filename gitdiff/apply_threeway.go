@@ -0,0 +1,337 @@
+package gitdiff
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	mergeMarkerOurs   = "<<<<<<< ours\n"
+	mergeMarkerSep    = "=======\n"
+	mergeMarkerTheirs = ">>>>>>> theirs\n"
+)
+
+// MergeConflict indicates a three-way merge completed, but the result
+// contains conflict markers for changes that could not be merged.
+//
+// Users can test if an error was caused by a merge conflict by using
+// errors.As or errors.Is with an empty MergeConflict:
+//
+//	if errors.Is(err, &MergeConflict{}) {
+//	    // handle merge conflict
+//	}
+type MergeConflict struct {
+	// Conflicts is the number of conflicting regions in the result
+	Conflicts int
+}
+
+func (c *MergeConflict) Error() string {
+	return fmt.Sprintf("merge conflict: %d conflicting region(s)", c.Conflicts)
+}
+
+// Is implements error matching for MergeConflict. Passing an empty instance
+// of MergeConflict always returns true.
+func (c *MergeConflict) Is(other error) bool {
+	if other, ok := other.(*MergeConflict); ok {
+		return other.Conflicts == 0 || other.Conflicts == c.Conflicts
+	}
+	return false
+}
+
+// ApplyThreeWay applies the changes in f to src, writing the result to dst.
+// If the changes do not apply cleanly, it falls back to a three-way merge, like
+// `git apply --3way`: the changes are applied to ancestor, the original
+// version of the file the patch was created from, and the result is merged
+// with src.
+//
+// If the merge has conflicts, ApplyThreeWay writes the merged content with
+// standard conflict markers to dst and returns a *MergeConflict. If the patch
+// includes the object ID of the original file, ApplyThreeWay checks that it
+// matches ancestor before merging. Binary files are never merged.
+//
+// Options are handled in the same way as Apply.
+func ApplyThreeWay(dst io.Writer, src, ancestor io.ReaderAt, f *File, options ...ApplyOption) error {
+	var out bytes.Buffer
+	err := Apply(&out, src, f, options...)
+	if err == nil {
+		_, err = dst.Write(out.Bytes())
+		return err
+	}
+	if !isMergeable(err) || f.IsBinary || f.IsNew {
+		return err
+	}
+
+	base, err := readAllAt(ancestor)
+	if err != nil {
+		return applyError(err, fileName(patchFileName(f)))
+	}
+	if err := checkBlobOID(base, f.OldOIDPrefix); err != nil {
+		return applyError(err, fileName(patchFileName(f)))
+	}
+
+	var theirs bytes.Buffer
+	if err := Apply(&theirs, bytes.NewReader(base), f, options...); err != nil {
+		return err
+	}
+
+	ours, err := readAllAt(src)
+	if err != nil {
+		return applyError(err, fileName(patchFileName(f)))
+	}
+
+	conflicts, err := merge3(dst, splitLines(base), splitLines(ours), splitLines(theirs.Bytes()))
+	if err != nil {
+		return err
+	}
+	if conflicts > 0 {
+		return &MergeConflict{Conflicts: conflicts}
+	}
+	return nil
+}
+
+// isMergeable returns true if err indicates that the content of the source
+// does not match the patch, as opposed to an invalid patch or an I/O error.
+func isMergeable(err error) bool {
+	return errors.Is(err, &Conflict{}) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+func readAllAt(r io.ReaderAt) ([]byte, error) {
+	return io.ReadAll(io.NewSectionReader(r, 0, math.MaxInt64))
+}
+
+// checkBlobOID checks that data is the content of the Git blob with the
+// given object ID prefix. It accepts both SHA1 and SHA256 IDs. Empty and null
+// IDs are not checked.
+func checkBlobOID(data []byte, prefix string) error {
+	if prefix == "" || strings.Trim(prefix, "0") == "" {
+		return nil
+	}
+
+	header := "blob " + strconv.Itoa(len(data)) + "\x00"
+
+	sha1Sum := sha1.New()
+	sha1Sum.Write([]byte(header))
+	sha1Sum.Write(data)
+	if strings.HasPrefix(hex.EncodeToString(sha1Sum.Sum(nil)), prefix) {
+		return nil
+	}
+
+	sha256Sum := sha256.New()
+	sha256Sum.Write([]byte(header))
+	sha256Sum.Write(data)
+	if strings.HasPrefix(hex.EncodeToString(sha256Sum.Sum(nil)), prefix) {
+		return nil
+	}
+
+	return fmt.Errorf("ancestor does not match object ID %s", prefix)
+}
+
+// splitLines splits data into lines that include their newline characters.
+func splitLines(data []byte) []string {
+	if len(data) == 0 {
+		return nil
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// merge3 writes the result of merging the changes from base to ours and from
+// base to theirs to w. It returns the number of conflicting regions, which are
+// surrounded by conflict markers in the output.
+func merge3(w io.Writer, base, ours, theirs []string) (conflicts int, err error) {
+	fm := newFormatter(w)
+
+	writeLines := func(lines []string, marker bool) {
+		for _, line := range lines {
+			fm.WriteString(line)
+		}
+		if marker && len(lines) > 0 && !strings.HasSuffix(lines[len(lines)-1], "\n") {
+			fm.WriteByte('\n')
+		}
+	}
+
+	matchOurs := matchLines(base, ours)
+	matchTheirs := matchLines(base, theirs)
+
+	i, o, t := 0, 0, 0
+	for i < len(base) || o < len(ours) || t < len(theirs) {
+		// stable line: unchanged in both versions
+		if i < len(base) && matchOurs[i] == o && matchTheirs[i] == t {
+			fm.WriteString(base[i])
+			i, o, t = i+1, o+1, t+1
+			continue
+		}
+
+		// find the end of the unstable region: the next base line that
+		// appears in both versions or the end of all inputs
+		j, oEnd, tEnd := i, len(ours), len(theirs)
+		for ; j < len(base); j++ {
+			if matchOurs[j] >= 0 && matchTheirs[j] >= 0 {
+				oEnd, tEnd = matchOurs[j], matchTheirs[j]
+				break
+			}
+		}
+
+		baseChunk, oursChunk, theirsChunk := base[i:j], ours[o:oEnd], theirs[t:tEnd]
+		switch {
+		case equalLines(oursChunk, baseChunk):
+			writeLines(theirsChunk, false)
+		case equalLines(theirsChunk, baseChunk), equalLines(oursChunk, theirsChunk):
+			writeLines(oursChunk, false)
+		default:
+			conflicts++
+			fm.WriteString(mergeMarkerOurs)
+			writeLines(oursChunk, true)
+			fm.WriteString(mergeMarkerSep)
+			writeLines(theirsChunk, true)
+			fm.WriteString(mergeMarkerTheirs)
+		}
+		i, o, t = j, oEnd, tEnd
+	}
+	return conflicts, fm.err
+}
+
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// matchLines finds a longest common subsequence of a and b. It returns a
+// slice where each element is the index of the matching line in b for the
+// line at the same index in a, or -1 if the line has no match.
+func matchLines(a, b []string) []int {
+	match := make([]int, len(a))
+	for i := range match {
+		match[i] = -1
+	}
+
+	// match common prefix and suffix directly to reduce the work for the diff
+	start := 0
+	for start < len(a) && start < len(b) && a[start] == b[start] {
+		match[start] = start
+		start++
+	}
+	endA, endB := len(a), len(b)
+	for endA > start && endB > start && a[endA-1] == b[endB-1] {
+		endA, endB = endA-1, endB-1
+		match[endA] = endB
+	}
+
+	myersMatch(a[start:endA], b[start:endB], func(i, j int) {
+		match[start+i] = start + j
+	})
+	return match
+}
+
+// myersMatch calls fn for each pair of matching lines in a longest common
+// subsequence of a and b, using the linear space variation of the algorithm
+// from "An O(ND) Difference Algorithm and Its Variations" by Eugene W. Myers.
+// Pairs are reported in increasing order.
+func myersMatch(a, b []string, fn func(i, j int)) {
+	size := 2*((len(a)+len(b)+1)/2) + 3
+	myersMatchRange(a, b, 0, 0, make([]int, size), make([]int, size), fn)
+}
+
+// myersMatchRange finds the matches for a and b, which start at aOff and bOff
+// in the original input, by splitting them at the middle snake of the edit
+// path. The forward and reverse vectors are reused by each recursive call.
+func myersMatchRange(a, b []string, aOff, bOff int, vf, vr []int, fn func(i, j int)) {
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		fn(aOff, bOff)
+		a, b = a[1:], b[1:]
+		aOff, bOff = aOff+1, bOff+1
+	}
+
+	suffix := 0
+	for suffix < len(a) && suffix < len(b) && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	a, b = a[:len(a)-suffix], b[:len(b)-suffix]
+
+	if len(a) > 0 && len(b) > 0 {
+		x, y, u, v := myersMiddleSnake(a, b, vf, vr)
+		myersMatchRange(a[:x], b[:y], aOff, bOff, vf, vr, fn)
+		for i := 0; i < u-x; i++ {
+			fn(aOff+x+i, bOff+y+i)
+		}
+		myersMatchRange(a[u:], b[v:], aOff+u, bOff+v, vf, vr, fn)
+	}
+
+	for i := 0; i < suffix; i++ {
+		fn(aOff+len(a)+i, bOff+len(b)+i)
+	}
+}
+
+// myersMiddleSnake returns the start (x, y) and end (u, v) of the middle
+// snake of the shortest edit path between a and b. It searches forward from
+// the start and in reverse from the end at the same time until the paths
+// overlap. For the reverse search, vr is indexed by the diagonal of the
+// reversed input.
+func myersMiddleSnake(a, b []string, vf, vr []int) (x, y, u, v int) {
+	n, m := len(a), len(b)
+	max := (n + m + 1) / 2
+	delta := n - m
+	odd := delta%2 != 0
+	offset := max + 1
+
+	vf[offset+1] = 0
+	vr[offset+1] = 0
+	for d := 0; d <= max; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && vf[offset+k-1] < vf[offset+k+1]) {
+				x = vf[offset+k+1]
+			} else {
+				x = vf[offset+k-1] + 1
+			}
+			y := x - k
+			startX, startY := x, y
+			for x < n && y < m && a[x] == b[y] {
+				x, y = x+1, y+1
+			}
+			vf[offset+k] = x
+
+			if c := delta - k; odd && c >= -(d-1) && c <= d-1 && x+vr[offset+c] >= n {
+				return startX, startY, x, y
+			}
+		}
+
+		for c := -d; c <= d; c += 2 {
+			var x int
+			if c == -d || (c != d && vr[offset+c-1] < vr[offset+c+1]) {
+				x = vr[offset+c+1]
+			} else {
+				x = vr[offset+c-1] + 1
+			}
+			y := x - c
+			startX, startY := x, y
+			for x < n && y < m && a[n-1-x] == b[m-1-y] {
+				x, y = x+1, y+1
+			}
+			vr[offset+c] = x
+
+			if k := delta - c; !odd && k >= -d && k <= d && x+vf[offset+k] >= n {
+				return n - x, m - y, n - startX, m - startY
+			}
+		}
+	}
+	panic("gitdiff: no middle snake found")
+}
@@ -0,0 +1,174 @@
+package gitdiff
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestApplyThreeWay(t *testing.T) {
+	const base = "line 1\nline 2\nline 3\nline 4\nline 5\nline 6\nline 7\n"
+
+	const patch = `diff --git a/file.txt b/file.txt
+index %s..1111111 100644
+--- a/file.txt
++++ b/file.txt
+@@ -2,5 +2,5 @@
+ line 2
+ line 3
+-line 4
++line 4 theirs
+ line 5
+ line 6
+`
+
+	tests := map[string]struct {
+		Src       string
+		OID       string
+		Result    string
+		Conflicts int
+		Err       interface{}
+	}{
+		"clean": {
+			Src:    base,
+			OID:    "734156d",
+			Result: "line 1\nline 2\nline 3\nline 4 theirs\nline 5\nline 6\nline 7\n",
+		},
+		"merged": {
+			Src:    "line 1\nline 2 ours\nline 3\nline 4\nline 5\nline 6\nline 7\nline 8 ours\n",
+			OID:    "734156d",
+			Result: "line 1\nline 2 ours\nline 3\nline 4 theirs\nline 5\nline 6\nline 7\nline 8 ours\n",
+		},
+		"sameChange": {
+			Src:    "line 1\nline 2 ours\nline 3\nline 4 theirs\nline 5\nline 6\nline 7\n",
+			OID:    "734156d",
+			Result: "line 1\nline 2 ours\nline 3\nline 4 theirs\nline 5\nline 6\nline 7\n",
+		},
+		"conflict": {
+			Src:       "line 1\nline 2\nline 3\nline 4 ours\nline 5 ours\nline 6\nline 7\n",
+			OID:       "734156d",
+			Result:    "line 1\nline 2\nline 3\n<<<<<<< ours\nline 4 ours\nline 5 ours\n=======\nline 4 theirs\nline 5\n>>>>>>> theirs\nline 6\nline 7\n",
+			Conflicts: 1,
+			Err:       &MergeConflict{},
+		},
+		"conflictNoEOL": {
+			Src:       "line 1\nline 2\nline 3\nline 4 ours",
+			OID:       "734156d",
+			Result:    "line 1\nline 2\nline 3\n<<<<<<< ours\nline 4 ours\n=======\nline 4 theirs\nline 5\nline 6\nline 7\n>>>>>>> theirs\n",
+			Conflicts: 1,
+			Err:       &MergeConflict{},
+		},
+		"wrongAncestor": {
+			Src: "line 1\nline 2 ours\nline 3\nline 4\nline 5\nline 6\nline 7\n",
+			OID: "abcdef0",
+			Err: "does not match object ID",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			files, _, err := Parse(strings.NewReader(strings.Replace(patch, "%s", test.OID, 1)))
+			if err != nil {
+				t.Fatalf("failed to parse patch: %v", err)
+			}
+
+			var dst bytes.Buffer
+			err = ApplyThreeWay(&dst, strings.NewReader(test.Src), strings.NewReader(base), files[0])
+			switch {
+			case test.Err != nil:
+				assertError(t, test.Err, err, "applying three-way")
+			case err != nil:
+				t.Fatalf("unexpected error applying three-way: %v", err)
+			}
+
+			if test.Conflicts > 0 {
+				var mc *MergeConflict
+				if !errors.As(err, &mc) || mc.Conflicts != test.Conflicts {
+					t.Errorf("incorrect merge conflict: expected %d conflicts, actual %v", test.Conflicts, err)
+				}
+			}
+			if dst.String() != test.Result {
+				t.Errorf("incorrect result\nexpected: %q\n  actual: %q", test.Result, dst.String())
+			}
+		})
+	}
+}
+
+func TestMatchLines(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	randomLines := func(n, alphabet int) []string {
+		lines := make([]string, n)
+		for i := range lines {
+			lines[i] = strconv.Itoa(rng.Intn(alphabet))
+		}
+		return lines
+	}
+
+	for i := 0; i < 500; i++ {
+		a := randomLines(rng.Intn(40), 1+rng.Intn(6))
+		b := randomLines(rng.Intn(40), 1+rng.Intn(6))
+
+		match := matchLines(a, b)
+
+		count, last := 0, -1
+		for i, j := range match {
+			if j < 0 {
+				continue
+			}
+			if j <= last || a[i] != b[j] {
+				t.Fatalf("invalid match %d -> %d\na: %q\nb: %q", i, j, a, b)
+			}
+			count, last = count+1, j
+		}
+		if expected := lcsLength(a, b); count != expected {
+			t.Fatalf("incorrect match length: expected %d, actual %d\na: %q\nb: %q", expected, count, a, b)
+		}
+	}
+}
+
+func TestMatchLinesMemory(t *testing.T) {
+	const n = 4000
+
+	a, b := make([]string, n), make([]string, n)
+	for i := 0; i < n; i++ {
+		a[i] = "a" + strconv.Itoa(i)
+		b[i] = "b" + strconv.Itoa(i)
+	}
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	match := matchLines(a, b)
+	runtime.ReadMemStats(&after)
+
+	for i, j := range match {
+		if j >= 0 {
+			t.Fatalf("unexpected match %d -> %d", i, j)
+		}
+	}
+	if alloc := after.TotalAlloc - before.TotalAlloc; alloc > 1<<20 {
+		t.Errorf("matching %d disjoint lines allocated %d bytes", n, alloc)
+	}
+}
+
+// lcsLength returns the length of a longest common subsequence of a and b.
+func lcsLength(a, b []string) int {
+	prev, cur := make([]int, len(b)+1), make([]int, len(b)+1)
+	for i := range a {
+		for j := range b {
+			switch {
+			case a[i] == b[j]:
+				cur[j+1] = prev[j] + 1
+			case prev[j+1] > cur[j]:
+				cur[j+1] = prev[j+1]
+			default:
+				cur[j+1] = cur[j]
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
@@ -1,7 +1,9 @@
 package gitdiff
 
 import (
+	"errors"
 	"fmt"
+	"io"
 )
 
 var (
@@ -89,3 +91,152 @@ func base85Encode(dst, src []byte) {
 func base85Len(n int) int {
 	return (n + 3) / 4 * 5
 }
+
+// NewBase85Reader returns a reader that decodes Base85-encoded data from r,
+// using the same alphabet as Git. n is the length of the decoded data, which
+// Git stores separately because the encoding pads data to a multiple of four
+// bytes. The reader decodes data incrementally and returns an error if r
+// contains an invalid byte, ends with a partial group, or contains fewer than
+// n bytes of encoded data.
+func NewBase85Reader(r io.Reader, n int64) io.Reader {
+	return &base85Reader{r: r, rem: n, size: n}
+}
+
+type base85Reader struct {
+	r   io.Reader
+	err error
+
+	size, rem int64
+	index     int64
+
+	v   uint32
+	n   int
+	in  [byteBufferSize]byte
+	out []byte
+}
+
+func (r *base85Reader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 && r.err == nil {
+		r.fill()
+	}
+	if len(r.out) > 0 {
+		n := copy(p, r.out)
+		r.out = r.out[n:]
+		return n, nil
+	}
+	return 0, r.err
+}
+
+// fill reads encoded data from the underlying reader and decodes it into the
+// output buffer, setting err at the end of the data.
+func (r *base85Reader) fill() {
+	nr, err := r.r.Read(r.in[:])
+
+	out := r.out[:0]
+	for i, c := range r.in[:nr] {
+		b, ok := b85Table[c]
+		if !ok {
+			r.err = fmt.Errorf("invalid base85 byte at index %d: 0x%X", r.index+int64(i), c)
+			break
+		}
+		r.v = 85*r.v + uint32(b)
+		r.n++
+
+		if r.n == 5 {
+			for j := 0; j < 4 && r.rem > 0; j++ {
+				out = append(out, byte(r.v>>24))
+				r.v <<= 8
+				r.rem--
+			}
+			r.v = 0
+			r.n = 0
+		}
+	}
+	r.index += int64(nr)
+	r.out = out
+
+	switch {
+	case r.err != nil:
+	case err == io.EOF:
+		switch {
+		case r.n > 0:
+			r.err = errors.New("base85 data terminated by underpadded sequence")
+		case r.rem > 0:
+			r.err = fmt.Errorf("base85 data underrun: %d < %d", r.size-r.rem, r.size)
+		default:
+			r.err = io.EOF
+		}
+	case err != nil:
+		r.err = err
+	}
+}
+
+// NewBase85Writer returns a writer that encodes data in Base85 and writes the
+// result to w, using the same alphabet as Git. Data is encoded in groups of
+// four bytes, so the writer must be closed to write a final partial group.
+// Closing the writer does not close w.
+func NewBase85Writer(w io.Writer) io.WriteCloser {
+	return &base85Writer{w: w}
+}
+
+type base85Writer struct {
+	w   io.Writer
+	err error
+
+	buf  [4]byte
+	nbuf int
+	out  [byteBufferSize / 4 * 5]byte
+}
+
+func (w *base85Writer) Write(p []byte) (n int, err error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	// complete a partial group from a previous write
+	if w.nbuf > 0 {
+		c := copy(w.buf[w.nbuf:], p)
+		w.nbuf += c
+		n += c
+		p = p[c:]
+		if w.nbuf < len(w.buf) {
+			return n, nil
+		}
+		if err := w.encode(w.buf[:]); err != nil {
+			return n, err
+		}
+		w.nbuf = 0
+	}
+
+	for len(p) >= len(w.buf) {
+		c := min(len(p), byteBufferSize) / 4 * 4
+		if err := w.encode(p[:c]); err != nil {
+			return n, err
+		}
+		n += c
+		p = p[c:]
+	}
+
+	w.nbuf = copy(w.buf[:], p)
+	n += w.nbuf
+	return n, nil
+}
+
+// Close writes any buffered data as a final padded group.
+func (w *base85Writer) Close() error {
+	if w.err != nil || w.nbuf == 0 {
+		return w.err
+	}
+	err := w.encode(w.buf[:w.nbuf])
+	w.nbuf = 0
+	return err
+}
+
+func (w *base85Writer) encode(src []byte) error {
+	dst := w.out[:base85Len(len(src))]
+	base85Encode(dst, src)
+	if _, err := w.w.Write(dst); err != nil {
+		w.err = err
+	}
+	return w.err
+}
@@ -2,7 +2,10 @@ package gitdiff
 
 import (
 	"bytes"
+	"io"
+	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 func TestBase85Decode(t *testing.T) {
@@ -96,6 +99,94 @@ func TestBase85Encode(t *testing.T) {
 	}
 }
 
+func TestBase85Reader(t *testing.T) {
+	tests := map[string]struct {
+		Input  string
+		Size   int64
+		Output []byte
+		Err    string
+	}{
+		"sixBytes": {
+			Input:  "007GV%KiWV",
+			Size:   6,
+			Output: []byte{0x0, 0x0, 0xCA, 0xFE, 0xCA, 0xFE},
+		},
+		"empty": {
+			Input:  "",
+			Size:   0,
+			Output: []byte{},
+		},
+		"invalidCharacter": {
+			Input: "007GV00'GV",
+			Size:  8,
+			Err:   "invalid base85 byte at index 7: 0x27",
+		},
+		"underpaddedSequence": {
+			Input: "007GV007G",
+			Size:  8,
+			Err:   "underpadded sequence",
+		},
+		"dataUnderrun": {
+			Input: "007GV",
+			Size:  8,
+			Err:   "base85 data underrun: 4 < 8",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			// decode one byte at a time to test state across reads
+			r := NewBase85Reader(iotest.OneByteReader(strings.NewReader(test.Input)), test.Size)
+
+			out, err := io.ReadAll(r)
+			if test.Err != "" {
+				assertError(t, test.Err, err, "decoding base85 data")
+
+				dst := make([]byte, test.Size)
+				if berr := base85Decode(dst, []byte(test.Input)); berr == nil || berr.Error() != err.Error() {
+					t.Errorf("stream error does not match batch error: %v != %v", err, berr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error decoding base85 data: %v", err)
+			}
+			if !bytes.Equal(test.Output, out) {
+				t.Errorf("incorrect output: expected %x, actual %x", test.Output, out)
+			}
+		})
+	}
+}
+
+func TestBase85Writer(t *testing.T) {
+	input := make([]byte, 3*byteBufferSize+7)
+	for i := range input {
+		input[i] = byte(i * 31)
+	}
+
+	expected := make([]byte, base85Len(len(input)))
+	base85Encode(expected, input)
+
+	for _, size := range []int{1, 3, 4, 5, byteBufferSize + 1, len(input)} {
+		var b bytes.Buffer
+		w := NewBase85Writer(&b)
+		for in := input; len(in) > 0; {
+			n, err := w.Write(in[:min(size, len(in))])
+			if err != nil {
+				t.Fatalf("unexpected error writing base85 data: %v", err)
+			}
+			in = in[n:]
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("unexpected error closing writer: %v", err)
+		}
+
+		if !bytes.Equal(expected, b.Bytes()) {
+			t.Errorf("incorrect output writing %d bytes at a time", size)
+		}
+	}
+}
+
 func FuzzBase85Roundtrip(f *testing.F) {
 	f.Add([]byte{0x2b, 0x0d})
 	f.Add([]byte{0xbc, 0xb4, 0x3f})
@@ -114,5 +205,25 @@ func FuzzBase85Roundtrip(f *testing.F) {
 		if !bytes.Equal(in, out) {
 			t.Errorf("decoded data differed from input data:\n   input: %x\n  output: %x\nencoding: %s\n", in, out, string(dst))
 		}
+
+		var b bytes.Buffer
+		w := NewBase85Writer(&b)
+		if _, err := w.Write(in); err != nil {
+			t.Fatalf("unexpected error writing base85 data: %v", err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("unexpected error closing base85 writer: %v", err)
+		}
+		if !bytes.Equal(dst, b.Bytes()) {
+			t.Errorf("streaming encoding differed from batch encoding:\n   batch: %s\n  stream: %s\n", dst, b.Bytes())
+		}
+
+		out, err := io.ReadAll(NewBase85Reader(&b, int64(n)))
+		if err != nil {
+			t.Fatalf("unexpected error reading base85 data: %v", err)
+		}
+		if !bytes.Equal(in, out) {
+			t.Errorf("streaming decoded data differed from input data:\n   input: %x\n  output: %x\n", in, out)
+		}
 	})
 }
@@ -3,6 +3,7 @@ package gitdiff
 import (
 	"bytes"
 	"compress/zlib"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -11,6 +12,8 @@ import (
 )
 
 func (p *parser) ParseBinaryFragments(f *File) (n int, err error) {
+	marker := p.Line(0)
+
 	isBinary, hasData, err := p.ParseBinaryMarker()
 	if err != nil || !isBinary {
 		return 0, err
@@ -18,15 +21,29 @@ func (p *parser) ParseBinaryFragments(f *File) (n int, err error) {
 
 	f.IsBinary = true
 	if !hasData {
+		if f.OldName == "" && f.NewName == "" {
+			setBinaryMarkerNames(f, marker, p.gitStripLevel())
+			p.transformNames(f)
+		}
 		return 0, nil
 	}
 
+	// Fragments are identified by position, not content: the first is always
+	// the forward fragment and the second is always the reverse fragment. An
+	// empty line in place of the first fragment means only the reverse exists.
+	if p.Line(0) == "\n" {
+		if err := p.Next(); err != nil && err != io.EOF {
+			return 0, err
+		}
+		return p.parseReverseOnlyBinaryFragment(f)
+	}
+
 	forward, err := p.ParseBinaryFragmentHeader()
 	if err != nil {
 		return 0, err
 	}
 	if forward == nil {
-		return 0, p.Errorf(0, "missing data for binary patch")
+		return 0, p.binaryFormatError(BinaryFormatHeader, errors.New("missing literal or delta line"))
 	}
 	if err := p.ParseBinaryChunk(forward); err != nil {
 		return 0, err
@@ -49,6 +66,21 @@ func (p *parser) ParseBinaryFragments(f *File) (n int, err error) {
 	return 1, nil
 }
 
+func (p *parser) parseReverseOnlyBinaryFragment(f *File) (n int, err error) {
+	reverse, err := p.ParseBinaryFragmentHeader()
+	if err != nil {
+		return 0, err
+	}
+	if reverse == nil {
+		return 0, p.binaryFormatError(BinaryFormatHeader, errors.New("missing literal or delta line for reverse fragment"))
+	}
+	if err := p.ParseBinaryChunk(reverse); err != nil {
+		return 0, err
+	}
+	f.ReverseBinaryFragment = reverse
+	return 1, nil
+}
+
 func (p *parser) ParseBinaryMarker() (isBinary bool, hasData bool, err error) {
 	line := p.Line(0)
 	switch {
@@ -72,6 +104,89 @@ func isBinaryNoDataMarker(line string) bool {
 	return false
 }
 
+// ParseTraditionalBinaryFileHeader parses a "Binary files A and B differ" line
+// that is not preceded by a file header, as generated by `diff` for binary
+// files. The line is the only source of the file names, so it acts as both
+// the header and the binary marker for the file.
+func (p *parser) ParseTraditionalBinaryFileHeader() (*File, error) {
+	oldName, newName, ok := parseBinaryMarkerNames(p.Line(0), p.traditionalStripLevel())
+	if !ok {
+		return nil, nil
+	}
+
+	if err := p.Next(); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	f := newTraditionalFile("", oldName, "", newName)
+	f.IsBinary = true
+	return f, nil
+}
+
+// setBinaryMarkerNames sets the names of f from a binary marker line if they
+// were not set by the file header.
+func setBinaryMarkerNames(f *File, line string, strip int) {
+	if f.OldName != "" || f.NewName != "" {
+		return
+	}
+
+	oldName, newName, ok := parseBinaryMarkerNames(line, strip)
+	if !ok {
+		return
+	}
+	if oldName != devNull && !f.IsNew {
+		f.OldName = oldName
+	}
+	if newName != devNull && !f.IsDelete {
+		f.NewName = newName
+	}
+}
+
+// parseBinaryMarkerNames parses the names from a "Binary files A and B differ"
+// line. Because names may contain the separator, it considers every possible
+// split and prefers one where the names are equal after stripping prefixes.
+// It returns false if the line has no names or they cannot be determined.
+func parseBinaryMarkerNames(line string, strip int) (oldName, newName string, ok bool) {
+	const (
+		prefix    = "Binary files "
+		separator = " and "
+		suffix    = " differ\n"
+	)
+
+	if len(line) < len(prefix)+len(suffix) || !strings.HasPrefix(line, prefix) || !strings.HasSuffix(line, suffix) {
+		return "", "", false
+	}
+	names := line[len(prefix) : len(line)-len(suffix)]
+
+	parseWhole := func(s string) (string, bool) {
+		name, n, err := parseName(s, 0, strip)
+		return name, err == nil && n == len(s)
+	}
+
+	matches := 0
+	for i := strings.Index(names, separator); i >= 0; {
+		o, oldOK := parseWhole(names[:i])
+		n, newOK := parseWhole(names[i+len(separator):])
+		if oldOK && newOK {
+			if o == n || o == devNull || n == devNull {
+				return o, n, true
+			}
+			oldName, newName = o, n
+			matches++
+		}
+
+		next := strings.Index(names[i+1:], separator)
+		if next < 0 {
+			break
+		}
+		i += next + 1
+	}
+	if matches != 1 {
+		return "", "", false
+	}
+	return oldName, newName, true
+}
+
 func (p *parser) ParseBinaryFragmentHeader() (*BinaryFragment, error) {
 	parts := strings.SplitN(strings.TrimSuffix(p.Line(0), "\n"), " ", 2)
 	if len(parts) < 2 {
@@ -91,7 +206,10 @@ func (p *parser) ParseBinaryFragmentHeader() (*BinaryFragment, error) {
 	var err error
 	if frag.Size, err = strconv.ParseInt(parts[1], 10, 64); err != nil {
 		nerr := err.(*strconv.NumError)
-		return nil, p.Errorf(0, "binary patch: invalid size: %v", nerr.Err)
+		return nil, p.binaryFormatError(BinaryFormatHeader, fmt.Errorf("invalid size: %v", nerr.Err))
+	}
+	if max := p.opts.maxFileSize; max > 0 && frag.Size > max {
+		return nil, &LimitError{Limit: "file size", Max: max, Line: p.lineno}
 	}
 
 	if err := p.Next(); err != nil && err != io.EOF {
@@ -121,7 +239,7 @@ func (p *parser) ParseBinaryChunk(frag *BinaryFragment) error {
 			break
 		}
 		if len(line) < len(shortestValidLine) || (len(line)-2)%5 != 0 {
-			return p.Errorf(0, "binary patch: corrupt data line")
+			return p.binaryFormatError(BinaryFormatDecode, errors.New("corrupt data line"))
 		}
 
 		byteCount, seq := int(line[0]), line[1:len(line)-1]
@@ -131,30 +249,30 @@ func (p *parser) ParseBinaryChunk(frag *BinaryFragment) error {
 		case 'a' <= byteCount && byteCount <= 'z':
 			byteCount = byteCount - 'a' + 27
 		default:
-			return p.Errorf(0, "binary patch: invalid length byte")
+			return p.binaryFormatError(BinaryFormatDecode, errors.New("invalid length byte"))
 		}
 
 		// base85 encodes every 4 bytes into 5 characters, with up to 3 bytes of end padding
 		maxByteCount := len(seq) / 5 * 4
 		if byteCount > maxByteCount || byteCount < maxByteCount-3 {
-			return p.Errorf(0, "binary patch: incorrect byte count")
+			return p.binaryFormatError(BinaryFormatDecode, errors.New("incorrect byte count"))
 		}
 
 		if err := base85Decode(buf[:byteCount], []byte(seq)); err != nil {
-			return p.Errorf(0, "binary patch: %v", err)
+			return p.binaryFormatError(BinaryFormatDecode, err)
 		}
 		data.Write(buf[:byteCount])
 
 		if err := p.Next(); err != nil {
 			if err == io.EOF {
-				return p.Errorf(0, "binary patch: unexpected EOF")
+				return p.binaryFormatError(BinaryFormatTruncated, errors.New("missing terminating blank line"))
 			}
 			return err
 		}
 	}
 
-	if err := inflateBinaryChunk(frag, &data); err != nil {
-		return p.Errorf(0, "binary patch: %v", err)
+	if kind, err := inflateBinaryChunk(p.opts.compressor, frag, &data, p.opts.maxFileSize > 0); err != nil {
+		return p.binaryFormatError(kind, err)
 	}
 
 	// consume the empty line that ended the fragment
@@ -164,23 +282,131 @@ func (p *parser) ParseBinaryChunk(frag *BinaryFragment) error {
 	return nil
 }
 
-func inflateBinaryChunk(frag *BinaryFragment, r io.Reader) error {
-	zr, err := zlib.NewReader(r)
+// Compressor compresses and decompresses the data in binary patches, which
+// Git stores in the zlib format. The default implementation uses the
+// compress/zlib package. A custom implementation can produce the exact bytes
+// that Git produces, which Go's implementation does not.
+type Compressor interface {
+	// NewReader returns a reader that decompresses data read from r.
+	NewReader(r io.Reader) (io.ReadCloser, error)
+
+	// NewWriter returns a writer that compresses data written to it and
+	// writes the result to w. Closing the writer must flush any buffered data
+	// but must not close w.
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+}
+
+// zlibCompressor is a Compressor that uses the compress/zlib package. Writers
+// compress data using level.
+type zlibCompressor struct {
+	level int
+}
+
+var defaultCompressor Compressor = zlibCompressor{level: zlib.DefaultCompression}
+
+func (zlibCompressor) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return zlib.NewReader(r)
+}
+
+func (c zlibCompressor) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return zlib.NewWriterLevel(w, c.level)
+}
+
+// inflateBinaryChunk decompresses the data in r and stores it in frag. If limit
+// is true, it stops decompressing after reading more than frag.Size bytes. If
+// decompression fails, it also returns the kind of the failure.
+func inflateBinaryChunk(c Compressor, frag *BinaryFragment, r io.Reader, limit bool) (BinaryFormatKind, error) {
+	if c == nil {
+		c = defaultCompressor
+	}
+
+	zr, err := c.NewReader(r)
 	if err != nil {
-		return err
+		return BinaryFormatCompression, err
+	}
+
+	var src io.Reader = zr
+	if limit {
+		// read at most one extra byte to detect data that is too large
+		// without inflating all of it
+		src = io.LimitReader(zr, frag.Size+1)
 	}
 
-	data, err := ioutil.ReadAll(zr)
+	data, err := ioutil.ReadAll(src)
 	if err != nil {
-		return err
+		return BinaryFormatCompression, err
+	}
+	if limit && int64(len(data)) > frag.Size {
+		return BinaryFormatSize, fmt.Errorf("%d byte fragment inflated to more than %d bytes", frag.Size, frag.Size)
 	}
 	if err := zr.Close(); err != nil {
-		return err
+		return BinaryFormatCompression, err
 	}
 
 	if int64(len(data)) != frag.Size {
-		return fmt.Errorf("%d byte fragment inflated to %d", frag.Size, len(data))
+		return BinaryFormatSize, fmt.Errorf("%d byte fragment inflated to %d", frag.Size, len(data))
 	}
 	frag.Data = data
-	return nil
+	return 0, nil
+}
+
+// BinaryFormatKind identifies the stage of parsing a binary fragment that
+// failed.
+type BinaryFormatKind int
+
+const (
+	// BinaryFormatDecode indicates a data line is malformed or is not valid
+	// base85
+	BinaryFormatDecode BinaryFormatKind = iota
+	// BinaryFormatCompression indicates the decoded data is not valid
+	// compressed data, which may mean it uses an unsupported encoding
+	BinaryFormatCompression
+	// BinaryFormatSize indicates the decompressed data does not match the
+	// size in the fragment header
+	BinaryFormatSize
+	// BinaryFormatHeader indicates the "literal" or "delta" line that starts
+	// a fragment is missing or has an invalid size
+	BinaryFormatHeader
+	// BinaryFormatTruncated indicates the input ends before the blank line
+	// that terminates a fragment
+	BinaryFormatTruncated
+)
+
+func (k BinaryFormatKind) String() string {
+	switch k {
+	case BinaryFormatDecode:
+		return "decode"
+	case BinaryFormatCompression:
+		return "compression"
+	case BinaryFormatSize:
+		return "size"
+	case BinaryFormatHeader:
+		return "header"
+	case BinaryFormatTruncated:
+		return "truncated"
+	}
+	return "unknown"
+}
+
+// BinaryFormatError is returned when the data of a binary fragment is
+// invalid. Kind identifies the stage of parsing that failed, which can
+// distinguish corrupt data from data in an unsupported encoding.
+type BinaryFormatError struct {
+	// Line is the one-indexed line number in the input where the error was
+	// detected
+	Line int64
+	Kind BinaryFormatKind
+	Err  error
+}
+
+func (e *BinaryFormatError) Unwrap() error {
+	return e.Err
+}
+
+func (e *BinaryFormatError) Error() string {
+	return fmt.Sprintf("gitdiff: line %d: binary patch: %v", e.Line, e.Err)
+}
+
+func (p *parser) binaryFormatError(kind BinaryFormatKind, err error) error {
+	return &BinaryFormatError{Line: p.lineno, Kind: kind, Err: err}
 }
@@ -2,7 +2,9 @@ package gitdiff
 
 import (
 	"encoding/binary"
+	"errors"
 	"io"
+	"os"
 	"reflect"
 	"strings"
 	"testing"
@@ -66,6 +68,76 @@ func TestParseBinaryMarker(t *testing.T) {
 	}
 }
 
+func TestParseBinaryMarkerNames(t *testing.T) {
+	tests := map[string]struct {
+		Input   string
+		Strip   int
+		OldName string
+		NewName string
+		OK      bool
+	}{
+		"noNames": {
+			Input: "Binary files differ\n",
+		},
+		"sameName": {
+			Input:   "Binary files a/foo.bin and b/foo.bin differ\n",
+			Strip:   1,
+			OldName: "foo.bin",
+			NewName: "foo.bin",
+			OK:      true,
+		},
+		"noStrip": {
+			Input:   "Binary files old/foo.bin and new/foo.bin differ\n",
+			OldName: "old/foo.bin",
+			NewName: "new/foo.bin",
+			OK:      true,
+		},
+		"newFile": {
+			Input:   "Binary files /dev/null and b/foo.bin differ\n",
+			Strip:   1,
+			OldName: "/dev/null",
+			NewName: "foo.bin",
+			OK:      true,
+		},
+		"separatorInName": {
+			Input:   "Binary files a/this and that.bin and b/this and that.bin differ\n",
+			Strip:   1,
+			OldName: "this and that.bin",
+			NewName: "this and that.bin",
+			OK:      true,
+		},
+		"ambiguous": {
+			Input: "Binary files a/x and y and b/z differ\n",
+			Strip: 1,
+		},
+		"quoted": {
+			Input:   `Binary files "a/sp\303\251cial.bin" and "b/sp\303\251cial.bin" differ` + "\n",
+			Strip:   1,
+			OldName: "sp\u00e9cial.bin",
+			NewName: "sp\u00e9cial.bin",
+			OK:      true,
+		},
+		"filesDiffer": {
+			Input: "Files a/foo.bin and b/foo.bin differ\n",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			oldName, newName, ok := parseBinaryMarkerNames(test.Input, test.Strip)
+			if test.OK != ok {
+				t.Fatalf("incorrect ok value: expected %t, actual %t", test.OK, ok)
+			}
+			if test.OldName != oldName {
+				t.Errorf("incorrect old name: expected %q, actual %q", test.OldName, oldName)
+			}
+			if test.NewName != newName {
+				t.Errorf("incorrect new name: expected %q, actual %q", test.NewName, newName)
+			}
+		})
+	}
+}
+
 func TestParseBinaryFragmentHeader(t *testing.T) {
 	tests := map[string]struct {
 		Input  string
@@ -127,6 +199,7 @@ func TestParseBinaryChunk(t *testing.T) {
 		Fragment BinaryFragment
 		Output   []byte
 		Err      string
+		Kind     string
 	}{
 		"singleline": {
 			Input: "TcmZQzU|?i`U?w2V48*Je09XJG\n\n",
@@ -147,37 +220,45 @@ func TestParseBinaryChunk(t *testing.T) {
 		"shortLine": {
 			Input: "A00\n\n",
 			Err:   "corrupt data line",
+			Kind:  "decode",
 		},
 		"underpaddedLine": {
 			Input: "H00000000\n\n",
 			Err:   "corrupt data line",
+			Kind:  "decode",
 		},
 		"invalidLengthByte": {
 			Input: "!00000\n\n",
 			Err:   "invalid length byte",
+			Kind:  "decode",
 		},
 		"miscountedLine": {
 			Input: "H00000\n\n",
 			Err:   "incorrect byte count",
+			Kind:  "decode",
 		},
 		"invalidEncoding": {
 			Input: "TcmZQzU|?i'U?w2V48*Je09XJG\n",
 			Err:   "invalid base85 byte",
+			Kind:  "decode",
 		},
 		"noTrailingEmptyLine": {
 			Input: "TcmZQzU|?i`U?w2V48*Je09XJG\n",
-			Err:   "unexpected EOF",
+			Err:   "missing terminating blank line",
+			Kind:  "truncated",
 		},
 		"invalidCompression": {
 			Input: "F007GV%KiWV\n\n",
 			Err:   "zlib",
+			Kind:  "compression",
 		},
 		"incorrectSize": {
 			Input: "TcmZQzU|?i`U?w2V48*Je09XJG\n\n",
 			Fragment: BinaryFragment{
 				Size: 16,
 			},
-			Err: "16 byte fragment inflated to 20",
+			Err:  "16 byte fragment inflated to 20",
+			Kind: "size",
 		},
 	}
 
@@ -191,6 +272,15 @@ func TestParseBinaryChunk(t *testing.T) {
 				if err == nil || !strings.Contains(err.Error(), test.Err) {
 					t.Fatalf("expected error containing %q parsing binary chunk, but got %v", test.Err, err)
 				}
+
+				var ferr *BinaryFormatError
+				if errors.As(err, &ferr) {
+					if ferr.Kind.String() != test.Kind {
+						t.Errorf("incorrect error kind: expected %q, actual %q", test.Kind, ferr.Kind)
+					}
+				} else if test.Kind != "" {
+					t.Errorf("expected *BinaryFormatError, but got %T", err)
+				}
 				return
 			}
 			if err != nil {
@@ -203,6 +293,48 @@ func TestParseBinaryChunk(t *testing.T) {
 	}
 }
 
+func TestParseBinaryTruncated(t *testing.T) {
+	tests := map[string]struct {
+		InputFile string
+		Err       string
+		Kind      BinaryFormatKind
+	}{
+		"missingTerminatingLine": {
+			InputFile: "testdata/binary_truncated.patch",
+			Err:       "gitdiff: line 16: binary patch: missing terminating blank line",
+			Kind:      BinaryFormatTruncated,
+		},
+		"missingSizeLine": {
+			InputFile: "testdata/binary_no_size.patch",
+			Err:       "gitdiff: line 4: binary patch: missing literal or delta line",
+			Kind:      BinaryFormatHeader,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			f, err := os.Open(test.InputFile)
+			if err != nil {
+				t.Fatalf("unexpected error opening input file: %v", err)
+			}
+			defer f.Close()
+
+			_, _, err = Parse(f)
+			if err == nil || err.Error() != test.Err {
+				t.Fatalf("incorrect error\nexpected: %s\n  actual: %v", test.Err, err)
+			}
+
+			var ferr *BinaryFormatError
+			if !errors.As(err, &ferr) {
+				t.Fatalf("expected *BinaryFormatError, but got %T", err)
+			}
+			if ferr.Kind != test.Kind {
+				t.Errorf("incorrect error kind: expected %v, actual %v", test.Kind, ferr.Kind)
+			}
+		})
+	}
+}
+
 func TestParseBinaryFragments(t *testing.T) {
 	tests := map[string]struct {
 		Input string
@@ -247,6 +379,24 @@ gcmZQzU|?i` + "`" + `U?w2V48*KJ%mKu_Kr9NxN<eH500b)lkN^Mx
 				Data:   fib(10, binary.BigEndian),
 			},
 		},
+		"reverseOnly": {
+			Input: `GIT binary patch
+
+literal 40
+gcmZQzU|?i` + "`" + `U?w2V48*KJ%mKu_Kr9NxN<eH500b)lkN^Mx
+
+`,
+			Binary: true,
+			ReverseFragment: &BinaryFragment{
+				Method: BinaryPatchLiteral,
+				Size:   40,
+				Data:   fib(10, binary.BigEndian),
+			},
+		},
+		"reverseOnlyMissingData": {
+			Input: "GIT binary patch\n\n",
+			Err:   true,
+		},
 		"noData": {
 			Input:  "Binary files differ\n",
 			Binary: true,
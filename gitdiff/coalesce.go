@@ -0,0 +1,117 @@
+package gitdiff
+
+import (
+	"errors"
+	"fmt"
+)
+
+// CoalesceFragments merges text fragments in f whose ranges in the old file
+// overlap or are adjacent, like Git does when it creates a patch. This is
+// useful before formatting fragments that were created or modified by hand.
+// The fragments must be sorted by position and overlapping lines must be
+// context lines with the same content in both fragments. Merged fragments
+// keep the comment of the first fragment and have new line counts.
+//
+// If the fragments cannot be merged or a merged fragment is invalid,
+// CoalesceFragments returns an error and does not modify f. If the fragments
+// are already disjoint, it does nothing.
+func (f *File) CoalesceFragments() error {
+	if len(f.TextFragments) < 2 {
+		return nil
+	}
+
+	frags := make([]*TextFragment, 0, len(f.TextFragments))
+	merged := false
+
+	cur := f.TextFragments[0]
+	for i, next := range f.TextFragments[1:] {
+		if len(cur.ParentRanges) > 0 || len(next.ParentRanges) > 0 {
+			return errors.New("cannot coalesce fragments from a combined diff")
+		}
+
+		start, end := oldRange(cur)
+		nextStart, _ := oldRange(next)
+		if nextStart < start {
+			return fmt.Errorf("fragment %d starts before the previous fragment", i+2)
+		}
+		if nextStart > end {
+			frags = append(frags, cur)
+			cur = next
+			continue
+		}
+
+		m, err := coalesceFragments(cur, next, end-nextStart)
+		if err != nil {
+			return fmt.Errorf("fragment %d: %w", i+2, err)
+		}
+		cur = m
+		merged = true
+	}
+	frags = append(frags, cur)
+
+	if merged {
+		f.TextFragments = frags
+	}
+	return nil
+}
+
+// coalesceFragments merges b into a, where the first n old lines of b are the
+// last n old lines of a. The overlapping lines must be context lines.
+func coalesceFragments(a, b *TextFragment, n int64) (*TextFragment, error) {
+	if n > int64(len(a.Lines)) || n > int64(len(b.Lines)) {
+		return nil, errors.New("overlapping fragments change the same lines")
+	}
+
+	oldStart, oldEnd := oldRange(a)
+
+	overlap := a.Lines[int64(len(a.Lines))-n:]
+	for i, line := range overlap {
+		if line.Op != OpContext || b.Lines[i].Op != OpContext {
+			return nil, errors.New("overlapping fragments change the same lines")
+		}
+		if line.Line != b.Lines[i].Line {
+			return nil, fmt.Errorf("overlapping fragments have different content for old line %d", oldEnd-n+int64(i))
+		}
+	}
+
+	newStart := a.NewPosition
+	if a.NewLines == 0 {
+		newStart++
+	}
+
+	m := &TextFragment{
+		Comment:     a.Comment,
+		OldPosition: oldStart,
+		NewPosition: newStart,
+		Lines:       make([]Line, 0, int64(len(a.Lines)+len(b.Lines))-n),
+	}
+	m.Lines = append(m.Lines, a.Lines...)
+	m.Lines = append(m.Lines, b.Lines[n:]...)
+
+	setLineCounts(m)
+
+	// empty ranges refer to the line before the change
+	if m.OldLines == 0 {
+		m.OldPosition--
+	}
+	if m.NewLines == 0 {
+		m.NewPosition--
+	}
+	setLineNumbers(m)
+
+	if err := m.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid merged fragment: %w", err)
+	}
+	return m, nil
+}
+
+// oldRange returns the one-indexed range of lines [start, end) in the old
+// file covered by the fragment.
+func oldRange(f *TextFragment) (start, end int64) {
+	start = f.OldPosition
+	if f.OldLines == 0 {
+		// an empty range refers to the line before the change
+		start++
+	}
+	return start, start + f.OldLines
+}
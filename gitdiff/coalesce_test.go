@@ -0,0 +1,204 @@
+package gitdiff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFileCoalesceFragments(t *testing.T) {
+	const header = "diff --git a/file.txt b/file.txt\n" +
+		"--- a/file.txt\n" +
+		"+++ b/file.txt\n"
+
+	tests := map[string]struct {
+		Input  string
+		Output string
+		Err    string
+	}{
+		"disjoint": {
+			Input: `@@ -1,3 +1,3 @@
+ line 1
+-line 2
++line 2 changed
+ line 3
+@@ -8,3 +8,3 @@
+ line 8
+-line 9
++line 9 changed
+ line 10
+`,
+		},
+		"adjacent": {
+			Input: `@@ -1,3 +1,3 @@ first
+ line 1
+-line 2
++line 2 changed
+ line 3
+@@ -4,3 +4,4 @@ second
+ line 4
++line 4.5
+-line 5
++line 5 changed
+ line 6
+`,
+			Output: `@@ -1,6 +1,7 @@ first
+ line 1
+-line 2
++line 2 changed
+ line 3
+ line 4
++line 4.5
+-line 5
++line 5 changed
+ line 6
+`,
+		},
+		"overlapping": {
+			Input: `@@ -1,4 +1,4 @@
+ line 1
+-line 2
++line 2 changed
+ line 3
+ line 4
+@@ -3,4 +3,3 @@
+ line 3
+ line 4
+-line 5
+ line 6
+`,
+			Output: `@@ -1,6 +1,5 @@
+ line 1
+-line 2
++line 2 changed
+ line 3
+ line 4
+-line 5
+ line 6
+`,
+		},
+		"insertionAfterFragment": {
+			Input: `@@ -1,2 +1,2 @@
+-line 1
++line 1 changed
+ line 2
+@@ -2,0 +3,1 @@
++line 2.5
+`,
+			Output: `@@ -1,2 +1,3 @@
+-line 1
++line 1 changed
+ line 2
++line 2.5
+`,
+		},
+		"threeFragments": {
+			Input: `@@ -1,2 +1,2 @@
+-line 1
++line 1 changed
+ line 2
+@@ -3,2 +3,2 @@
+-line 3
++line 3 changed
+ line 4
+@@ -4,2 +4,2 @@
+ line 4
+-line 5
++line 5 changed
+`,
+			Output: `@@ -1,5 +1,5 @@
+-line 1
++line 1 changed
+ line 2
+-line 3
++line 3 changed
+ line 4
+-line 5
++line 5 changed
+`,
+		},
+		"overlappingChanges": {
+			Input: `@@ -1,3 +1,3 @@
+ line 1
+-line 2
++line 2 changed
+ line 3
+@@ -2,2 +2,2 @@
+-line 2
++line 2 changed again
+ line 3
+`,
+			Err: "change the same lines",
+		},
+		"overlappingDifferentContext": {
+			Input: `@@ -1,3 +1,3 @@
+ line 1
+-line 2
++line 2 changed
+ line 3
+@@ -3,2 +3,2 @@
+ line three
+-line 4
++line 4 changed
+`,
+			Err: "different content for old line 3",
+		},
+		"outOfOrder": {
+			Input: `@@ -8,3 +8,3 @@
+ line 8
+-line 9
++line 9 changed
+ line 10
+@@ -1,3 +1,3 @@
+ line 1
+-line 2
++line 2 changed
+ line 3
+`,
+			Err: "starts before the previous fragment",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			f := assertParseSingleFile(t, []byte(header+test.Input), "patch")
+			frags := f.TextFragments
+
+			err := f.CoalesceFragments()
+			if test.Err != "" {
+				assertError(t, test.Err, err, "coalescing fragments")
+				if len(f.TextFragments) != len(frags) {
+					t.Errorf("fragments were modified after error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error coalescing fragments: %v", err)
+			}
+
+			if test.Output == "" {
+				if len(f.TextFragments) != len(frags) {
+					t.Fatalf("incorrect number of fragments: expected %d, actual %d", len(frags), len(f.TextFragments))
+				}
+				for i := range frags {
+					if f.TextFragments[i] != frags[i] {
+						t.Errorf("fragment %d was modified", i+1)
+					}
+				}
+				return
+			}
+
+			if len(f.TextFragments) != 1 {
+				t.Fatalf("incorrect number of fragments: expected 1, actual %d", len(f.TextFragments))
+			}
+
+			var b strings.Builder
+			newFormatter(&b).FormatTextFragment(f.TextFragments[0])
+			if b.String() != test.Output {
+				t.Errorf("incorrect fragment\nexpected:\n%s\nactual:\n%s", test.Output, b.String())
+			}
+
+			reparsed := assertParseSingleFile(t, []byte(header+b.String()), "coalesced patch")
+			assertFilesEqual(t, reparsed, f)
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package gitdiff
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+)
+
+// ParseCompressed parses a patch that may be compressed with gzip or zlib. It
+// checks the start of r for the magic header of each format and decompresses
+// the input before parsing if it finds one. Otherwise, it parses r as
+// uncompressed text. Errors from decompressing the input are returned as-is
+// and are not treated as the end of the patch.
+//
+// Apart from decompression, ParseCompressed is equivalent to Parse.
+func ParseCompressed(r io.Reader) ([]*File, string, error) {
+	br := bufio.NewReader(r)
+
+	header, err := br.Peek(2)
+	if err != nil && err != io.EOF {
+		return nil, "", err
+	}
+
+	var dr io.ReadCloser
+	switch {
+	case isGzipHeader(header):
+		dr, err = gzip.NewReader(br)
+	case isZlibHeader(header):
+		dr, err = zlib.NewReader(br)
+	default:
+		return Parse(br)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	files, preamble, err := Parse(dr)
+	if err != nil {
+		return files, preamble, err
+	}
+	return files, preamble, dr.Close()
+}
+
+func isGzipHeader(b []byte) bool {
+	return len(b) >= 2 && b[0] == 0x1f && b[1] == 0x8b
+}
+
+// isZlibHeader reports if b starts with a zlib header that uses the deflate
+// method with a 32K window and no preset dictionary, the only form created by
+// Git and by the compress/zlib package. Limiting the window size avoids most
+// text that happens to have a valid header checksum.
+func isZlibHeader(b []byte) bool {
+	if len(b) < 2 {
+		return false
+	}
+	cmf, flg := b[0], b[1]
+	return cmf == 0x78 && flg&0x20 == 0 && (uint16(cmf)<<8|uint16(flg))%31 == 0
+}
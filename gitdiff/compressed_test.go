@@ -0,0 +1,100 @@
+package gitdiff
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"testing"
+)
+
+func TestParseCompressed(t *testing.T) {
+	const patch = `commit message
+
+diff --git a/file.txt b/file.txt
+index 1c23fcc..40a1b33 100644
+--- a/file.txt
++++ b/file.txt
+@@ -1,2 +1,2 @@
+ line 1
+-line 2
++line 2 changed
+`
+
+	compress := func(newWriter func(io.Writer) io.WriteCloser) []byte {
+		var b bytes.Buffer
+		w := newWriter(&b)
+		if _, err := w.Write([]byte(patch)); err != nil {
+			t.Fatalf("unexpected error compressing patch: %v", err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("unexpected error compressing patch: %v", err)
+		}
+		return b.Bytes()
+	}
+
+	gzipData := compress(func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) })
+	zlibData := compress(func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) })
+
+	tests := map[string]struct {
+		Input []byte
+		Files int
+		Err   interface{}
+	}{
+		"plain": {
+			Input: []byte(patch),
+			Files: 1,
+		},
+		"gzip": {
+			Input: gzipData,
+			Files: 1,
+		},
+		"zlib": {
+			Input: zlibData,
+			Files: 1,
+		},
+		"empty": {
+			Input: []byte{},
+		},
+		"shortPlain": {
+			Input: []byte("x"),
+		},
+		"truncatedGzip": {
+			Input: gzipData[:len(gzipData)/2],
+			Err:   io.ErrUnexpectedEOF,
+		},
+		"corruptZlib": {
+			Input: append(append([]byte{}, zlibData[:len(zlibData)-4]...), 0, 0, 0, 0),
+			Err:   zlib.ErrChecksum,
+		},
+		"invalidGzipHeader": {
+			Input: []byte{0x1f, 0x8b, 0x00},
+			Err:   io.ErrUnexpectedEOF,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			files, preamble, err := ParseCompressed(bytes.NewReader(test.Input))
+			if test.Err != nil {
+				assertError(t, test.Err, err, "parsing compressed patch")
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error parsing compressed patch: %v", err)
+			}
+
+			if len(files) != test.Files {
+				t.Fatalf("incorrect number of files: expected %d, actual %d", test.Files, len(files))
+			}
+			if test.Files > 0 {
+				if preamble != "commit message\n\n" {
+					t.Errorf("incorrect preamble: %q", preamble)
+				}
+				if files[0].NewName != "file.txt" || len(files[0].TextFragments) != 1 {
+					t.Errorf("incorrect file: %+v", files[0])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,321 @@
+package gitdiff
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	contextHunkSeparator = "***************"
+)
+
+// contextLine is a line from one side of a hunk in the context diff format.
+type contextLine struct {
+	op    byte
+	data  string
+	noEOL bool
+}
+
+// ParseContextFragments parses hunks in the context diff format until the
+// next file header or the end of the stream and attaches them to the given
+// file as text fragments. It returns the number of fragments that were added.
+func (p *parser) ParseContextFragments(f *File) (n int, err error) {
+	for {
+		frag, err := p.ParseContextFragment()
+		if err != nil {
+			return n, err
+		}
+		if frag == nil {
+			return n, nil
+		}
+		if err := p.checkFragmentLimit(n); err != nil {
+			return n, err
+		}
+
+		if f.IsNew && frag.OldLines > 0 {
+			return n, p.Errorf(-1, "new file depends on old contents")
+		}
+		if f.IsDelete && frag.NewLines > 0 {
+			return n, p.Errorf(-1, "deleted file still has contents")
+		}
+
+		f.TextFragments = append(f.TextFragments, frag)
+		n++
+	}
+}
+
+// ParseContextFragment parses a single hunk in the context diff format and
+// converts it to a text fragment. A hunk starts with a separator line and
+// contains a section for the old lines and a section for the new lines:
+//
+//	***************
+//	*** 1,3 ****
+//	  context line
+//	! changed line
+//	- deleted line
+//	--- 1,2 ----
+//	  context line
+//	! new changed line
+//
+// A section is omitted if it only contains context lines.
+func (p *parser) ParseContextFragment() (*TextFragment, error) {
+	if !strings.HasPrefix(p.Line(0), contextHunkSeparator) {
+		return nil, nil
+	}
+
+	frag := &TextFragment{}
+	frag.Comment = strings.TrimSpace(p.Line(0)[len(contextHunkSeparator):])
+
+	if err := p.Next(); err != nil {
+		if err == io.EOF {
+			return nil, p.Errorf(0, "no content following hunk separator")
+		}
+		return nil, err
+	}
+
+	oldStart, oldCount, err := p.parseContextRange("*** ", " ****")
+	if err != nil {
+		return nil, err
+	}
+
+	var oldLines []contextLine
+	if !isContextRange(p.Line(0), "--- ", " ----") {
+		if oldLines, err = p.parseContextSection(oldCount, '-'); err != nil {
+			return nil, err
+		}
+	}
+
+	newStart, newCount, err := p.parseContextRange("--- ", " ----")
+	if err != nil {
+		return nil, err
+	}
+
+	var newLines []contextLine
+	if line := p.Line(0); line != "\n" && isContextSectionLine(line, '+') {
+		if newLines, err = p.parseContextSection(newCount, '+'); err != nil {
+			return nil, err
+		}
+	}
+
+	// an omitted section contains the context lines from the other section
+	switch {
+	case oldLines == nil && newLines == nil:
+		return nil, p.Errorf(0, "hunk contains no changes")
+	case oldLines == nil:
+		oldLines = filterContextLines(newLines)
+	case newLines == nil:
+		newLines = filterContextLines(oldLines)
+	}
+
+	if err := checkContextCount(oldStart, oldCount, len(oldLines)); err != nil {
+		return nil, p.Errorf(0, "hunk header miscounts old lines: %v", err)
+	}
+	if err := checkContextCount(newStart, newCount, len(newLines)); err != nil {
+		return nil, p.Errorf(0, "hunk header miscounts new lines: %v", err)
+	}
+
+	frag.OldPosition, frag.OldLines = oldStart, int64(len(oldLines))
+	frag.NewPosition, frag.NewLines = newStart, int64(len(newLines))
+
+	if err := mergeContextLines(frag, oldLines, newLines); err != nil {
+		return nil, p.Errorf(0, "invalid hunk: %v", err)
+	}
+	if p.opts.headersOnly {
+		// the sections must be merged to find the counts, so drop the lines
+		// after merging instead of while parsing
+		frag.Lines = nil
+	}
+	setLineNumbers(frag)
+
+	return frag, nil
+}
+
+// parseContextRange parses a range line of a context hunk and advances the
+// parser. It returns the start of the range and the number of lines if the
+// range has both a start and an end. If the range only has a single number,
+// the count is -1, meaning the section has either zero or one lines.
+func (p *parser) parseContextRange(startMark, endMark string) (start int64, count int64, err error) {
+	line := p.Line(0)
+	if !isContextRange(line, startMark, endMark) {
+		return 0, 0, p.Errorf(0, "invalid hunk range: %q", strings.TrimSuffix(line, "\n"))
+	}
+
+	r := strings.TrimSuffix(line, "\n")
+	r = r[len(startMark) : len(r)-len(endMark)]
+
+	parts := strings.SplitN(r, ",", 2)
+	if start, err = strconv.ParseInt(parts[0], 10, 64); err != nil {
+		nerr := err.(*strconv.NumError)
+		return 0, 0, p.Errorf(0, "invalid hunk range: bad start of range: %s: %v", parts[0], nerr.Err)
+	}
+
+	count = -1
+	if len(parts) > 1 {
+		end, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			nerr := err.(*strconv.NumError)
+			return 0, 0, p.Errorf(0, "invalid hunk range: bad end of range: %s: %v", parts[1], nerr.Err)
+		}
+		if end < start {
+			return 0, 0, p.Errorf(0, "invalid hunk range: end is before start")
+		}
+		count = end - start + 1
+	}
+
+	if err := p.Next(); err != nil && err != io.EOF {
+		return 0, 0, err
+	}
+	return start, count, nil
+}
+
+// parseContextSection parses the lines in one section of a context hunk. If
+// count is negative, the section contains a single line.
+func (p *parser) parseContextSection(count int64, changeOp byte) ([]contextLine, error) {
+	if count < 0 {
+		count = 1
+	}
+
+	// do not trust the count when allocating, since it comes from the input
+	size := count
+	if size > 64 {
+		size = 64
+	}
+	lines := make([]contextLine, 0, size)
+	for int64(len(lines)) < count {
+		line := p.Line(0)
+		if !isContextSectionLine(line, changeOp) {
+			return nil, p.Errorf(0, "invalid line operation: %q", strings.TrimSuffix(line, "\n"))
+		}
+
+		data := p.ContentLine(0)
+		if line == "\n" {
+			// some tools remove trailing space from empty context lines
+			lines = append(lines, contextLine{op: ' ', data: data})
+		} else {
+			lines = append(lines, contextLine{op: line[0], data: data[2:]})
+		}
+
+		if err := p.Next(); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+
+		if isNoNewlineMarker(p.Line(0)) {
+			last := &lines[len(lines)-1]
+			last.data = strings.TrimSuffix(last.data, "\n")
+			last.noEOL = true
+			if err := p.Next(); err != nil && err != io.EOF {
+				return nil, err
+			}
+		}
+	}
+
+	if int64(len(lines)) < count {
+		return nil, p.Errorf(0, "hunk header miscounts lines: expected %d, found %d", count, len(lines))
+	}
+	return lines, nil
+}
+
+func isContextRange(line, startMark, endMark string) bool {
+	line = strings.TrimSuffix(line, "\n")
+	return len(line) > len(startMark)+len(endMark) && strings.HasPrefix(line, startMark) && strings.HasSuffix(line, endMark)
+}
+
+func isContextSectionLine(line string, changeOp byte) bool {
+	if line == "\n" {
+		return true
+	}
+	if len(line) < 2 || line[1] != ' ' {
+		return false
+	}
+	return line[0] == ' ' || line[0] == '!' || line[0] == changeOp
+}
+
+func filterContextLines(lines []contextLine) []contextLine {
+	filtered := make([]contextLine, 0, len(lines))
+	for _, line := range lines {
+		if line.op == ' ' {
+			filtered = append(filtered, line)
+		}
+	}
+	return filtered
+}
+
+// checkContextCount checks that the number of lines in a section matches the
+// count from the range line. Ranges with a single number may have zero lines
+// if the section is empty.
+func checkContextCount(start, count int64, n int) error {
+	switch {
+	case count < 0 && n > 1:
+		return fmt.Errorf("expected at most 1, found %d", n)
+	case count >= 0 && int64(n) != count:
+		return fmt.Errorf("expected %d, found %d", count, n)
+	case start == 0 && n > 0:
+		return fmt.Errorf("range starting at 0 contains %d lines", n)
+	}
+	return nil
+}
+
+// mergeContextLines combines the old and new sections of a context hunk into
+// the lines of a unified text fragment. Deleted lines always appear before
+// added lines in a group of changes.
+func mergeContextLines(frag *TextFragment, oldLines, newLines []contextLine) error {
+	appendLine := func(op LineOp, line contextLine) {
+		switch op {
+		case OpContext:
+			if frag.LinesAdded == 0 && frag.LinesDeleted == 0 {
+				frag.LeadingContext++
+			} else {
+				frag.TrailingContext++
+			}
+		case OpDelete:
+			frag.LinesDeleted++
+			frag.TrailingContext = 0
+		case OpAdd:
+			frag.LinesAdded++
+			frag.TrailingContext = 0
+		}
+		frag.Lines = append(frag.Lines, Line{Op: op, Line: line.data, NoNewlineAtEOF: line.noEOL})
+	}
+
+	i, j := 0, 0
+	for i < len(oldLines) || j < len(newLines) {
+		switch {
+		case i < len(oldLines) && oldLines[i].op == '-':
+			appendLine(OpDelete, oldLines[i])
+			i++
+
+		case j < len(newLines) && newLines[j].op == '+':
+			appendLine(OpAdd, newLines[j])
+			j++
+
+		case i < len(oldLines) && oldLines[i].op == '!':
+			if j >= len(newLines) || newLines[j].op != '!' {
+				return fmt.Errorf("changed lines in old section do not have replacements")
+			}
+			for ; i < len(oldLines) && oldLines[i].op == '!'; i++ {
+				appendLine(OpDelete, oldLines[i])
+			}
+			for ; j < len(newLines) && newLines[j].op == '!'; j++ {
+				appendLine(OpAdd, newLines[j])
+			}
+
+		case i < len(oldLines) && j < len(newLines) && oldLines[i].op == ' ' && newLines[j].op == ' ':
+			appendLine(OpContext, oldLines[i])
+			i++
+			j++
+
+		default:
+			return fmt.Errorf("old and new sections do not match")
+		}
+	}
+
+	if frag.LinesAdded == 0 && frag.LinesDeleted == 0 {
+		return fmt.Errorf("hunk contains no changes")
+	}
+	return nil
+}
@@ -0,0 +1,252 @@
+package gitdiff
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseContextFragment(t *testing.T) {
+	tests := map[string]struct {
+		Input  string
+		Output *TextFragment
+		Err    bool
+	}{
+		"changed": {
+			Input: `***************
+*** 1,4 ****
+  line 1
+! line 2
+  line 3
+- line 4
+--- 1,3 ----
+  line 1
+! line 2 changed
+  line 3
+`,
+			Output: &TextFragment{
+				OldPosition: 1,
+				OldLines:    4,
+				NewPosition: 1,
+				NewLines:    3,
+				Lines: []Line{
+					{Op: OpContext, Line: "line 1\n", OldLineNo: 1, NewLineNo: 1},
+					{Op: OpDelete, Line: "line 2\n", OldLineNo: 2},
+					{Op: OpAdd, Line: "line 2 changed\n", NewLineNo: 2},
+					{Op: OpContext, Line: "line 3\n", OldLineNo: 3, NewLineNo: 3},
+					{Op: OpDelete, Line: "line 4\n", OldLineNo: 4},
+				},
+				LinesAdded:     1,
+				LinesDeleted:   2,
+				LeadingContext: 1,
+			},
+		},
+		"onlyAdditions": {
+			Input: `*************** func main() {
+*** 10,11 ****
+--- 10,12 ----
+  line 10
+  line 11
++ line 12
+`,
+			Output: &TextFragment{
+				Comment:     "func main() {",
+				OldPosition: 10,
+				OldLines:    2,
+				NewPosition: 10,
+				NewLines:    3,
+				Lines: []Line{
+					{Op: OpContext, Line: "line 10\n", OldLineNo: 10, NewLineNo: 10},
+					{Op: OpContext, Line: "line 11\n", OldLineNo: 11, NewLineNo: 11},
+					{Op: OpAdd, Line: "line 12\n", NewLineNo: 12},
+				},
+				LinesAdded:     1,
+				LeadingContext: 2,
+			},
+		},
+		"onlyDeletions": {
+			Input: `***************
+*** 1,2 ****
+  line 1
+- line 2
+--- 1 ----
+`,
+			Output: &TextFragment{
+				OldPosition: 1,
+				OldLines:    2,
+				NewPosition: 1,
+				NewLines:    1,
+				Lines: []Line{
+					{Op: OpContext, Line: "line 1\n", OldLineNo: 1, NewLineNo: 1},
+					{Op: OpDelete, Line: "line 2\n", OldLineNo: 2},
+				},
+				LinesDeleted:   1,
+				LeadingContext: 1,
+			},
+		},
+		"noContext": {
+			Input: `***************
+*** 1 ****
+--- 2 ----
++ line 2
+`,
+			Output: &TextFragment{
+				OldPosition: 1,
+				OldLines:    0,
+				NewPosition: 2,
+				NewLines:    1,
+				Lines: []Line{
+					{Op: OpAdd, Line: "line 2\n", NewLineNo: 2},
+				},
+				LinesAdded: 1,
+			},
+		},
+		"noNewlineAtEnd": {
+			Input: `***************
+*** 1 ****
+! line 1
+--- 1 ----
+! line 1 changed
+\ No newline at end of file
+`,
+			Output: &TextFragment{
+				OldPosition: 1,
+				OldLines:    1,
+				NewPosition: 1,
+				NewLines:    1,
+				Lines: []Line{
+					{Op: OpDelete, Line: "line 1\n", OldLineNo: 1},
+					{Op: OpAdd, Line: "line 1 changed", NewLineNo: 1, NoNewlineAtEOF: true},
+				},
+				LinesAdded:   1,
+				LinesDeleted: 1,
+			},
+		},
+		"miscount": {
+			Input: `***************
+*** 1,3 ****
+  line 1
+- line 2
+--- 1 ----
+`,
+			Err: true,
+		},
+		"unmatchedChange": {
+			Input: `***************
+*** 1,2 ****
+  line 1
+! line 2
+--- 1,2 ----
+  line 1
++ line 2
+`,
+			Err: true,
+		},
+		"invalidRange": {
+			Input: `***************
+*** 1,2
+`,
+			Err: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := newTestParser(test.Input, true)
+
+			frag, err := p.ParseContextFragment()
+			if test.Err {
+				if err == nil || err == io.EOF {
+					t.Fatalf("expected error parsing context fragment, but got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("error parsing context fragment: %v", err)
+			}
+
+			if !reflect.DeepEqual(test.Output, frag) {
+				t.Errorf("incorrect fragment\nexpected: %+v\nactual: %+v", test.Output, frag)
+			}
+			if err := frag.Validate(); err != nil {
+				t.Errorf("parsed fragment is not valid: %v", err)
+			}
+		})
+	}
+}
+
+func TestParseContextDiff(t *testing.T) {
+	const input = `*** dir/file.txt	2019-03-21 23:00:00.0 -0700
+--- dir/file.txt	2019-03-21 23:30:00.0 -0700
+***************
+*** 1,2 ****
+! old line
+  context line
+--- 1,2 ----
+! new line
+  context line
+*** /dev/null	1970-01-01 00:00:00.0 +0000
+--- dir/new.txt	2019-03-21 23:30:00.0 -0700
+***************
+*** 0 ****
+--- 1 ----
++ new file
+`
+
+	files, _, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("unexpected error parsing patch: %v", err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("incorrect number of parsed files: expected 2, actual %d", len(files))
+	}
+
+	expected := []*File{
+		{
+			OldName: "dir/file.txt",
+			NewName: "dir/file.txt",
+			TextFragments: []*TextFragment{
+				{
+					OldPosition: 1,
+					OldLines:    2,
+					NewPosition: 1,
+					NewLines:    2,
+					Lines: []Line{
+						{Op: OpDelete, Line: "old line\n", OldLineNo: 1},
+						{Op: OpAdd, Line: "new line\n", NewLineNo: 1},
+						{Op: OpContext, Line: "context line\n", OldLineNo: 2, NewLineNo: 2},
+					},
+					LinesAdded:      1,
+					LinesDeleted:    1,
+					TrailingContext: 1,
+				},
+			},
+			HeaderLine: 1,
+		},
+		{
+			NewName: "dir/new.txt",
+			IsNew:   true,
+			TextFragments: []*TextFragment{
+				{
+					OldPosition: 0,
+					OldLines:    0,
+					NewPosition: 1,
+					NewLines:    1,
+					Lines: []Line{
+						{Op: OpAdd, Line: "new file\n", NewLineNo: 1},
+					},
+					LinesAdded: 1,
+				},
+			},
+			HeaderLine:   10,
+			HeaderOffset: 184,
+		},
+	}
+
+	for i := range expected {
+		if !reflect.DeepEqual(expected[i], files[i]) {
+			t.Errorf("incorrect file at position %d\nexpected: %+v\n  actual: %+v", i, expected[i], files[i])
+		}
+	}
+}
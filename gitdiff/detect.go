@@ -0,0 +1,225 @@
+package gitdiff
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// PatchFormat identifies the format of the file headers and fragments in a
+// patch, as reported by DetectFormat.
+type PatchFormat int
+
+const (
+	// PatchFormatUnknown means the input contains no recognized file headers.
+	PatchFormatUnknown PatchFormat = iota
+
+	// PatchFormatGit means the input contains files with Git headers, like
+	// the output of `git diff`.
+	PatchFormatGit
+
+	// PatchFormatCombined means the input contains files with combined diff
+	// headers, like the output of `git diff --cc`. It may also contain files
+	// with Git headers.
+	PatchFormatCombined
+
+	// PatchFormatUnified means the input contains files with traditional
+	// headers and fragments in the unified format, like the output of
+	// `diff -u`.
+	PatchFormatUnified
+
+	// PatchFormatContext means the input contains files with traditional
+	// headers and fragments in the context format, like the output of
+	// `diff -c`.
+	PatchFormatContext
+
+	// PatchFormatMailbox means the input is a UNIX mailbox, like the output
+	// of `git format-patch`. Use ParseMailbox to parse the patches.
+	PatchFormatMailbox
+
+	// PatchFormatMixed means the input contains files in more than one of
+	// the other formats, or Git headers followed by context fragments. The
+	// parser may not handle the input as expected.
+	PatchFormatMixed
+)
+
+func (f PatchFormat) String() string {
+	switch f {
+	case PatchFormatGit:
+		return "git"
+	case PatchFormatCombined:
+		return "combined"
+	case PatchFormatUnified:
+		return "unified"
+	case PatchFormatContext:
+		return "context"
+	case PatchFormatMailbox:
+		return "mailbox"
+	case PatchFormatMixed:
+		return "mixed"
+	}
+	return "unknown"
+}
+
+// DetectFormat returns the format of the patch at the start of r. It peeks at
+// the buffered content of r without consuming it, so callers can pass r to
+// Parse or ParseMailbox after detecting the format. Only the complete lines
+// that fit in the buffer are checked, so use bufio.NewReaderSize to detect the
+// format of larger inputs.
+//
+// Detection uses the same line prefixes as the parser, but does not validate
+// the headers or fragments it finds. It returns an error only if reading from
+// r fails.
+func DetectFormat(r *bufio.Reader) (PatchFormat, error) {
+	b, err := r.Peek(r.Size())
+	switch {
+	case err == bufio.ErrBufferFull:
+		// ignore the last line if the buffer ends in the middle of it
+		if i := strings.LastIndexByte(string(b), '\n'); i >= 0 {
+			b = b[:i+1]
+		}
+	case err != nil && err != io.EOF:
+		return PatchFormatUnknown, err
+	}
+
+	lines := strings.SplitAfter(strings.TrimPrefix(string(b), utf8BOM), "\n")
+	return detectFormat(lines), nil
+}
+
+func detectFormat(lines []string) PatchFormat {
+	var (
+		seen    [PatchFormatMixed]bool
+		current PatchFormat
+		inFrag  bool
+		mixed   bool
+		mailbox bool
+		started bool
+
+		// lines left in the current unified fragment
+		oldLines, newLines int64
+	)
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		next := ""
+		if i+1 < len(lines) {
+			next = lines[i+1]
+		}
+
+		if !started && strings.TrimSpace(line) != "" {
+			started = true
+			mailbox = isMailboxSeparator(line)
+		}
+
+		if oldLines > 0 || newLines > 0 {
+			// skip fragment content, which can look like headers
+			if countFragmentLine(line, &oldLines, &newLines) {
+				seen[current] = true
+				continue
+			}
+			oldLines, newLines = 0, 0
+		}
+
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			current, inFrag = PatchFormatGit, false
+
+		case strings.HasPrefix(line, "diff --cc "), strings.HasPrefix(line, "diff --combined "):
+			current, inFrag = PatchFormatCombined, false
+
+		case strings.HasPrefix(line, "--- ") && strings.HasPrefix(next, "+++ "):
+			if !isGitFormat(current) || inFrag {
+				current, inFrag = PatchFormatUnified, false
+			}
+			i++
+
+		case strings.HasPrefix(line, "*** ") && strings.HasPrefix(next, "--- "):
+			if isGitFormat(current) && !inFrag {
+				mixed = true
+			}
+			current, inFrag = PatchFormatContext, false
+			i++
+
+		case strings.HasPrefix(line, "@@ "), strings.HasPrefix(line, "@@@ "):
+			if current == PatchFormatContext {
+				mixed = true
+			}
+			inFrag = true
+			if strings.HasPrefix(line, "@@ ") {
+				oldLines, newLines = fragmentLineCounts(line)
+			}
+
+		case strings.HasPrefix(line, "***************"):
+			if current != PatchFormatUnknown && current != PatchFormatContext {
+				mixed = true
+			}
+			inFrag = true
+		}
+		seen[current] = true
+	}
+
+	var kinds int
+	for _, ok := range []bool{seen[PatchFormatGit] || seen[PatchFormatCombined], seen[PatchFormatUnified], seen[PatchFormatContext]} {
+		if ok {
+			kinds++
+		}
+	}
+
+	switch {
+	case mixed || kinds > 1:
+		return PatchFormatMixed
+	case mailbox:
+		return PatchFormatMailbox
+	case seen[PatchFormatCombined]:
+		return PatchFormatCombined
+	case seen[PatchFormatGit]:
+		return PatchFormatGit
+	case seen[PatchFormatUnified]:
+		return PatchFormatUnified
+	case seen[PatchFormatContext]:
+		return PatchFormatContext
+	}
+	return PatchFormatUnknown
+}
+
+// fragmentLineCounts returns the old and new line counts from a unified
+// fragment header. It returns zero counts if the header is invalid.
+func fragmentLineCounts(line string) (oldLines int64, newLines int64) {
+	header, _, ok := strings.Cut(strings.TrimPrefix(line, "@@ "), " @@")
+	if !ok {
+		return 0, 0
+	}
+	oldRange, newRange, ok := strings.Cut(header, " ")
+	if !ok || !strings.HasPrefix(oldRange, "-") || !strings.HasPrefix(newRange, "+") {
+		return 0, 0
+	}
+
+	_, oldLines, oldErr := parseRange(oldRange[1:])
+	_, newLines, newErr := parseRange(newRange[1:])
+	if oldErr != nil || newErr != nil {
+		return 0, 0
+	}
+	return oldLines, newLines
+}
+
+// countFragmentLine updates the line counts of a unified fragment for a line
+// of fragment content. It returns false if the line is not fragment content.
+func countFragmentLine(line string, oldLines, newLines *int64) bool {
+	switch {
+	case strings.HasPrefix(line, " "), line == "\n", line == "\r\n":
+		*oldLines--
+		*newLines--
+	case strings.HasPrefix(line, "-"):
+		*oldLines--
+	case strings.HasPrefix(line, "+"):
+		*newLines--
+	case isNoNewlineMarker(line):
+	default:
+		return false
+	}
+	return true
+}
+
+func isGitFormat(f PatchFormat) bool {
+	return f == PatchFormatGit || f == PatchFormatCombined
+}
@@ -0,0 +1,177 @@
+package gitdiff
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestDetectFormat(t *testing.T) {
+	const (
+		gitFile = `diff --git a/file.txt b/file.txt
+index 1c23fcc..40a1b33 100644
+--- a/file.txt
++++ b/file.txt
+@@ -1,2 +1,2 @@
+ line 1
+-line 2
++line 2 changed
+`
+		unifiedFile = `--- file.txt.orig	2020-04-11 15:21:23.000000000 -0700
++++ file.txt	2020-04-11 15:21:23.000000000 -0700
+@@ -1,2 +1,2 @@
+ line 1
+-line 2
++line 2 changed
+`
+		contextFile = `*** file.txt.orig	2020-04-11 15:21:23.000000000 -0700
+--- file.txt	2020-04-11 15:21:23.000000000 -0700
+***************
+*** 1,2 ****
+  line 1
+! line 2
+--- 1,2 ----
+  line 1
+! line 2 changed
+`
+		combinedFile = `diff --cc file.txt
+index 1c23fcc,3b8c5e1..40a1b33
+--- a/file.txt
++++ b/file.txt
+@@@ -1,2 -1,2 +1,2 @@@
+  line 1
+- line 2
+ -line 2 theirs
+++line 2 merged
+`
+	)
+
+	tests := map[string]struct {
+		Input  string
+		Format PatchFormat
+	}{
+		"empty": {
+			Input:  "",
+			Format: PatchFormatUnknown,
+		},
+		"noFiles": {
+			Input:  "commit message\n\nwith no diff\n",
+			Format: PatchFormatUnknown,
+		},
+		"git": {
+			Input:  "commit message\n\n" + gitFile + gitFile,
+			Format: PatchFormatGit,
+		},
+		"unified": {
+			Input:  "diff -u file.txt.orig file.txt\n" + unifiedFile,
+			Format: PatchFormatUnified,
+		},
+		"context": {
+			Input:  contextFile,
+			Format: PatchFormatContext,
+		},
+		"combined": {
+			Input:  gitFile + combinedFile,
+			Format: PatchFormatCombined,
+		},
+		"mailbox": {
+			Input: "\ufeffFrom 61f5cd90bed4d204ee3feb3aa41ee91d4734855b Mon Sep 17 00:00:00 2001\n" +
+				"From: Morton Haypenny <mhaypenny@example.com>\n" +
+				"Subject: [PATCH] A sample commit\n\n---\n" + gitFile,
+			Format: PatchFormatMailbox,
+		},
+		"gitWithHeaderLikeContent": {
+			Input: `diff --git a/file.txt b/file.txt
+index 1c23fcc..40a1b33 100644
+--- a/file.txt
++++ b/file.txt
+@@ -1,3 +1,3 @@
+ line 1
+--- x
++++ y
+ line 3
+`,
+			Format: PatchFormatGit,
+		},
+		"gitAndUnified": {
+			Input:  gitFile + unifiedFile,
+			Format: PatchFormatMixed,
+		},
+		"unifiedAndContext": {
+			Input:  unifiedFile + contextFile,
+			Format: PatchFormatMixed,
+		},
+		"gitWithContextFragment": {
+			Input: `diff --git a/file.txt b/file.txt
+index 1c23fcc..40a1b33 100644
+***************
+*** 1,2 ****
+  line 1
+! line 2
+--- 1,2 ----
+  line 1
+! line 2 changed
+`,
+			Format: PatchFormatMixed,
+		},
+		"gitWithContextHeader": {
+			Input:  "diff --git a/file.txt b/file.txt\n" + contextFile,
+			Format: PatchFormatMixed,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			f, err := DetectFormat(bufio.NewReader(strings.NewReader(test.Input)))
+			if err != nil {
+				t.Fatalf("unexpected error detecting format: %v", err)
+			}
+			if f != test.Format {
+				t.Errorf("incorrect format: expected %v, actual %v", test.Format, f)
+			}
+		})
+	}
+}
+
+func TestDetectFormatDoesNotConsume(t *testing.T) {
+	input := `diff --git a/file.txt b/file.txt
+index 1c23fcc..40a1b33 100644
+--- a/file.txt
++++ b/file.txt
+@@ -1,2 +1,2 @@
+ line 1
+-line 2
++line 2 changed
+`
+	r := bufio.NewReader(strings.NewReader(input))
+
+	f, err := DetectFormat(r)
+	if err != nil {
+		t.Fatalf("unexpected error detecting format: %v", err)
+	}
+	if f != PatchFormatGit {
+		t.Fatalf("incorrect format: expected %v, actual %v", PatchFormatGit, f)
+	}
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("unexpected error reading input: %v", err)
+	}
+	if string(b) != input {
+		t.Errorf("reader was consumed by detection\nexpected:\n%s\nactual:\n%s", input, b)
+	}
+}
+
+func TestDetectFormatPartialBuffer(t *testing.T) {
+	// the second header does not fit in the buffer and is ignored
+	input := "diff --git a/file.txt b/file.txt\n" + strings.Repeat("x", 32) + "\n--- a/other.txt\n+++ b/other.txt\n"
+
+	f, err := DetectFormat(bufio.NewReaderSize(strings.NewReader(input), 40))
+	if err != nil {
+		t.Fatalf("unexpected error detecting format: %v", err)
+	}
+	if f != PatchFormatGit {
+		t.Errorf("incorrect format: expected %v, actual %v", PatchFormatGit, f)
+	}
+}
@@ -0,0 +1,116 @@
+package gitdiff
+
+import (
+	"bytes"
+	"slices"
+)
+
+// Equal returns true if f and other describe the same changes. Files are
+// equal if they have the same names, modes, object IDs, scores, and flags and
+// if their fragments are equal as described by TextFragment.Equal and
+// BinaryFragment.Equal.
+//
+// Equal ignores fields that describe where the file appeared in the parsed
+// input, like HeaderLine, RawHeader, and Preamble, and fields that are
+// derived from other fields, like the submodule commits. Two nil files are
+// equal.
+func (f *File) Equal(other *File) bool {
+	if f == nil || other == nil {
+		return f == other
+	}
+
+	if f.OldName != other.OldName || f.NewName != other.NewName {
+		return false
+	}
+	if f.IsNew != other.IsNew || f.IsDelete != other.IsDelete || f.IsCopy != other.IsCopy || f.IsRename != other.IsRename {
+		return false
+	}
+	if f.OldMode != other.OldMode || f.NewMode != other.NewMode {
+		return false
+	}
+	if f.OldOIDPrefix != other.OldOIDPrefix || f.NewOIDPrefix != other.NewOIDPrefix {
+		return false
+	}
+	if f.Score != other.Score || f.Dissimilarity != other.Dissimilarity {
+		return false
+	}
+
+	if f.IsCombined != other.IsCombined {
+		return false
+	}
+	if !slices.Equal(f.ParentOIDPrefixes, other.ParentOIDPrefixes) || !slices.Equal(f.ParentModes, other.ParentModes) {
+		return false
+	}
+
+	if len(f.TextFragments) != len(other.TextFragments) {
+		return false
+	}
+	for i, frag := range f.TextFragments {
+		if !frag.Equal(other.TextFragments[i]) {
+			return false
+		}
+	}
+
+	if f.IsBinary != other.IsBinary {
+		return false
+	}
+	return f.BinaryFragment.Equal(other.BinaryFragment) && f.ReverseBinaryFragment.Equal(other.ReverseBinaryFragment)
+}
+
+// Equal returns true if f and other describe the same changes. Fragments are
+// equal if they have the same comment, start at the same positions, and have
+// the same sequence of lines. Lines are equal if they have the same
+// operations and content and the same "no newline" state, as reported by
+// NoEOL or the NoNewlineAtEOF field.
+//
+// Equal ignores the line counts of the fragments and the line numbers of
+// each line, which can be computed from the lines. It also ignores the
+// BareContext field of each line, which only affects formatting. Two nil
+// fragments are equal.
+func (f *TextFragment) Equal(other *TextFragment) bool {
+	if f == nil || other == nil {
+		return f == other
+	}
+
+	if f.Comment != other.Comment {
+		return false
+	}
+	if f.OldPosition != other.OldPosition || f.NewPosition != other.NewPosition {
+		return false
+	}
+
+	if len(f.ParentRanges) != len(other.ParentRanges) {
+		return false
+	}
+	for i, r := range f.ParentRanges {
+		if r.Position != other.ParentRanges[i].Position {
+			return false
+		}
+	}
+
+	if len(f.Lines) != len(other.Lines) {
+		return false
+	}
+	for i, line := range f.Lines {
+		if !line.equal(other.Lines[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (fl Line) equal(other Line) bool {
+	return fl.Op == other.Op &&
+		fl.Line == other.Line &&
+		slices.Equal(fl.ParentOps, other.ParentOps) &&
+		(fl.NoNewlineAtEOF || fl.NoEOL()) == (other.NoNewlineAtEOF || other.NoEOL())
+}
+
+// Equal returns true if f and other have the same method, size, and data. Two
+// nil fragments are equal.
+func (f *BinaryFragment) Equal(other *BinaryFragment) bool {
+	if f == nil || other == nil {
+		return f == other
+	}
+	return f.Method == other.Method && f.Size == other.Size && bytes.Equal(f.Data, other.Data)
+}
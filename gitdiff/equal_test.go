@@ -0,0 +1,162 @@
+package gitdiff
+
+import (
+	"os"
+	"testing"
+)
+
+func TestFileEqual(t *testing.T) {
+	patch := `diff --git a/file.txt b/file.txt
+index 1c23fcc..40a1b33 100644
+--- a/file.txt
++++ b/file.txt
+@@ -1,3 +1,3 @@ heading
+ line 1
+-line 2
++line 2 changed
+ line 3
+\ No newline at end of file
+`
+	parsed := assertParseSingleFile(t, []byte(patch), "patch")
+
+	base := func() *File {
+		return &File{
+			OldName:      "file.txt",
+			NewName:      "file.txt",
+			OldMode:      os.FileMode(0100644),
+			OldOIDPrefix: "1c23fcc",
+			NewOIDPrefix: "40a1b33",
+			TextFragments: []*TextFragment{
+				{
+					Comment:     "heading",
+					OldPosition: 1,
+					NewPosition: 1,
+					Lines: []Line{
+						{Op: OpContext, Line: "line 1\n"},
+						{Op: OpDelete, Line: "line 2\n"},
+						{Op: OpAdd, Line: "line 2 changed\n"},
+						{Op: OpContext, Line: "line 3"},
+					},
+				},
+			},
+		}
+	}
+
+	tests := map[string]struct {
+		Modify func(f *File)
+		Equal  bool
+	}{
+		"same": {
+			Modify: func(f *File) {},
+			Equal:  true,
+		},
+		"ignoresPosition": {
+			Modify: func(f *File) {
+				f.HeaderLine = 10
+				f.HeaderOffset = 200
+				f.Preamble = "commit message\n"
+			},
+			Equal: true,
+		},
+		"ignoresBareContext": {
+			Modify: func(f *File) { f.TextFragments[0].Lines[0].BareContext = true },
+			Equal:  true,
+		},
+		"differentName": {
+			Modify: func(f *File) { f.NewName = "other.txt" },
+		},
+		"differentMode": {
+			Modify: func(f *File) { f.NewMode = os.FileMode(0100755) },
+		},
+		"differentOID": {
+			Modify: func(f *File) { f.NewOIDPrefix = "40a1b34" },
+		},
+		"differentFlag": {
+			Modify: func(f *File) { f.IsRename = true },
+		},
+		"differentComment": {
+			Modify: func(f *File) { f.TextFragments[0].Comment = "" },
+		},
+		"differentPosition": {
+			Modify: func(f *File) { f.TextFragments[0].OldPosition = 2 },
+		},
+		"differentLine": {
+			Modify: func(f *File) { f.TextFragments[0].Lines[2].Line = "line 2 changed again\n" },
+		},
+		"differentOp": {
+			Modify: func(f *File) { f.TextFragments[0].Lines[0].Op = OpDelete },
+		},
+		"differentNoNewline": {
+			Modify: func(f *File) { f.TextFragments[0].Lines[3].Line = "line 3\n" },
+		},
+		"extraFragment": {
+			Modify: func(f *File) {
+				f.TextFragments = append(f.TextFragments, &TextFragment{OldPosition: 10, NewPosition: 10})
+			},
+		},
+		"binary": {
+			Modify: func(f *File) {
+				f.IsBinary = true
+				f.BinaryFragment = &BinaryFragment{Method: BinaryPatchLiteral, Size: 1, Data: []byte{1}}
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			f := base()
+			test.Modify(f)
+
+			if eq := parsed.Equal(f); eq != test.Equal {
+				t.Errorf("incorrect result comparing parsed file: expected %t, actual %t", test.Equal, eq)
+			}
+			if eq := f.Equal(parsed); eq != test.Equal {
+				t.Errorf("incorrect result comparing to parsed file: expected %t, actual %t", test.Equal, eq)
+			}
+		})
+	}
+
+	t.Run("nil", func(t *testing.T) {
+		var f *File
+		if !f.Equal(nil) {
+			t.Errorf("expected nil files to be equal")
+		}
+		if f.Equal(parsed) || parsed.Equal(nil) {
+			t.Errorf("expected nil and non-nil files to be different")
+		}
+	})
+}
+
+func TestBinaryFragmentEqual(t *testing.T) {
+	frag := &BinaryFragment{Method: BinaryPatchLiteral, Size: 3, Data: []byte{1, 2, 3}}
+
+	tests := map[string]struct {
+		Other *BinaryFragment
+		Equal bool
+	}{
+		"same": {
+			Other: &BinaryFragment{Method: BinaryPatchLiteral, Size: 3, Data: []byte{1, 2, 3}},
+			Equal: true,
+		},
+		"differentMethod": {
+			Other: &BinaryFragment{Method: BinaryPatchDelta, Size: 3, Data: []byte{1, 2, 3}},
+		},
+		"differentSize": {
+			Other: &BinaryFragment{Method: BinaryPatchLiteral, Size: 4, Data: []byte{1, 2, 3}},
+		},
+		"differentData": {
+			Other: &BinaryFragment{Method: BinaryPatchLiteral, Size: 3, Data: []byte{1, 2, 4}},
+		},
+		"nil": {
+			Other: nil,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if eq := frag.Equal(test.Other); eq != test.Equal {
+				t.Errorf("incorrect result: expected %t, actual %t", test.Equal, eq)
+			}
+		})
+	}
+}
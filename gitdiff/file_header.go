@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"strconv"
 	"strings"
 	"time"
@@ -19,7 +20,20 @@ const (
 func (p *parser) ParseNextFileHeader() (*File, string, error) {
 	var preamble strings.Builder
 	var file *File
+
+	defer func() { p.recordRaw = false }()
 	for {
+		line, offset := p.lineno, p.offset
+		if p.opts.rawHeaders {
+			p.rawHeader.Reset()
+			p.recordRaw = true
+		}
+
+		// range-diff output contains nested diffs that are not patches
+		if line == 1 && isRangeDiffPair(p.Line(0)) {
+			return nil, "", ErrRangeDiff
+		}
+
 		// check for disconnected fragment headers (corrupt patch)
 		frag, err := p.ParseTextFragmentHeader()
 		if err != nil {
@@ -30,13 +44,24 @@ func (p *parser) ParseNextFileHeader() (*File, string, error) {
 			return nil, "", p.Errorf(-1, "patch fragment without file header: %s", frag.Header())
 		}
 
+		// check for a git-generated combined diff
+		file, err = p.ParseCombinedFileHeader()
+		if err != nil {
+			return nil, "", err
+		}
+		if file != nil {
+			p.setHeaderPosition(file, line, offset, preamble.String())
+			return file, file.Preamble, nil
+		}
+
 		// check for a git-generated patch
 		file, err = p.ParseGitFileHeader()
 		if err != nil {
 			return nil, "", err
 		}
 		if file != nil {
-			return file, preamble.String(), nil
+			p.setHeaderPosition(file, line, offset, preamble.String())
+			return file, file.Preamble, nil
 		}
 
 		// check for a "traditional" patch
@@ -45,11 +70,47 @@ func (p *parser) ParseNextFileHeader() (*File, string, error) {
 			return nil, "", err
 		}
 		if file != nil {
-			return file, preamble.String(), nil
+			p.recursive = true
+			p.setHeaderPosition(file, line, offset, preamble.String())
+			return file, file.Preamble, nil
 		}
 
+		// check for a "traditional" patch in the context format
+		file, err = p.ParseContextFileHeader()
+		if err != nil {
+			return nil, "", err
+		}
+		if file != nil {
+			p.recursive = true
+			p.setHeaderPosition(file, line, offset, preamble.String())
+			return file, file.Preamble, nil
+		}
+
+		// check for a "traditional" binary file with no other header
+		file, err = p.ParseTraditionalBinaryFileHeader()
+		if err != nil {
+			return nil, "", err
+		}
+		if file != nil {
+			p.recursive = true
+			p.setHeaderPosition(file, line, offset, preamble.String())
+			return file, file.Preamble, nil
+		}
+
+		// check for a file that only exists on one side of a recursive diff
+		file, err = p.ParseOnlyInHeader()
+		if err != nil {
+			return nil, "", err
+		}
+		if file != nil {
+			p.setHeaderPosition(file, line, offset, preamble.String())
+			return file, file.Preamble, nil
+		}
+
+		p.ParseDiffCommandRoots()
+
 	NextLine:
-		preamble.WriteString(p.Line(0))
+		preamble.WriteString(p.ContentLine(0))
 		if err := p.Next(); err != nil {
 			if err == io.EOF {
 				break
@@ -60,6 +121,27 @@ func (p *parser) ParseNextFileHeader() (*File, string, error) {
 	return nil, preamble.String(), nil
 }
 
+// setHeaderPosition records the position of the header of file in the input
+// and the preamble preceding it. If the parser keeps raw headers, it also sets
+// the raw header of the file to the lines consumed since the start of the
+// header.
+func (p *parser) setHeaderPosition(file *File, line, offset int64, preamble string) {
+	file.HeaderLine, file.HeaderOffset = line, offset
+	file.Preamble = preamble
+	if p.recordRaw {
+		file.RawHeader = p.rawHeader.String()
+	}
+}
+
+// transformNames applies the name transform option to the names of f, if the
+// option is set and f has names.
+func (p *parser) transformNames(f *File) {
+	if p.opts.nameTransform == nil || (f.OldName == "" && f.NewName == "") {
+		return
+	}
+	f.OldName, f.NewName = p.opts.nameTransform(f.OldName, f.NewName)
+}
+
 func (p *parser) ParseGitFileHeader() (*File, error) {
 	const prefix = "diff --git "
 
@@ -68,14 +150,19 @@ func (p *parser) ParseGitFileHeader() (*File, error) {
 	}
 	header := p.Line(0)[len(prefix):]
 
-	defaultName, err := parseGitHeaderName(header)
+	strip := p.gitStripLevel()
+
+	defaultName, err := parseGitHeaderName(header, strip)
 	if err != nil {
 		return nil, p.Errorf(0, "git file header: %v", err)
 	}
 
 	f := &File{}
 	for {
-		end, err := parseGitHeaderData(f, p.Line(1), defaultName)
+		end, err := parseGitHeaderData(f, p.Line(1), defaultName, strip)
+		if err == nil && p.opts.maxOIDLength > 0 {
+			err = validateOIDs(f, p.opts.maxOIDLength)
+		}
 		if err != nil {
 			return nil, p.Errorf(1, "git file header: %v", err)
 		}
@@ -93,11 +180,19 @@ func (p *parser) ParseGitFileHeader() (*File, error) {
 	}
 
 	if f.OldName == "" && f.NewName == "" {
-		if defaultName == "" {
-			return nil, p.Errorf(0, "git file header: missing filename information")
+		if defaultName != "" {
+			f.OldName = defaultName
+			f.NewName = defaultName
+		} else {
+			// `git diff --no-index` compares files with different names, which
+			// only appear in the header line if the content is the same
+			oldName, newName, ok := parseGitHeaderNames(header, strip)
+			if !ok {
+				return nil, p.Errorf(0, "git file header: missing filename information")
+			}
+			f.OldName = oldName
+			f.NewName = newName
 		}
-		f.OldName = defaultName
-		f.NewName = defaultName
 	}
 
 	if (f.NewName == "" && !f.IsDelete) || (f.OldName == "" && !f.IsNew) {
@@ -107,6 +202,58 @@ func (p *parser) ParseGitFileHeader() (*File, error) {
 	return f, nil
 }
 
+// ParseCombinedFileHeader parses the header of a file in a combined diff,
+// generated by Git for merge commits. The header starts with "diff --cc" for
+// the dense format and "diff --combined" for the full format.
+func (p *parser) ParseCombinedFileHeader() (*File, error) {
+	var prefix string
+	for _, pre := range []string{"diff --cc ", "diff --combined "} {
+		if strings.HasPrefix(p.Line(0), pre) {
+			prefix = pre
+			break
+		}
+	}
+	if prefix == "" {
+		return nil, nil
+	}
+
+	// combined diffs include the plain path without a prefix
+	name, _, err := parseName(p.Line(0)[len(prefix):], 0, 0)
+	if err != nil {
+		return nil, p.Errorf(0, "combined file header: %v", err)
+	}
+
+	f := &File{
+		OldName:    name,
+		NewName:    name,
+		IsCombined: true,
+	}
+
+	strip := p.gitStripLevel()
+	for {
+		end, err := parseCombinedHeaderData(f, p.Line(1), strip)
+		if err == nil && p.opts.maxOIDLength > 0 {
+			err = validateOIDs(f, p.opts.maxOIDLength)
+		}
+		if err != nil {
+			return nil, p.Errorf(1, "combined file header: %v", err)
+		}
+
+		if err := p.Next(); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+
+		if end {
+			break
+		}
+	}
+
+	return f, nil
+}
+
 func (p *parser) ParseTraditionalFileHeader() (*File, error) {
 	const shortestValidFragHeader = "@@ -1 +1 @@\n"
 	const (
@@ -133,16 +280,70 @@ func (p *parser) ParseTraditionalFileHeader() (*File, error) {
 		return nil, err
 	}
 
-	oldName, _, err := parseName(oldLine[len(oldPrefix):], '\t', 0)
+	strip := p.traditionalStripLevel()
+
+	oldName, _, err := parseName(oldLine[len(oldPrefix):], '\t', strip)
+	if err != nil {
+		return nil, p.Errorf(0, "file header: %v", err)
+	}
+
+	newName, _, err := parseName(newLine[len(newPrefix):], '\t', strip)
+	if err != nil {
+		return nil, p.Errorf(1, "file header: %v", err)
+	}
+
+	return newTraditionalFile(oldLine, oldName, newLine, newName), nil
+}
+
+// ParseContextFileHeader parses the header of a file in the "context" diff
+// format, generated by `diff -c` and other tools. Fragments in this format
+// are parsed by ParseContextFragments.
+func (p *parser) ParseContextFileHeader() (*File, error) {
+	const (
+		oldPrefix = "*** "
+		newPrefix = "--- "
+	)
+
+	oldLine, newLine := p.Line(0), p.Line(1)
+
+	if !strings.HasPrefix(oldLine, oldPrefix) || !strings.HasPrefix(newLine, newPrefix) {
+		return nil, nil
+	}
+	// heuristic: only a file header if followed by a hunk separator
+	if !strings.HasPrefix(p.Line(2), contextHunkSeparator) {
+		return nil, nil
+	}
+
+	strip := p.traditionalStripLevel()
+
+	oldName, _, err := parseName(oldLine[len(oldPrefix):], '\t', strip)
 	if err != nil {
 		return nil, p.Errorf(0, "file header: %v", err)
 	}
 
-	newName, _, err := parseName(newLine[len(newPrefix):], '\t', 0)
+	newName, _, err := parseName(newLine[len(newPrefix):], '\t', strip)
 	if err != nil {
 		return nil, p.Errorf(1, "file header: %v", err)
 	}
 
+	// advance past the first two lines so parser is after the header
+	// no EOF check needed because we know there are >=3 valid lines
+	if err := p.Next(); err != nil {
+		return nil, err
+	}
+	if err := p.Next(); err != nil {
+		return nil, err
+	}
+
+	return newTraditionalFile(oldLine, oldName, newLine, newName), nil
+}
+
+// newTraditionalFile creates a File from the names and header lines of a
+// patch that was not generated by Git, which does not explicitly mark new or
+// deleted files. Usually, both names refer to the same file and the File uses
+// one of them for both names. Absolute paths in different directories, as
+// produced by `diff -u /path/to/a/file /path/to/b/file`, are kept separate.
+func newTraditionalFile(oldLine, oldName, newLine, newName string) *File {
 	f := &File{}
 	switch {
 	case oldName == devNull || hasEpochTimestamp(oldLine):
@@ -151,6 +352,9 @@ func (p *parser) ParseTraditionalFileHeader() (*File, error) {
 	case newName == devNull || hasEpochTimestamp(newLine):
 		f.IsDelete = true
 		f.OldName = oldName
+	case isAbs(oldName) && isAbs(newName) && path.Dir(oldName) != path.Dir(newName):
+		f.OldName = oldName
+		f.NewName = newName
 	default:
 		// if old name is a prefix of new name, use that instead
 		// this avoids picking variants like "file.bak" or "file~"
@@ -162,16 +366,16 @@ func (p *parser) ParseTraditionalFileHeader() (*File, error) {
 			f.NewName = newName
 		}
 	}
-
-	return f, nil
+	return f
 }
 
 // parseGitHeaderName extracts a default file name from the Git file header
 // line. This is required for mode-only changes and creation/deletion of empty
 // files. Other types of patch include the file name(s) in the header data.
 // If the names in the header do not match because the patch is a rename,
-// return an empty default name.
-func parseGitHeaderName(header string) (string, error) {
+// return an empty default name. The strip argument is the number of leading
+// directory components to remove from each name before comparing them.
+func parseGitHeaderName(header string, strip int) (string, error) {
 	header = strings.TrimSuffix(header, "\n")
 	if len(header) == 0 {
 		return "", nil
@@ -232,9 +436,9 @@ func parseGitHeaderName(header string) (string, error) {
 		}
 	}
 
-	first = trimTreePrefix(first, 1)
+	first = trimTreePrefix(first, strip)
 	if second != "" {
-		if first == trimTreePrefix(second, 1) {
+		if first == trimTreePrefix(second, strip) {
 			return first, nil
 		}
 		return "", nil
@@ -248,7 +452,7 @@ func parseGitHeaderName(header string) (string, error) {
 		if !isSpace(first[i]) {
 			continue
 		}
-		second = trimTreePrefix(first[i+1:], 1)
+		second = trimTreePrefix(first[i+1:], strip)
 		if name := first[:i]; name == second {
 			return name, nil
 		}
@@ -256,10 +460,49 @@ func parseGitHeaderName(header string) (string, error) {
 	return "", nil
 }
 
+// parseGitHeaderNames parses two different names from the header line of a
+// Git file header. If the names are not quoted, the line must contain exactly
+// one space to separate them. It returns false if it cannot find two names.
+func parseGitHeaderNames(header string, strip int) (oldName, newName string, ok bool) {
+	header = strings.TrimSuffix(header, "\n")
+
+	var first, second string
+	if header != "" && header[0] == '"' {
+		name, n, err := parseQuotedName(header)
+		if err != nil || n >= len(header) || !isSpace(header[n]) {
+			return "", "", false
+		}
+		first, second = name, strings.TrimLeft(header[n:], " \t")
+	} else {
+		sep := strings.LastIndex(header, " \"")
+		if sep < 0 {
+			if strings.Count(header, " ") != 1 {
+				return "", "", false
+			}
+			sep = strings.IndexByte(header, ' ')
+		}
+		first, second = header[:sep], header[sep+1:]
+	}
+
+	if second != "" && second[0] == '"' {
+		name, n, err := parseQuotedName(second)
+		if err != nil || n != len(second) {
+			return "", "", false
+		}
+		second = name
+	}
+
+	first, second = trimTreePrefix(first, strip), trimTreePrefix(second, strip)
+	if first == "" || second == "" {
+		return "", "", false
+	}
+	return first, second, true
+}
+
 // parseGitHeaderData parses a single line of metadata from a Git file header.
 // It returns true when header parsing is complete; in that case, line was the
 // first line of non-header content.
-func parseGitHeaderData(f *File, line, defaultName string) (end bool, err error) {
+func parseGitHeaderData(f *File, line, defaultName string, strip int) (end bool, err error) {
 	if len(line) > 0 && line[len(line)-1] == '\n' {
 		line = line[:len(line)-1]
 	}
@@ -267,7 +510,7 @@ func parseGitHeaderData(f *File, line, defaultName string) (end bool, err error)
 	for _, hdr := range []struct {
 		prefix string
 		end    bool
-		parse  func(*File, string, string) error
+		parse  func(*File, string, string, int) error
 	}{
 		{"@@ -", true, nil},
 		{"--- ", false, parseGitHeaderOldName},
@@ -283,12 +526,12 @@ func parseGitHeaderData(f *File, line, defaultName string) (end bool, err error)
 		{"rename from ", false, parseGitHeaderRenameFrom},
 		{"rename to ", false, parseGitHeaderRenameTo},
 		{"similarity index ", false, parseGitHeaderScore},
-		{"dissimilarity index ", false, parseGitHeaderScore},
+		{"dissimilarity index ", false, parseGitHeaderDissimilarity},
 		{"index ", false, parseGitHeaderIndex},
 	} {
 		if strings.HasPrefix(line, hdr.prefix) {
 			if hdr.parse != nil {
-				err = hdr.parse(f, line[len(hdr.prefix):], defaultName)
+				err = hdr.parse(f, line[len(hdr.prefix):], defaultName, strip)
 			}
 			return hdr.end, err
 		}
@@ -299,8 +542,84 @@ func parseGitHeaderData(f *File, line, defaultName string) (end bool, err error)
 	return true, nil
 }
 
-func parseGitHeaderOldName(f *File, line, defaultName string) error {
-	name, _, err := parseName(line, '\t', 1)
+// parseCombinedHeaderData parses a single line of metadata from a combined
+// diff file header. It returns true when header parsing is complete; in that
+// case, line was the first line of non-header content.
+func parseCombinedHeaderData(f *File, line string, strip int) (end bool, err error) {
+	if len(line) > 0 && line[len(line)-1] == '\n' {
+		line = line[:len(line)-1]
+	}
+
+	for _, hdr := range []struct {
+		prefix string
+		end    bool
+		parse  func(*File, string, string, int) error
+	}{
+		{"@@", true, nil},
+		{"--- ", false, parseGitHeaderOldName},
+		{"+++ ", false, parseGitHeaderNewName},
+		{"index ", false, parseCombinedHeaderIndex},
+		{"mode ", false, parseCombinedHeaderModes},
+		{"new file mode ", false, parseCombinedHeaderCreatedMode},
+		{"deleted file mode ", false, parseCombinedHeaderDeletedModes},
+	} {
+		if strings.HasPrefix(line, hdr.prefix) {
+			if hdr.parse != nil {
+				err = hdr.parse(f, line[len(hdr.prefix):], "", strip)
+			}
+			return hdr.end, err
+		}
+	}
+
+	return true, nil
+}
+
+func parseCombinedHeaderIndex(f *File, line, defaultName string, strip int) error {
+	const sep = ".."
+
+	oids := strings.SplitN(line, sep, 2)
+	if len(oids) < 2 {
+		return fmt.Errorf("invalid index line: missing %q", sep)
+	}
+	f.ParentOIDPrefixes = strings.Split(oids[0], ",")
+	f.OldOIDPrefix, f.NewOIDPrefix = f.ParentOIDPrefixes[0], oids[1]
+	return nil
+}
+
+func parseCombinedHeaderModes(f *File, line, defaultName string, strip int) (err error) {
+	const sep = ".."
+
+	modes := strings.SplitN(line, sep, 2)
+	if len(modes) < 2 {
+		return fmt.Errorf("invalid mode line: missing %q", sep)
+	}
+	if f.ParentModes, err = parseModeList(modes[0]); err != nil {
+		return err
+	}
+	f.OldMode = f.ParentModes[0]
+	f.NewMode, err = parseMode(strings.TrimSpace(modes[1]))
+	return
+}
+
+func parseCombinedHeaderCreatedMode(f *File, line, defaultName string, strip int) (err error) {
+	f.IsNew = true
+	f.OldName = ""
+	f.NewMode, err = parseMode(strings.TrimSpace(line))
+	return
+}
+
+func parseCombinedHeaderDeletedModes(f *File, line, defaultName string, strip int) (err error) {
+	f.IsDelete = true
+	f.NewName = ""
+	if f.ParentModes, err = parseModeList(line); err != nil {
+		return err
+	}
+	f.OldMode = f.ParentModes[0]
+	return nil
+}
+
+func parseGitHeaderOldName(f *File, line, defaultName string, strip int) error {
+	name, _, err := parseName(line, '\t', strip)
 	if err != nil {
 		return err
 	}
@@ -311,8 +630,8 @@ func parseGitHeaderOldName(f *File, line, defaultName string) error {
 	return verifyGitHeaderName(name, f.OldName, f.IsNew, "old")
 }
 
-func parseGitHeaderNewName(f *File, line, defaultName string) error {
-	name, _, err := parseName(line, '\t', 1)
+func parseGitHeaderNewName(f *File, line, defaultName string, strip int) error {
+	name, _, err := parseName(line, '\t', strip)
 	if err != nil {
 		return err
 	}
@@ -323,81 +642,136 @@ func parseGitHeaderNewName(f *File, line, defaultName string) error {
 	return verifyGitHeaderName(name, f.NewName, f.IsDelete, "new")
 }
 
-func parseGitHeaderOldMode(f *File, line, defaultName string) (err error) {
+func parseGitHeaderOldMode(f *File, line, defaultName string, strip int) (err error) {
 	f.OldMode, err = parseMode(strings.TrimSpace(line))
 	return
 }
 
-func parseGitHeaderNewMode(f *File, line, defaultName string) (err error) {
+func parseGitHeaderNewMode(f *File, line, defaultName string, strip int) (err error) {
 	f.NewMode, err = parseMode(strings.TrimSpace(line))
 	return
 }
 
-func parseGitHeaderDeletedMode(f *File, line, defaultName string) error {
+func parseGitHeaderDeletedMode(f *File, line, defaultName string, strip int) error {
 	f.IsDelete = true
 	f.OldName = defaultName
-	return parseGitHeaderOldMode(f, line, defaultName)
+	return parseGitHeaderOldMode(f, line, defaultName, strip)
 }
 
-func parseGitHeaderCreatedMode(f *File, line, defaultName string) error {
+func parseGitHeaderCreatedMode(f *File, line, defaultName string, strip int) error {
 	f.IsNew = true
 	f.NewName = defaultName
-	return parseGitHeaderNewMode(f, line, defaultName)
+	return parseGitHeaderNewMode(f, line, defaultName, strip)
 }
 
-func parseGitHeaderCopyFrom(f *File, line, defaultName string) (err error) {
+func parseGitHeaderCopyFrom(f *File, line, defaultName string, strip int) (err error) {
 	f.IsCopy = true
 	f.OldName, _, err = parseName(line, 0, 0)
 	return
 }
 
-func parseGitHeaderCopyTo(f *File, line, defaultName string) (err error) {
+func parseGitHeaderCopyTo(f *File, line, defaultName string, strip int) (err error) {
 	f.IsCopy = true
 	f.NewName, _, err = parseName(line, 0, 0)
 	return
 }
 
-func parseGitHeaderRenameFrom(f *File, line, defaultName string) (err error) {
+func parseGitHeaderRenameFrom(f *File, line, defaultName string, strip int) (err error) {
 	f.IsRename = true
 	f.OldName, _, err = parseName(line, 0, 0)
 	return
 }
 
-func parseGitHeaderRenameTo(f *File, line, defaultName string) (err error) {
+func parseGitHeaderRenameTo(f *File, line, defaultName string, strip int) (err error) {
 	f.IsRename = true
 	f.NewName, _, err = parseName(line, 0, 0)
 	return
 }
 
-func parseGitHeaderScore(f *File, line, defaultName string) error {
+func parseGitHeaderScore(f *File, line, defaultName string, strip int) error {
+	score, err := parseScore(line)
+	if err == nil && score <= 100 {
+		f.Score = score
+	}
+	return err
+}
+
+func parseGitHeaderDissimilarity(f *File, line, defaultName string, strip int) error {
+	score, err := parseScore(line)
+	if err == nil && score <= 100 {
+		f.Dissimilarity = score
+	}
+	return err
+}
+
+func parseScore(line string) (int, error) {
 	score, err := strconv.ParseInt(strings.TrimSuffix(line, "%"), 10, 32)
 	if err != nil {
 		nerr := err.(*strconv.NumError)
-		return fmt.Errorf("invalid score line: %v", nerr.Err)
+		return 0, fmt.Errorf("invalid score line: %v", nerr.Err)
 	}
-	if score <= 100 {
-		f.Score = int(score)
-	}
-	return nil
+	return int(score), nil
 }
 
-func parseGitHeaderIndex(f *File, line, defaultName string) error {
+func parseGitHeaderIndex(f *File, line, defaultName string, strip int) error {
 	const sep = ".."
 
 	// note that git stops parsing if the OIDs are too long to be valid
 	// checking this requires knowing if the repository uses SHA1 or SHA256
 	// hashes, which we don't know, so we just skip that check
 
-	parts := strings.SplitN(line, " ", 2)
+	parts := strings.SplitN(strings.TrimLeft(line, " "), " ", 2)
 	oids := strings.SplitN(parts[0], sep, 2)
 
-	if len(oids) < 2 {
+	switch {
+	case len(oids) == 2:
+		f.OldOIDPrefix, f.NewOIDPrefix = oids[0], oids[1]
+	case len(parts) == 1 && isIndexMode(parts[0]):
+		// some tools write index lines with only a mode
+		parts = []string{"", parts[0]}
+	case parts[0] != "":
 		return fmt.Errorf("invalid index line: missing %q", sep)
 	}
-	f.OldOIDPrefix, f.NewOIDPrefix = oids[0], oids[1]
 
 	if len(parts) > 1 {
-		return parseGitHeaderOldMode(f, parts[1], defaultName)
+		mode, err := parseMode(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return err
+		}
+		// "old mode" and "deleted file mode" lines take precedence, even if
+		// they appear before the index line
+		if f.OldMode == 0 {
+			f.OldMode = mode
+		}
+	}
+	return nil
+}
+
+// isIndexMode returns true if s is a file mode that appears on an index line
+// with no object IDs.
+func isIndexMode(s string) bool {
+	if len(s) != 6 {
+		return false
+	}
+	_, err := parseMode(s)
+	return err == nil
+}
+
+// validateOIDs checks that the object IDs in f are hexadecimal strings with no
+// more than maxLen characters.
+func validateOIDs(f *File, maxLen int) error {
+	oids := []string{f.OldOIDPrefix, f.NewOIDPrefix}
+	oids = append(oids, f.ParentOIDPrefixes...)
+
+	for _, oid := range oids {
+		if len(oid) > maxLen {
+			return fmt.Errorf("invalid index line: object ID is longer than %d characters", maxLen)
+		}
+		for i := 0; i < len(oid); i++ {
+			if !isHex(oid[i]) {
+				return fmt.Errorf("invalid index line: object ID contains non-hex character %q", oid[i])
+			}
+		}
 	}
 	return nil
 }
@@ -411,6 +785,18 @@ func parseMode(s string) (os.FileMode, error) {
 	return os.FileMode(mode), nil
 }
 
+func parseModeList(s string) ([]os.FileMode, error) {
+	var modes []os.FileMode
+	for _, m := range strings.Split(s, ",") {
+		mode, err := parseMode(strings.TrimSpace(m))
+		if err != nil {
+			return nil, err
+		}
+		modes = append(modes, mode)
+	}
+	return modes, nil
+}
+
 // parseName extracts a file name from the start of a string and returns the
 // name and the index of the first character after the name. If the name is
 // unquoted and term is non-zero, parsing stops at the first occurrence of
@@ -541,6 +927,14 @@ func hasEpochTimestamp(s string) bool {
 	return true
 }
 
+func isAbs(name string) bool {
+	return strings.HasPrefix(name, "/")
+}
+
+func isHex(c byte) bool {
+	return ('0' <= c && c <= '9') || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F')
+}
+
 func isSpace(c byte) bool {
 	return c == ' ' || c == '\t' || c == '\n'
 }
@@ -96,6 +96,77 @@ rename to bar.txt
 				IsRename: true,
 			},
 		},
+		"modeChangeWithRename": {
+			Input: `diff --git a/foo.sh b/bar.sh
+old mode 100644
+new mode 100755
+similarity index 90%
+rename from foo.sh
+rename to bar.sh
+index 1c23fcc..40a1b33
+--- a/foo.sh
++++ b/bar.sh
+@@ -1,2 +1,2 @@
+`,
+			Output: &File{
+				OldName:      "foo.sh",
+				NewName:      "bar.sh",
+				OldMode:      os.FileMode(0100644),
+				NewMode:      os.FileMode(0100755),
+				OldOIDPrefix: "1c23fcc",
+				NewOIDPrefix: "40a1b33",
+				Score:        90,
+				IsRename:     true,
+			},
+		},
+		"renameOldNew": {
+			Input: `diff --git a/foo.txt b/bar.txt
+similarity index 100%
+rename old foo.txt
+rename new bar.txt
+`,
+			Output: &File{
+				OldName:  "foo.txt",
+				NewName:  "bar.txt",
+				Score:    100,
+				IsRename: true,
+			},
+		},
+		"renameWithModeChange": {
+			Input: `diff --git a/foo.sh b/bar.sh
+similarity index 100%
+rename from foo.sh
+rename to bar.sh
+old mode 100644
+new mode 100755
+`,
+			Output: &File{
+				OldName:  "foo.sh",
+				NewName:  "bar.sh",
+				OldMode:  os.FileMode(0100644),
+				NewMode:  os.FileMode(0100755),
+				Score:    100,
+				IsRename: true,
+			},
+		},
+		"modeChangeBeforeIndexWithMode": {
+			Input: `diff --git a/file.sh b/file.sh
+old mode 100644
+new mode 100755
+index 1c23fcc..40a1b33 100755
+--- a/file.sh
++++ b/file.sh
+@@ -1,2 +1,2 @@
+`,
+			Output: &File{
+				OldName:      "file.sh",
+				NewName:      "file.sh",
+				OldMode:      os.FileMode(0100644),
+				NewMode:      os.FileMode(0100755),
+				OldOIDPrefix: "1c23fcc",
+				NewOIDPrefix: "40a1b33",
+			},
+		},
 		"copy": {
 			Input: `diff --git a/file.txt b/copy.txt
 similarity index 100%
@@ -109,10 +180,34 @@ copy to copy.txt
 				IsCopy:  true,
 			},
 		},
-		"missingDefaultFilename": {
+		"modeChangeDifferentNames": {
 			Input: `diff --git a/foo.sh b/bar.sh
 old mode 100644
 new mode 100755
+`,
+			Output: &File{
+				OldName: "foo.sh",
+				NewName: "bar.sh",
+				OldMode: os.FileMode(0100644),
+				NewMode: os.FileMode(0100755),
+			},
+		},
+		"modeChangeDifferentQuotedNames": {
+			Input: `diff --git "a/foo bar.sh" "b/baz qux.sh"
+old mode 100644
+new mode 100755
+`,
+			Output: &File{
+				OldName: "foo bar.sh",
+				NewName: "baz qux.sh",
+				OldMode: os.FileMode(0100644),
+				NewMode: os.FileMode(0100755),
+			},
+		},
+		"missingDefaultFilename": {
+			Input: `diff --git a/foo bar.sh b/baz qux.sh
+old mode 100644
+new mode 100755
 `,
 			Err: true,
 		},
@@ -169,6 +264,93 @@ index deadbeef
 	}
 }
 
+func TestParseCombinedFileHeader(t *testing.T) {
+	tests := map[string]struct {
+		Input  string
+		Output *File
+		Err    bool
+	}{
+		"dense": {
+			Input: `diff --cc dir/file.txt
+index eb12e79,6e6d153..fd85031
+--- a/dir/file.txt
++++ b/dir/file.txt
+@@@ -1,5 -1,5 +1,6 @@@
+`,
+			Output: &File{
+				OldName:           "dir/file.txt",
+				NewName:           "dir/file.txt",
+				OldOIDPrefix:      "eb12e79",
+				NewOIDPrefix:      "fd85031",
+				ParentOIDPrefixes: []string{"eb12e79", "6e6d153"},
+				IsCombined:        true,
+			},
+		},
+		"full": {
+			Input: `diff --combined dir/file.txt
+index eb12e79,6e6d153..fd85031
+--- a/dir/file.txt
++++ b/dir/file.txt
+@@@ -1,5 -1,5 +1,6 @@@
+`,
+			Output: &File{
+				OldName:           "dir/file.txt",
+				NewName:           "dir/file.txt",
+				OldOIDPrefix:      "eb12e79",
+				NewOIDPrefix:      "fd85031",
+				ParentOIDPrefixes: []string{"eb12e79", "6e6d153"},
+				IsCombined:        true,
+			},
+		},
+		"quotedName": {
+			Input: `diff --combined "dir/file\twith\ttabs.txt"
+index eb12e79,6e6d153..fd85031
+`,
+			Output: &File{
+				OldName:           "dir/file\twith\ttabs.txt",
+				NewName:           "dir/file\twith\ttabs.txt",
+				OldOIDPrefix:      "eb12e79",
+				NewOIDPrefix:      "fd85031",
+				ParentOIDPrefixes: []string{"eb12e79", "6e6d153"},
+				IsCombined:        true,
+			},
+		},
+		"invalidName": {
+			Input: `diff --combined "dir/file.txt
+index eb12e79,6e6d153..fd85031
+`,
+			Err: true,
+		},
+		"notCombinedHeader": {
+			Input: `diff --git a/file.txt b/file.txt
+index 1c23fcc..40a1b33 100644
+`,
+			Output: nil,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := newTestParser(test.Input, true)
+
+			f, err := p.ParseCombinedFileHeader()
+			if test.Err {
+				if err == nil || err == io.EOF {
+					t.Fatalf("expected error parsing combined file header, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error parsing combined file header: %v", err)
+			}
+
+			if !reflect.DeepEqual(test.Output, f) {
+				t.Errorf("incorrect file\nexpected: %+v\n  actual: %+v", test.Output, f)
+			}
+		})
+	}
+}
+
 func TestParseTraditionalFileHeader(t *testing.T) {
 	tests := map[string]struct {
 		Input  string
@@ -235,6 +417,26 @@ func TestParseTraditionalFileHeader(t *testing.T) {
 				NewName: "dir/file.txt",
 			},
 		},
+		"absolutePathsInDifferentDirectories": {
+			Input: `--- /Users/user/project/rendered-1/templates/deployment.yaml	2021-06-10 12:00:00.000000000 -0700
++++ /Users/user/project/rendered-2/templates/deployment.yaml	2021-06-10 12:05:00.000000000 -0700
+@@ -1 +1 @@
+`,
+			Output: &File{
+				OldName: "/Users/user/project/rendered-1/templates/deployment.yaml",
+				NewName: "/Users/user/project/rendered-2/templates/deployment.yaml",
+			},
+		},
+		"absolutePathsInSameDirectory": {
+			Input: `--- /tmp/dir/file.txt.orig	2021-06-10 12:00:00.000000000 -0700
++++ /tmp/dir/file.txt	2021-06-10 12:05:00.000000000 -0700
+@@ -1 +1 @@
+`,
+			Output: &File{
+				OldName: "/tmp/dir/file.txt",
+				NewName: "/tmp/dir/file.txt",
+			},
+		},
 		"notTraditionalHeader": {
 			Input: `diff --git a/dir/file.txt b/dir/file.txt
 --- a/dir/file.txt
@@ -274,6 +476,103 @@ context line
 	}
 }
 
+func TestParseFileHeaderStripLevel(t *testing.T) {
+	tests := map[string]struct {
+		Input  string
+		Strip  int
+		Output *File
+	}{
+		"gitStripZero": {
+			Input: `diff --git a/dir/file.txt b/dir/file.txt
+index 1c23fcc..40a1b33 100644
+--- a/dir/file.txt
++++ b/dir/file.txt
+@@ -1 +1 @@
+`,
+			Strip: 0,
+			Output: &File{
+				OldName:      "a/dir/file.txt",
+				NewName:      "b/dir/file.txt",
+				OldMode:      os.FileMode(0100644),
+				OldOIDPrefix: "1c23fcc",
+				NewOIDPrefix: "40a1b33",
+				HeaderLine:   1,
+			},
+		},
+		"gitStripTwo": {
+			Input: `diff --git a/dir/file.txt b/dir/file.txt
+old mode 100644
+new mode 100755
+`,
+			Strip: 2,
+			Output: &File{
+				OldName:    "file.txt",
+				NewName:    "file.txt",
+				OldMode:    os.FileMode(0100644),
+				NewMode:    os.FileMode(0100755),
+				HeaderLine: 1,
+			},
+		},
+		"gitNewFileStripTwo": {
+			Input: `diff --git a/dir/file.txt b/dir/file.txt
+new file mode 100644
+index 0000000..f5711e4
+--- /dev/null
++++ b/dir/file.txt
+@@ -0,0 +1 @@
+`,
+			Strip: 2,
+			Output: &File{
+				NewName:      "file.txt",
+				NewMode:      os.FileMode(0100644),
+				OldOIDPrefix: "0000000",
+				NewOIDPrefix: "f5711e4",
+				IsNew:        true,
+				HeaderLine:   1,
+			},
+		},
+		"traditionalStripOne": {
+			Input: `--- a/dir/file.txt
++++ b/dir/file.txt
+@@ -1 +1 @@
+`,
+			Strip: 1,
+			Output: &File{
+				OldName:    "dir/file.txt",
+				NewName:    "dir/file.txt",
+				HeaderLine: 1,
+			},
+		},
+		"traditionalDeleteStripOne": {
+			Input: `--- a/dir/file.txt
++++ /dev/null
+@@ -1 +0,0 @@
+`,
+			Strip: 1,
+			Output: &File{
+				OldName:    "dir/file.txt",
+				IsDelete:   true,
+				HeaderLine: 1,
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := newTestParser(test.Input, true)
+			WithStripLevel(test.Strip)(&p.opts)
+
+			f, _, err := p.ParseNextFileHeader()
+			if err != nil {
+				t.Fatalf("unexpected error parsing file header: %v", err)
+			}
+			if !reflect.DeepEqual(test.Output, f) {
+				t.Errorf("incorrect file\nexpected: %+v\n  actual: %+v", test.Output, f)
+			}
+		})
+	}
+}
+
 func TestCleanName(t *testing.T) {
 	tests := map[string]struct {
 		Input  string
@@ -567,6 +866,20 @@ func TestParseGitHeaderData(t *testing.T) {
 				IsRename: true,
 			},
 		},
+		"renameOld": {
+			Line: "rename old dir/file.txt\n",
+			OutputFile: &File{
+				OldName:  "dir/file.txt",
+				IsRename: true,
+			},
+		},
+		"renameNew": {
+			Line: "rename new dir/file.txt\n",
+			OutputFile: &File{
+				NewName:  "dir/file.txt",
+				IsRename: true,
+			},
+		},
 		"similarityIndex": {
 			Line: "similarity index 88%\n",
 			OutputFile: &File{
@@ -583,6 +896,22 @@ func TestParseGitHeaderData(t *testing.T) {
 			Line: "similarity index 12ab%\n",
 			Err:  true,
 		},
+		"dissimilarityIndex": {
+			Line: "dissimilarity index 75%\n",
+			OutputFile: &File{
+				Dissimilarity: 75,
+			},
+		},
+		"dissimilarityIndexTooBig": {
+			Line: "dissimilarity index 9001%\n",
+			OutputFile: &File{
+				Dissimilarity: 0,
+			},
+		},
+		"dissimilarityIndexInvalid": {
+			Line: "dissimilarity index 12ab%\n",
+			Err:  true,
+		},
 		"indexFullSHA1AndMode": {
 			Line: "index 79c6d7f7b7e76c75b3d238f12fb1323f2333ba14..04fab916d8f938173cbb8b93469855f0e838f098 100644\n",
 			OutputFile: &File{
@@ -606,6 +935,57 @@ func TestParseGitHeaderData(t *testing.T) {
 				OldMode:      os.FileMode(0100644),
 			},
 		},
+		"indexAsymmetricAbbrevSHA1AndMode": {
+			Line: "index 79c6d7f..04fab9 100644\n",
+			OutputFile: &File{
+				OldOIDPrefix: "79c6d7f",
+				NewOIDPrefix: "04fab9",
+				OldMode:      os.FileMode(0100644),
+			},
+		},
+		"indexAbbrevAndFullSHA1": {
+			Line: "index 79c6d7f..04fab916d8f938173cbb8b93469855f0e838f098\n",
+			OutputFile: &File{
+				OldOIDPrefix: "79c6d7f",
+				NewOIDPrefix: "04fab916d8f938173cbb8b93469855f0e838f098",
+			},
+		},
+		"indexZeroOIDsAndMode": {
+			Line: "index 0000000..0000000 100644\n",
+			OutputFile: &File{
+				OldOIDPrefix: "0000000",
+				NewOIDPrefix: "0000000",
+				OldMode:      os.FileMode(0100644),
+			},
+		},
+		"indexEmptyOIDsAndMode": {
+			Line: "index .. 100644\n",
+			OutputFile: &File{
+				OldMode: os.FileMode(0100644),
+			},
+		},
+		"indexModeOnly": {
+			Line: "index 100644\n",
+			OutputFile: &File{
+				OldMode: os.FileMode(0100644),
+			},
+		},
+		"indexModeOnlyExtraSpace": {
+			Line: "index  100644\n",
+			OutputFile: &File{
+				OldMode: os.FileMode(0100644),
+			},
+		},
+		"indexEmpty": {
+			Line:       "index \n",
+			OutputFile: &File{},
+		},
+		"indexTruncatedNewOID": {
+			Line: "index 79c6d7..\n",
+			OutputFile: &File{
+				OldOIDPrefix: "79c6d7",
+			},
+		},
 		"indexInvalid": {
 			Line: "index 79c6d7f7b7e76c75b3d238f12fb1323f2333ba14\n",
 			Err:  true,
@@ -619,7 +999,7 @@ func TestParseGitHeaderData(t *testing.T) {
 				f = *test.InputFile
 			}
 
-			end, err := parseGitHeaderData(&f, test.Line, test.DefaultName)
+			end, err := parseGitHeaderData(&f, test.Line, test.DefaultName, 1)
 			if test.Err {
 				if err == nil || err == io.EOF {
 					t.Fatalf("expected error parsing header data, but got %v", err)
@@ -674,6 +1054,10 @@ func TestParseGitHeaderName(t *testing.T) {
 			Input:  `a/dir/file.txt "b/dir/file.txt"`,
 			Output: "dir/file.txt",
 		},
+		"matchingNamesWithNewlines": {
+			Input:  `"a/dir/new\nline.txt" "b/dir/new\nline.txt"`,
+			Output: "dir/new\nline.txt",
+		},
 		"noSecondName": {
 			Input:  "a/dir/foo.txt",
 			Output: "",
@@ -690,7 +1074,7 @@ func TestParseGitHeaderName(t *testing.T) {
 
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
-			output, err := parseGitHeaderName(test.Input)
+			output, err := parseGitHeaderName(test.Input, 1)
 			if test.Err {
 				if err == nil {
 					t.Fatalf("expected error parsing header name, but got nil")
@@ -2,26 +2,113 @@ package gitdiff
 
 import (
 	"bytes"
-	"compress/zlib"
 	"fmt"
 	"io"
 	"strconv"
+	"strings"
 )
 
+// A FormatOption modifies the behavior of Format.
+type FormatOption func(*formatOptions)
+
+// WithPrefixes sets the prefixes added to the old and new file names in the
+// "diff --git", "---", and "+++" lines. By default, the prefixes are "a/" and
+// "b/". Patches formatted with custom prefixes can be parsed using a strip
+// level that removes the prefixes.
+func WithPrefixes(oldPrefix, newPrefix string) FormatOption {
+	return func(opts *formatOptions) {
+		opts.oldPrefix = oldPrefix
+		opts.newPrefix = newPrefix
+	}
+}
+
+// WithNoPrefix omits prefixes from file names, like the --no-prefix flag in
+// Git. Patches formatted without prefixes can be parsed using a strip level
+// of 0.
+func WithNoPrefix() FormatOption {
+	return WithPrefixes("", "")
+}
+
+// WithQuotePath sets whether bytes greater than 0x7F in file names are
+// written as octal escapes, like the core.quotePath setting in Git. By
+// default, these bytes are escaped. If quote is false, UTF-8 names are written
+// literally and names are only quoted if they contain control characters,
+// double quotes, or backslashes.
+func WithQuotePath(quote bool) FormatOption {
+	return func(opts *formatOptions) {
+		opts.quotePath = quote
+	}
+}
+
+// WithFormatCompressor sets the Compressor used to compress the data in
+// binary patches. By default, the formatter uses the compress/zlib package,
+// so the encoded data likely differs from the data Git produces for the same
+// content. Use a Compressor that matches Git's zlib library to produce
+// identical output.
+func WithFormatCompressor(c Compressor) FormatOption {
+	return func(opts *formatOptions) {
+		opts.compressor = c
+	}
+}
+
+// WithCompressionLevel sets the zlib compression level used to compress the
+// data in binary patches, using the levels defined by the compress/zlib
+// package. Git uses zlib.BestSpeed by default, but because Go's
+// implementation of compression differs from the zlib C library, the encoded
+// data may still differ from the data Git produces. This option replaces any
+// Compressor set by WithFormatCompressor. By default, the formatter uses
+// zlib.DefaultCompression.
+func WithCompressionLevel(level int) FormatOption {
+	return func(opts *formatOptions) {
+		opts.compressor = zlibCompressor{level: level}
+	}
+}
+
+type formatOptions struct {
+	oldPrefix  string
+	newPrefix  string
+	quotePath  bool
+	compressor Compressor
+}
+
+func defaultFormatOptions() formatOptions {
+	return formatOptions{
+		oldPrefix: "a/",
+		newPrefix: "b/",
+		quotePath: true,
+	}
+}
+
+// Format writes a git diff representation of f to w. Without options, the
+// output is the same as f.String(). It returns the number of bytes written and
+// any error that occurred while writing.
+func Format(w io.Writer, f *File, options ...FormatOption) (int64, error) {
+	fm := newFormatter(w)
+	for _, opt := range options {
+		opt(&fm.opts)
+	}
+	fm.FormatFile(f)
+	return fm.n, fm.err
+}
+
 type formatter struct {
-	w   io.Writer
-	err error
+	w    io.Writer
+	n    int64
+	err  error
+	opts formatOptions
 }
 
 func newFormatter(w io.Writer) *formatter {
-	return &formatter{w: w}
+	return &formatter{w: w, opts: defaultFormatOptions()}
 }
 
 func (fm *formatter) Write(p []byte) (int, error) {
 	if fm.err != nil {
 		return len(p), nil
 	}
-	if _, err := fm.w.Write(p); err != nil {
+	n, err := fm.w.Write(p)
+	fm.n += int64(n)
+	if err != nil {
 		fm.err = err
 	}
 	return len(p), nil
@@ -41,6 +128,9 @@ func (fm *formatter) WriteQuotedName(s string) {
 	qpos := 0
 	for i := 0; i < len(s); i++ {
 		ch := s[i]
+		if ch >= 0x80 && !fm.opts.quotePath {
+			continue
+		}
 		if q, quoted := quoteByte(ch); quoted {
 			if qpos == 0 {
 				fm.WriteByte('"')
@@ -84,6 +174,11 @@ func quoteByte(b byte) ([]byte, bool) {
 }
 
 func (fm *formatter) FormatFile(f *File) {
+	if f.IsCombined {
+		fm.FormatCombinedFile(f)
+		return
+	}
+
 	fm.WriteString("diff --git ")
 
 	var aName, bName string
@@ -101,9 +196,9 @@ func (fm *formatter) FormatFile(f *File) {
 		bName = f.NewName
 	}
 
-	fm.WriteQuotedName("a/" + aName)
+	fm.WriteQuotedName(fm.opts.oldPrefix + aName)
 	fm.WriteByte(' ')
-	fm.WriteQuotedName("b/" + bName)
+	fm.WriteQuotedName(fm.opts.newPrefix + bName)
 	fm.WriteByte('\n')
 
 	if f.OldMode != 0 {
@@ -123,11 +218,10 @@ func (fm *formatter) FormatFile(f *File) {
 	}
 
 	if f.Score > 0 {
-		if f.IsCopy || f.IsRename {
-			fmt.Fprintf(fm, "similarity index %d%%\n", f.Score)
-		} else {
-			fmt.Fprintf(fm, "dissimilarity index %d%%\n", f.Score)
-		}
+		fmt.Fprintf(fm, "similarity index %d%%\n", f.Score)
+	}
+	if f.Dissimilarity > 0 {
+		fmt.Fprintf(fm, "dissimilarity index %d%%\n", f.Dissimilarity)
 	}
 
 	if f.IsCopy {
@@ -156,40 +250,109 @@ func (fm *formatter) FormatFile(f *File) {
 		}
 	}
 
-	if f.OldOIDPrefix != "" && f.NewOIDPrefix != "" {
-		fmt.Fprintf(fm, "index %s..%s", f.OldOIDPrefix, f.NewOIDPrefix)
+	// Mode is only included on the index line when it is not changing
+	indexMode := f.OldMode != 0 && ((f.NewMode == 0 && !f.IsDelete) || f.OldMode == f.NewMode)
 
-		// Mode is only included on the index line when it is not changing
-		if f.OldMode != 0 && ((f.NewMode == 0 && !f.IsDelete) || f.OldMode == f.NewMode) {
+	switch {
+	case f.OldOIDPrefix != "" || f.NewOIDPrefix != "":
+		fmt.Fprintf(fm, "index %s..%s", f.OldOIDPrefix, f.NewOIDPrefix)
+		if indexMode {
 			fmt.Fprintf(fm, " %o", f.OldMode)
 		}
-
 		fm.WriteByte('\n')
+
+	case indexMode:
+		// some tools write index lines with only a mode
+		fmt.Fprintf(fm, "index %o\n", f.OldMode)
 	}
 
 	if f.IsBinary {
-		if f.BinaryFragment == nil {
+		if f.BinaryFragment == nil && f.ReverseBinaryFragment == nil {
 			fm.WriteString("Binary files ")
-			fm.WriteQuotedName("a/" + aName)
+			fm.WriteQuotedName(fm.opts.oldPrefix + aName)
 			fm.WriteString(" and ")
-			fm.WriteQuotedName("b/" + bName)
+			fm.WriteQuotedName(fm.opts.newPrefix + bName)
 			fm.WriteString(" differ\n")
 		} else {
 			fm.WriteString("GIT binary patch\n")
-			fm.FormatBinaryFragment(f.BinaryFragment)
+			if f.BinaryFragment != nil {
+				fm.FormatBinaryFragment(f.BinaryFragment)
+			} else {
+				// an empty line in place of the forward fragment
+				fm.WriteByte('\n')
+			}
 			if f.ReverseBinaryFragment != nil {
 				fm.FormatBinaryFragment(f.ReverseBinaryFragment)
 			}
 		}
 	}
 
+	fm.FormatTextFragments(f)
+}
+
+// FormatCombinedFile formats a file from a combined diff. Unlike normal
+// diffs, the header does not include copy or rename information and the index
+// and mode lines list values for each parent.
+func (fm *formatter) FormatCombinedFile(f *File) {
+	name := f.NewName
+	if name == "" {
+		name = f.OldName
+	}
+
+	fm.WriteString("diff --cc ")
+	fm.WriteQuotedName(name)
+	fm.WriteByte('\n')
+
+	if len(f.ParentOIDPrefixes) > 0 && f.NewOIDPrefix != "" {
+		fm.WriteString("index ")
+		for i, oid := range f.ParentOIDPrefixes {
+			if i > 0 {
+				fm.WriteByte(',')
+			}
+			fm.WriteString(oid)
+		}
+		fmt.Fprintf(fm, "..%s\n", f.NewOIDPrefix)
+	}
+
+	switch {
+	case f.IsNew:
+		if f.NewMode != 0 {
+			fmt.Fprintf(fm, "new file mode %o\n", f.NewMode)
+		}
+	case len(f.ParentModes) > 0:
+		if f.IsDelete {
+			fm.WriteString("deleted file ")
+		}
+		fm.WriteString("mode ")
+		for i, mode := range f.ParentModes {
+			if i > 0 {
+				fm.WriteByte(',')
+			}
+			fmt.Fprintf(fm, "%o", mode)
+		}
+		if !f.IsDelete {
+			fmt.Fprintf(fm, "..%o", f.NewMode)
+		}
+		fm.WriteByte('\n')
+	}
+
+	if f.IsBinary {
+		fm.WriteString("Binary files differ\n")
+	}
+
+	fm.FormatTextFragments(f)
+}
+
+// FormatTextFragments formats the "---" and "+++" lines and all text
+// fragments of a file.
+func (fm *formatter) FormatTextFragments(f *File) {
 	// The "---" and "+++" lines only appear for text patches with fragments
 	if len(f.TextFragments) > 0 {
 		fm.WriteString("--- ")
 		if f.OldName == "" {
 			fm.WriteString("/dev/null")
 		} else {
-			fm.WriteQuotedName("a/" + f.OldName)
+			fm.WriteQuotedName(fm.opts.oldPrefix + f.OldName)
 		}
 		fm.WriteByte('\n')
 
@@ -197,7 +360,7 @@ func (fm *formatter) FormatFile(f *File) {
 		if f.NewName == "" {
 			fm.WriteString("/dev/null")
 		} else {
-			fm.WriteQuotedName("b/" + f.NewName)
+			fm.WriteQuotedName(fm.opts.newPrefix + f.NewName)
 		}
 		fm.WriteByte('\n')
 
@@ -212,16 +375,33 @@ func (fm *formatter) FormatTextFragment(f *TextFragment) {
 	fm.WriteByte('\n')
 
 	for _, line := range f.Lines {
-		fm.WriteString(line.Op.String())
+		for _, op := range line.ParentOps {
+			fm.WriteString(op.String())
+		}
+		if len(line.ParentOps) == 0 && !(line.BareContext && line.Op == OpContext && line.Line == "\n") {
+			fm.WriteString(line.Op.String())
+		}
 		fm.WriteString(line.Line)
 		if line.NoEOL() {
-			fm.WriteString("\n\\ No newline at end of file\n")
+			fm.WriteByte('\n')
+		}
+		if line.NoEOL() || line.NoNewlineAtEOF {
+			fm.WriteString("\\ No newline at end of file\n")
 		}
 	}
 }
 
 func (fm *formatter) FormatTextFragmentHeader(f *TextFragment) {
-	fmt.Fprintf(fm, "@@ -%d,%d +%d,%d @@", f.OldPosition, f.OldLines, f.NewPosition, f.NewLines)
+	if len(f.ParentRanges) > 0 {
+		mark := strings.Repeat("@", len(f.ParentRanges)+1)
+		fm.WriteString(mark)
+		for _, r := range f.ParentRanges {
+			fmt.Fprintf(fm, " -%d,%d", r.Position, r.Lines)
+		}
+		fmt.Fprintf(fm, " +%d,%d %s", f.NewPosition, f.NewLines, mark)
+	} else {
+		fmt.Fprintf(fm, "@@ -%d,%d +%d,%d @@", f.OldPosition, f.OldLines, f.NewPosition, f.NewLines)
+	}
 	if f.Comment != "" {
 		fm.WriteByte(' ')
 		fm.WriteString(f.Comment)
@@ -242,7 +422,13 @@ func (fm *formatter) FormatBinaryFragment(f *BinaryFragment) {
 	fm.Write(strconv.AppendInt(nil, f.Size, 10))
 	fm.WriteByte('\n')
 
-	data := deflateBinaryChunk(f.Data)
+	data, err := deflateBinaryChunk(fm.opts.compressor, f.Data)
+	if err != nil {
+		if fm.err == nil {
+			fm.err = err
+		}
+		return
+	}
 	n := (len(data) / maxBytesPerLine) * maxBytesPerLine
 
 	buf := make([]byte, base85Len(maxBytesPerLine))
@@ -270,12 +456,21 @@ func (fm *formatter) FormatBinaryFragment(f *BinaryFragment) {
 	fm.WriteByte('\n')
 }
 
-func deflateBinaryChunk(data []byte) []byte {
-	var b bytes.Buffer
-
-	zw := zlib.NewWriter(&b)
-	_, _ = zw.Write(data)
-	_ = zw.Close()
+func deflateBinaryChunk(c Compressor, data []byte) ([]byte, error) {
+	if c == nil {
+		c = defaultCompressor
+	}
 
-	return b.Bytes()
+	var b bytes.Buffer
+	zw, err := c.NewWriter(&b)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
 }
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
 	"slices"
 	"testing"
 )
@@ -20,18 +21,27 @@ func TestFormatRoundtrip(t *testing.T) {
 		{File: "mode.patch"},
 		{File: "mode_modify.patch"},
 		{File: "modify.patch"},
+		{File: "modify_asymmetric_oids.patch"},
+		{File: "modify_index_mode_only.patch"},
+		{File: "modify_no_newline.patch"},
+		{File: "modify_rewrite.patch"},
+		{File: "modify_zero_oids.patch"},
 		{File: "new.patch"},
 		{File: "new_empty.patch"},
 		{File: "new_mode.patch"},
 		{File: "rename.patch"},
 		{File: "rename_modify.patch"},
+		{File: "combined.patch"},
+		{File: "combined_mode.patch"},
 
 		// Due to differences between Go's 'encoding/zlib' package and the zlib
 		// C library, binary patches cannot be compared directly as the patch
-		// data is slightly different when re-encoded by Go.
+		// data is slightly different when re-encoded by Go, even when using
+		// the same compression level as Git.
 		{File: "binary_modify.patch", SkipTextCompare: true},
 		{File: "binary_new.patch", SkipTextCompare: true},
 		{File: "binary_modify_nodata.patch"},
+		{File: "binary_modify_reverse_only.patch", SkipTextCompare: true},
 	}
 
 	for _, patch := range patches {
@@ -52,6 +62,9 @@ func TestFormatRoundtrip(t *testing.T) {
 
 			reparsed := assertParseSingleFile(t, []byte(str), "formatted patch")
 			assertFilesEqual(t, original, reparsed)
+			if !original.Equal(reparsed) {
+				t.Errorf("reparsed file is not equal to original file")
+			}
 		})
 	}
 }
@@ -82,6 +95,15 @@ func assertFilesEqual(t *testing.T, expected, actual *File) {
 	assertEqual(t, expected.OldOIDPrefix, actual.OldOIDPrefix, "OldOIDPrefix")
 	assertEqual(t, expected.NewOIDPrefix, actual.NewOIDPrefix, "NewOIDPrefix")
 	assertEqual(t, expected.Score, actual.Score, "Score")
+	assertEqual(t, expected.Dissimilarity, actual.Dissimilarity, "Dissimilarity")
+
+	assertEqual(t, expected.IsCombined, actual.IsCombined, "IsCombined")
+	if !slices.Equal(expected.ParentOIDPrefixes, actual.ParentOIDPrefixes) {
+		t.Errorf("ParentOIDPrefixes: expected %#v, actual %#v", expected.ParentOIDPrefixes, actual.ParentOIDPrefixes)
+	}
+	if !slices.Equal(expected.ParentModes, actual.ParentModes) {
+		t.Errorf("ParentModes: expected %#v, actual %#v", expected.ParentModes, actual.ParentModes)
+	}
 
 	if len(expected.TextFragments) == len(actual.TextFragments) {
 		for i := range expected.TextFragments {
@@ -103,7 +125,10 @@ func assertFilesEqual(t *testing.T, expected, actual *File) {
 			assertEqual(t, ef.LeadingContext, af.LeadingContext, prefix+"LeadingContext")
 			assertEqual(t, ef.TrailingContext, af.TrailingContext, prefix+"TrailingContext")
 
-			if !slices.Equal(ef.Lines, af.Lines) {
+			if !slices.Equal(ef.ParentRanges, af.ParentRanges) {
+				t.Errorf("%sParentRanges: expected %#v, actual %#v", prefix, ef.ParentRanges, af.ParentRanges)
+			}
+			if !reflect.DeepEqual(ef.Lines, af.Lines) {
 				t.Errorf("%sLines: expected %#v, actual %#v", prefix, ef.Lines, af.Lines)
 			}
 		}
@@ -1,6 +1,12 @@
 package gitdiff
 
 import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -26,3 +32,438 @@ func TestFormatter_WriteQuotedName(t *testing.T) {
 		}
 	}
 }
+
+func TestFormatQuotePath(t *testing.T) {
+	f := &File{
+		OldName:  "docs/café menu.txt",
+		NewName:  "docs/crème brûlée.txt",
+		IsRename: true,
+		Score:    90,
+		TextFragments: []*TextFragment{
+			{
+				OldPosition:  1,
+				OldLines:     1,
+				NewPosition:  1,
+				NewLines:     1,
+				LinesAdded:   1,
+				LinesDeleted: 1,
+				Lines: []Line{
+					{Op: OpDelete, Line: "old\n", OldLineNo: 1},
+					{Op: OpAdd, Line: "new\n", NewLineNo: 1},
+				},
+			},
+		},
+	}
+
+	var b strings.Builder
+	if _, err := Format(&b, f, WithQuotePath(false)); err != nil {
+		t.Fatalf("unexpected error formatting file: %v", err)
+	}
+
+	expected := `diff --git a/docs/café menu.txt b/docs/crème brûlée.txt
+similarity index 90%
+rename from docs/café menu.txt
+rename to docs/crème brûlée.txt
+--- a/docs/café menu.txt
++++ b/docs/crème brûlée.txt
+@@ -1,1 +1,1 @@
+-old
++new
+`
+	if b.String() != expected {
+		t.Errorf("incorrect patch text\nexpected: %q\n  actual: %q\n", expected, b.String())
+	}
+
+	reparsed := assertParseSingleFile(t, []byte(b.String()), "formatted patch")
+	assertFilesEqual(t, f, reparsed)
+
+	var q strings.Builder
+	fm := newFormatter(&q)
+	WithQuotePath(false)(&fm.opts)
+	fm.WriteQuotedName("tab\tsnowman \u2603")
+	if expected := "\"tab\\tsnowman \u2603\""; q.String() != expected {
+		t.Errorf("expected %q, got %q", expected, q.String())
+	}
+}
+
+func TestFormatNewlineNames(t *testing.T) {
+	frag := &TextFragment{
+		OldPosition:  1,
+		OldLines:     1,
+		NewPosition:  1,
+		NewLines:     1,
+		LinesAdded:   1,
+		LinesDeleted: 1,
+		Lines: []Line{
+			{Op: OpDelete, Line: "old\n", OldLineNo: 1},
+			{Op: OpAdd, Line: "new\n", NewLineNo: 1},
+		},
+	}
+
+	tests := map[string]*File{
+		"modify": {
+			OldName:       "dir/new\nline.txt",
+			NewName:       "dir/new\nline.txt",
+			OldMode:       0o100644,
+			OldOIDPrefix:  "1c23fcc",
+			NewOIDPrefix:  "40a1b33",
+			TextFragments: []*TextFragment{frag},
+		},
+		"create": {
+			NewName: "new\nline.txt",
+			NewMode: 0o100644,
+			IsNew:   true,
+		},
+		"delete": {
+			OldName:  "new\nline.txt",
+			OldMode:  0o100644,
+			IsDelete: true,
+		},
+		"renameToNewline": {
+			OldName:  "old name.txt",
+			NewName:  "new\nline.txt",
+			IsRename: true,
+			Score:    100,
+		},
+		"renameWithNewlines": {
+			OldName:       "old\nline.txt",
+			NewName:       "new\nline.txt",
+			IsRename:      true,
+			Score:         90,
+			TextFragments: []*TextFragment{frag},
+		},
+		"modeChange": {
+			OldName: "a\nb",
+			NewName: "a\nb",
+			OldMode: 0o100644,
+			NewMode: 0o100755,
+		},
+	}
+
+	for name, f := range tests {
+		t.Run(name, func(t *testing.T) {
+			for _, quote := range []bool{true, false} {
+				var b strings.Builder
+				if _, err := Format(&b, f, WithQuotePath(quote)); err != nil {
+					t.Fatalf("unexpected error formatting file: %v", err)
+				}
+
+				reparsed := assertParseSingleFile(t, []byte(b.String()), "formatted patch")
+				assertFilesEqual(t, f, reparsed)
+			}
+		})
+	}
+}
+
+type storeCompressor struct {
+	readers, writers int
+}
+
+func (c *storeCompressor) NewReader(r io.Reader) (io.ReadCloser, error) {
+	c.readers++
+	return zlib.NewReader(r)
+}
+
+func (c *storeCompressor) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	c.writers++
+	return zlib.NewWriterLevel(w, zlib.NoCompression)
+}
+
+func TestFormatCompressor(t *testing.T) {
+	b, err := os.ReadFile(filepath.Join("testdata", "string", "binary_new.patch"))
+	if err != nil {
+		t.Fatalf("failed to read patch: %v", err)
+	}
+
+	var c storeCompressor
+	files, _, err := NewParser(WithCompressor(&c)).Parse(bytes.NewReader(b))
+	if err != nil {
+		t.Fatalf("unexpected error parsing patch: %v", err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("expected 1 file, but found %d", len(files))
+	}
+	if c.readers != 2 {
+		t.Errorf("incorrect number of readers: expected 2, actual %d", c.readers)
+	}
+
+	var out strings.Builder
+	if _, err := Format(&out, files[0], WithFormatCompressor(&c)); err != nil {
+		t.Fatalf("unexpected error formatting file: %v", err)
+	}
+	if c.writers != 2 {
+		t.Errorf("incorrect number of writers: expected 2, actual %d", c.writers)
+	}
+	if out.String() == files[0].String() {
+		t.Error("formatted patch with custom compressor is the same as the default")
+	}
+
+	reparsed := assertParseSingleFile(t, []byte(out.String()), "formatted patch")
+	if !bytes.Equal(files[0].BinaryFragment.Data, reparsed.BinaryFragment.Data) {
+		t.Error("incorrect binary data after formatting with custom compressor")
+	}
+}
+
+func TestFormatCompressionLevel(t *testing.T) {
+	b, err := os.ReadFile(filepath.Join("testdata", "string", "binary_new.patch"))
+	if err != nil {
+		t.Fatalf("failed to read patch: %v", err)
+	}
+	f := assertParseSingleFile(t, b, "patch")
+
+	tests := map[string]struct {
+		Level  int
+		Header []byte
+		Err    string
+	}{
+		"bestSpeed": {
+			Level:  zlib.BestSpeed,
+			Header: []byte{0x78, 0x01}, // matches the data in the patch from Git
+		},
+		"bestCompression": {
+			Level:  zlib.BestCompression,
+			Header: []byte{0x78, 0xDA},
+		},
+		"invalid": {
+			Level: 42,
+			Err:   "invalid compression level",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var out strings.Builder
+			_, err := Format(&out, f, WithCompressionLevel(test.Level))
+			if test.Err != "" {
+				assertError(t, test.Err, err, "formatting file")
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error formatting file: %v", err)
+			}
+
+			data, err := deflateBinaryChunk(zlibCompressor{level: test.Level}, f.BinaryFragment.Data)
+			if err != nil {
+				t.Fatalf("unexpected error compressing data: %v", err)
+			}
+			if !bytes.HasPrefix(data, test.Header) {
+				t.Errorf("incorrect zlib header: expected %x, actual %x", test.Header, data[:2])
+			}
+
+			reparsed := assertParseSingleFile(t, []byte(out.String()), "formatted patch")
+			if !bytes.Equal(f.BinaryFragment.Data, reparsed.BinaryFragment.Data) {
+				t.Error("incorrect binary data after formatting with compression level")
+			}
+		})
+	}
+}
+
+func TestFormatRenameOldNew(t *testing.T) {
+	input := `diff --git a/foo.txt b/bar.txt
+similarity index 100%
+rename old foo.txt
+rename new bar.txt
+`
+	expected := `diff --git a/foo.txt b/bar.txt
+similarity index 100%
+rename from foo.txt
+rename to bar.txt
+`
+
+	f := assertParseSingleFile(t, []byte(input), "patch")
+	if s := f.String(); s != expected {
+		t.Errorf("incorrect patch\nexpected: %q\n  actual: %q", expected, s)
+	}
+}
+
+func TestFile_WriteTo(t *testing.T) {
+	b, err := os.ReadFile(filepath.Join("testdata", "string", "modify.patch"))
+	if err != nil {
+		t.Fatalf("failed to read patch: %v", err)
+	}
+	f := assertParseSingleFile(t, b, "patch")
+
+	var out bytes.Buffer
+	n, err := f.WriteTo(&out)
+	if err != nil {
+		t.Fatalf("unexpected error writing file: %v", err)
+	}
+	if n != int64(out.Len()) {
+		t.Errorf("incorrect byte count: expected %d, actual %d", out.Len(), n)
+	}
+	if out.String() != string(b) {
+		t.Errorf("incorrect patch text\nexpected: %q\n  actual: %q\n", string(b), out.String())
+	}
+
+	lw := &limitedWriter{limit: 10}
+	n, err = f.WriteTo(lw)
+	if err != errWriteLimit {
+		t.Fatalf("expected write limit error, but got: %v", err)
+	}
+	if n != 10 {
+		t.Errorf("incorrect byte count after error: expected 10, actual %d", n)
+	}
+}
+
+var errWriteLimit = errors.New("write limit exceeded")
+
+type limitedWriter struct {
+	limit int
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if len(p) > w.limit {
+		n := w.limit
+		w.limit = 0
+		return n, errWriteLimit
+	}
+	w.limit -= len(p)
+	return len(p), nil
+}
+
+func TestFormatPrefixes(t *testing.T) {
+	tests := map[string]struct {
+		Options    []FormatOption
+		StripLevel int
+		Header     string
+	}{
+		"default": {
+			StripLevel: 1,
+			Header:     "diff --git a/dir/file.txt b/dir/file.txt\n",
+		},
+		"custom": {
+			Options:    []FormatOption{WithPrefixes("i/", "w/")},
+			StripLevel: 1,
+			Header:     "diff --git i/dir/file.txt w/dir/file.txt\n",
+		},
+		"noPrefix": {
+			Options:    []FormatOption{WithNoPrefix()},
+			StripLevel: 0,
+			Header:     "diff --git dir/file.txt dir/file.txt\n",
+		},
+	}
+
+	f := &File{
+		OldName:      "dir/file.txt",
+		NewName:      "dir/file.txt",
+		OldOIDPrefix: "ebe9fa54",
+		NewOIDPrefix: "fe103e1d",
+		OldMode:      0o100644,
+		TextFragments: []*TextFragment{
+			{
+				OldPosition: 1, OldLines: 1,
+				NewPosition: 1, NewLines: 1,
+				LinesAdded: 1, LinesDeleted: 1,
+				Lines: []Line{
+					{Op: OpDelete, Line: "old line\n"},
+					{Op: OpAdd, Line: "new line\n"},
+				},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var b strings.Builder
+			if _, err := Format(&b, f, test.Options...); err != nil {
+				t.Fatalf("unexpected error formatting file: %v", err)
+			}
+
+			out := b.String()
+			if !strings.HasPrefix(out, test.Header) {
+				t.Errorf("incorrect header line\nexpected: %q\n  actual: %q", test.Header, out)
+			}
+
+			files, _, err := NewParser(WithStripLevel(test.StripLevel)).Parse(strings.NewReader(out))
+			if err != nil {
+				t.Fatalf("unexpected error parsing formatted file: %v", err)
+			}
+			if len(files) != 1 {
+				t.Fatalf("expected 1 file, but got %d", len(files))
+			}
+			if files[0].OldName != f.OldName || files[0].NewName != f.NewName {
+				t.Errorf("incorrect names: expected %q => %q, actual %q => %q",
+					f.OldName, f.NewName, files[0].OldName, files[0].NewName)
+			}
+		})
+	}
+}
+
+func TestFormatNoNewlineMarker(t *testing.T) {
+	tests := map[string]struct {
+		Line     Line
+		Expected string
+	}{
+		"newline": {
+			Line:     Line{Op: OpAdd, Line: "line\n"},
+			Expected: "+line\n",
+		},
+		"missingNewline": {
+			Line:     Line{Op: OpAdd, Line: "line"},
+			Expected: "+line\n\\ No newline at end of file\n",
+		},
+		"flag": {
+			Line:     Line{Op: OpAdd, Line: "line", NoNewlineAtEOF: true},
+			Expected: "+line\n\\ No newline at end of file\n",
+		},
+		"flagWithNewline": {
+			Line:     Line{Op: OpAdd, Line: "line\n", NoNewlineAtEOF: true},
+			Expected: "+line\n\\ No newline at end of file\n",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			frag := &TextFragment{NewLines: 1, LinesAdded: 1, Lines: []Line{test.Line}}
+
+			var b strings.Builder
+			newFormatter(&b).FormatTextFragment(frag)
+
+			lines := strings.SplitN(b.String(), "\n", 2)
+			if lines[1] != test.Expected {
+				t.Errorf("incorrect fragment\nexpected: %q\n  actual: %q", test.Expected, lines[1])
+			}
+		})
+	}
+}
+
+func TestFormatBareContextLines(t *testing.T) {
+	header := "diff --git a/file.txt b/file.txt\n" +
+		"index 4cb29ea..0a9caa1 100644\n" +
+		"--- a/file.txt\n" +
+		"+++ b/file.txt\n" +
+		"@@ -1,5 +1,6 @@\n"
+
+	// the first empty context line has no leading space, the second does
+	input := header + " one\n" + "\n" + " \n" + "+two\n" + " three\n" + " four\n"
+	normalized := header + " one\n" + " \n" + " \n" + "+two\n" + " three\n" + " four\n"
+
+	tests := map[string]struct {
+		Options  []ParserOption
+		Expected string
+	}{
+		"default": {
+			Expected: normalized,
+		},
+		"bareContextLines": {
+			Options:  []ParserOption{WithBareContextLines()},
+			Expected: input,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			files, _, err := NewParser(test.Options...).Parse(strings.NewReader(input))
+			if err != nil {
+				t.Fatalf("unexpected error parsing patch: %v", err)
+			}
+			if len(files) != 1 {
+				t.Fatalf("expected 1 file, but found %d", len(files))
+			}
+
+			if s := files[0].String(); s != test.Expected {
+				t.Errorf("incorrect patch\nexpected: %q\n  actual: %q", test.Expected, s)
+			}
+		})
+	}
+}
@@ -3,8 +3,10 @@ package gitdiff
 import (
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"strings"
+	"unicode"
 )
 
 // File describes changes to a single file. It can be either a text file or a
@@ -18,12 +20,31 @@ type File struct {
 	IsCopy   bool
 	IsRename bool
 
+	// OldMode and NewMode are the Git modes of the file, like 0100644 for a
+	// regular file. Git stores the file type in the high bits of the mode,
+	// which do not match the type bits of os.FileMode, so methods like
+	// IsRegular and IsDir are not meaningful for these values. Use IsSymlink
+	// and IsGitlink to check for special entries.
 	OldMode os.FileMode
 	NewMode os.FileMode
 
 	OldOIDPrefix string
 	NewOIDPrefix string
-	Score        int
+
+	// Score is the similarity index of a copied or renamed file, as a
+	// percentage. Dissimilarity is the dissimilarity index of a file that
+	// Git considers a complete rewrite, as a percentage. Both are zero if
+	// the patch does not include them.
+	Score         int
+	Dissimilarity int
+
+	// IsSubmodule is true if the file is a submodule, which has mode 160000 in
+	// Git. The text fragments of a submodule contain "Subproject commit" lines
+	// instead of file content. OldSubmoduleCommit and NewSubmoduleCommit
+	// contain the commit IDs from those lines, if they are present.
+	IsSubmodule        bool
+	OldSubmoduleCommit string
+	NewSubmoduleCommit string
 
 	// TextFragments contains the fragments describing changes to a text file. It
 	// may be empty if the file is empty or if only the mode changes.
@@ -33,10 +54,42 @@ type File struct {
 	// binary data, BinaryFragment will be non-nil and describe the changes to
 	// the data. If the patch is reversible, ReverseBinaryFragment will also be
 	// non-nil and describe the changes needed to restore the original file
-	// after applying the changes in BinaryFragment.
+	// after applying the changes in BinaryFragment. Some tools produce
+	// patches that only include the reverse data, in which case
+	// BinaryFragment is nil and ReverseBinaryFragment is non-nil.
 	IsBinary              bool
 	BinaryFragment        *BinaryFragment
 	ReverseBinaryFragment *BinaryFragment
+
+	// IsCombined is true if the file is from a combined diff of a merge
+	// commit. Combined diffs compare the file in the merge result with the
+	// file in each parent of the merge. ParentOIDPrefixes and ParentModes
+	// contain the object ID prefix and mode of the file in each parent, if
+	// they are known. OldOIDPrefix and OldMode contain the values for the
+	// first parent. Combined diffs can be parsed and formatted, but not
+	// applied.
+	IsCombined        bool
+	ParentOIDPrefixes []string
+	ParentModes       []os.FileMode
+
+	// HeaderLine is the one-indexed line number of the first line of the file
+	// header in the parsed input and HeaderOffset is the zero-indexed byte
+	// offset of that line. Both are zero if the file was not created by
+	// parsing a patch.
+	HeaderLine   int64
+	HeaderOffset int64
+
+	// RawHeader is the exact text of the file header in the parsed input,
+	// including line endings and any header lines that are not otherwise
+	// represented in the File. It ends before the first fragment. It is only
+	// set if the parser uses the WithRawHeaders option.
+	RawHeader string
+
+	// Preamble is any content between the end of the previous file, or the
+	// start of the input, and the header of this file. For patches generated
+	// by "git log -p" or "git format-patch", this includes the commit
+	// information. It is not included when formatting the file.
+	Preamble string
 }
 
 // String returns a git diff representation of this file. The value can be
@@ -44,12 +97,201 @@ type File struct {
 // the original input.
 func (f *File) String() string {
 	var diff strings.Builder
-	newFormatter(&diff).FormatFile(f)
+	_, _ = f.WriteTo(&diff)
 	return diff.String()
 }
 
+// WriteTo writes a git diff representation of this file to w. It produces the
+// same output as String, but does not hold the full representation in memory.
+// WriteTo implements the io.WriterTo interface.
+func (f *File) WriteTo(w io.Writer) (int64, error) {
+	return Format(w, f)
+}
+
+// IsModeChange returns true if the patch changes the mode of an existing file.
+// It is false for new and deleted files.
+func (f *File) IsModeChange() bool {
+	return f.OldMode != 0 && f.NewMode != 0 && f.OldMode != f.NewMode
+}
+
+// IsSymlink returns true if either mode of the file is the Git mode for a
+// symbolic link, 120000. The content of a symbolic link is the target path.
+func (f *File) IsSymlink() bool {
+	return f.OldMode&gitModeTypeMask == gitModeSymlink || f.NewMode&gitModeTypeMask == gitModeSymlink
+}
+
+// IsGitlink returns true if either mode of the file is the Git mode for a
+// gitlink, 160000, which is a reference to a commit in a submodule. It
+// matches the IsSubmodule field of parsed files.
+func (f *File) IsGitlink() bool {
+	return f.OldMode&gitModeTypeMask == submoduleMode || f.NewMode&gitModeTypeMask == submoduleMode
+}
+
+// HasContentChanges returns true if the patch changes the content of the
+// file. This is true if the file has text fragments or is a binary file, even
+// if the patch does not include the binary data.
+func (f *File) HasContentChanges() bool {
+	return len(f.TextFragments) > 0 || f.IsBinary
+}
+
+// IsContentIdentical returns true if the old and new content of the file are
+// the same, meaning the patch only changes metadata like the name or mode. It
+// is false if the file has text or binary fragments, is a binary file, or has
+// object IDs that differ, even if fragments were not parsed. It is also false
+// for new and deleted files.
+func (f *File) IsContentIdentical() bool {
+	if f.HasContentChanges() || f.BinaryFragment != nil || f.IsNew || f.IsDelete {
+		return false
+	}
+	if f.OldOIDPrefix != "" && f.NewOIDPrefix != "" {
+		n := min(len(f.OldOIDPrefix), len(f.NewOIDPrefix))
+		return f.OldOIDPrefix[:n] == f.NewOIDPrefix[:n]
+	}
+	return true
+}
+
+// IsPureRename returns true if the patch renames the file without changing its
+// content or mode.
+func (f *File) IsPureRename() bool {
+	return f.IsRename && !f.HasContentChanges() && !f.IsModeChange()
+}
+
+// Reverse returns a new file that undoes the changes in f. Like `git apply
+// -R`, it swaps the old and new names, modes, and object IDs, swaps IsNew and
+// IsDelete, and reverses each text fragment as described by
+// TextFragment.Reverse. Renames and copies keep their flags with the names
+// swapped. For binary files, the forward and reverse binary fragments are
+// swapped. HeaderLine and HeaderOffset are zero in the result. Reverse does
+// not support files from combined diffs.
+func (f *File) Reverse() *File {
+	r := &File{
+		OldName: f.NewName,
+		NewName: f.OldName,
+
+		IsNew:    f.IsDelete,
+		IsDelete: f.IsNew,
+		IsCopy:   f.IsCopy,
+		IsRename: f.IsRename,
+
+		OldMode: f.NewMode,
+		NewMode: f.OldMode,
+
+		OldOIDPrefix:  f.NewOIDPrefix,
+		NewOIDPrefix:  f.OldOIDPrefix,
+		Score:         f.Score,
+		Dissimilarity: f.Dissimilarity,
+
+		IsSubmodule:        f.IsSubmodule,
+		OldSubmoduleCommit: f.NewSubmoduleCommit,
+		NewSubmoduleCommit: f.OldSubmoduleCommit,
+
+		IsBinary:              f.IsBinary,
+		BinaryFragment:        f.ReverseBinaryFragment,
+		ReverseBinaryFragment: f.BinaryFragment,
+	}
+	// a file with only an old mode has the same mode on both sides
+	if f.OldMode != 0 && f.NewMode == 0 && !f.IsDelete {
+		r.OldMode, r.NewMode = f.OldMode, 0
+	}
+	for _, frag := range f.TextFragments {
+		r.TextFragments = append(r.TextFragments, frag.Reverse())
+	}
+	return r
+}
+
+// IsReversible returns true if ApplyReverse can revert the changes in the
+// file. Text changes are always reversible. Binary changes are only
+// reversible if the patch includes a reverse binary fragment. Files from
+// combined diffs are never reversible.
+func (f *File) IsReversible() bool {
+	if f.IsCombined {
+		return false
+	}
+	if f.IsBinary {
+		return f.ReverseBinaryFragment != nil
+	}
+	return true
+}
+
+// RecomputePositions sets the new position of each text fragment based on
+// its old position and the net number of lines added or deleted by the
+// fragments before it. Old positions refer to the source and are not changed.
+// Use RecomputePositions after adding or removing fragments so that the file
+// can be applied by Git. The line counts of each fragment must be correct.
+func (f *File) RecomputePositions() {
+	var delta int64
+	for _, frag := range f.TextFragments {
+		start := frag.OldPosition
+		if frag.OldLines == 0 {
+			// an empty range refers to the line before the change
+			start++
+		}
+
+		frag.NewPosition = start + delta
+		if frag.NewLines == 0 {
+			frag.NewPosition--
+		}
+		setLineNumbers(frag)
+
+		delta += frag.NewLines - frag.OldLines
+	}
+}
+
+// SplitHunks returns a file for each text fragment in f, like the hunks
+// offered by `git add -p`. Each file has the same header fields as f and a
+// single fragment. Fragment positions are not changed, so each file applies
+// to the original content independently of the others. The fragments are
+// shared with f and are not copied.
+//
+// Files with binary changes or without text fragments cannot be split and
+// SplitHunks returns a single file with all of the changes of f.
+func (f *File) SplitHunks() []*File {
+	if len(f.TextFragments) == 0 {
+		split := *f
+		return []*File{&split}
+	}
+
+	files := make([]*File, len(f.TextFragments))
+	for i, frag := range f.TextFragments {
+		split := *f
+		split.TextFragments = []*TextFragment{frag}
+		files[i] = &split
+	}
+	return files
+}
+
+// ChangedLineRanges returns the ranges of lines in the new content of the file
+// that were added or modified by the text fragments, in order. Adjacent
+// changed lines are merged into a single range, even if they are in
+// different fragments. Lines that were only deleted do not appear in the
+// result.
+func (f *File) ChangedLineRanges() []Range {
+	var ranges []Range
+	for _, frag := range f.TextFragments {
+		lineNo := max(frag.NewPosition, 1)
+		for _, line := range frag.Lines {
+			if line.Op == OpAdd {
+				if n := len(ranges); n > 0 && ranges[n-1].Position+ranges[n-1].Lines == lineNo {
+					ranges[n-1].Lines++
+				} else {
+					ranges = append(ranges, Range{Position: lineNo, Lines: 1})
+				}
+			}
+			if line.New() {
+				lineNo++
+			}
+		}
+	}
+	return ranges
+}
+
 // TextFragment describes changed lines starting at a specific line in a text file.
 type TextFragment struct {
+	// Comment is the unprocessed section heading after the ranges in the
+	// fragment header, like the enclosing function name that Git finds using
+	// the diff.xfuncname setting. Leading and trailing whitespace is removed,
+	// but internal whitespace is preserved exactly. See SectionHeading for a
+	// normalized version.
 	Comment string
 
 	OldPosition int64
@@ -64,9 +306,99 @@ type TextFragment struct {
 	LeadingContext  int64
 	TrailingContext int64
 
+	// ParentRanges is set for fragments in combined diffs and contains the
+	// range of old lines in each parent. OldPosition and OldLines contain the
+	// range in the first parent.
+	ParentRanges []Range
+
 	Lines []Line
 }
 
+// NewTextFragment creates a fragment that changes oldLines into newLines,
+// using a minimal line diff to find the context, deleted, and added lines.
+// Each line should end with a newline character unless it is the last line of
+// a file that does not end with a newline. oldStart and newStart are the
+// one-indexed line numbers of the first old and new lines.
+//
+// The fragment has all counts and line numbers set, so it passes Validate and
+// can be formatted or applied directly. If oldLines or newLines is empty, the
+// position of that side refers to the line before the change, as in fragments
+// generated by Git.
+func NewTextFragment(oldStart, newStart int64, oldLines, newLines []string) *TextFragment {
+	frag := &TextFragment{
+		OldPosition: oldStart,
+		NewPosition: newStart,
+		Lines:       make([]Line, 0, max(int64(len(oldLines)), int64(len(newLines)))),
+	}
+
+	newLine := func(op LineOp, s string) Line {
+		return Line{Op: op, Line: s, NoNewlineAtEOF: !strings.HasSuffix(s, "\n")}
+	}
+
+	j := 0
+	for i, match := range matchLines(oldLines, newLines) {
+		if match < 0 {
+			frag.Lines = append(frag.Lines, newLine(OpDelete, oldLines[i]))
+			continue
+		}
+		for ; j < match; j++ {
+			frag.Lines = append(frag.Lines, newLine(OpAdd, newLines[j]))
+		}
+		frag.Lines = append(frag.Lines, newLine(OpContext, oldLines[i]))
+		j++
+	}
+	for ; j < len(newLines); j++ {
+		frag.Lines = append(frag.Lines, newLine(OpAdd, newLines[j]))
+	}
+
+	setLineCounts(frag)
+	if frag.OldLines == 0 {
+		frag.OldPosition--
+	}
+	if frag.NewLines == 0 {
+		frag.NewPosition--
+	}
+	setLineNumbers(frag)
+	return frag
+}
+
+// setLineCounts sets the line counts of frag based on the operations of its
+// lines.
+func setLineCounts(frag *TextFragment) {
+	frag.OldLines, frag.NewLines = 0, 0
+	frag.LinesAdded, frag.LinesDeleted = 0, 0
+	frag.LeadingContext, frag.TrailingContext = 0, 0
+
+	for _, line := range frag.Lines {
+		switch line.Op {
+		case OpContext:
+			frag.OldLines++
+			frag.NewLines++
+			if frag.LinesAdded == 0 && frag.LinesDeleted == 0 {
+				frag.LeadingContext++
+			} else {
+				frag.TrailingContext++
+			}
+		case OpDelete:
+			frag.OldLines++
+			frag.LinesDeleted++
+			frag.TrailingContext = 0
+		case OpAdd:
+			frag.NewLines++
+			frag.LinesAdded++
+			frag.TrailingContext = 0
+		}
+	}
+}
+
+// Range is a range of lines in a file. Position is the one-indexed number of
+// the first line in the range, or zero if the range is empty and starts at
+// the beginning of the file.
+type Range struct {
+	Position int64
+	Lines    int64
+}
+
 // String returns a git diff format of this fragment. See [File.String] for
 // more details on this format.
 func (f *TextFragment) String() string {
@@ -83,6 +415,115 @@ func (f *TextFragment) Header() string {
 	return hdr.String()
 }
 
+// Reverse returns a new fragment that undoes the changes in f. Added lines in
+// f are deleted lines in the result and deleted lines in f are added lines in
+// the result. Within each group of changes, deleted lines appear before added
+// lines, matching the order produced by Git. Reverse does not support
+// fragments from combined diffs.
+func (f *TextFragment) Reverse() *TextFragment {
+	r := &TextFragment{
+		Comment:         f.Comment,
+		OldPosition:     f.NewPosition,
+		OldLines:        f.NewLines,
+		NewPosition:     f.OldPosition,
+		NewLines:        f.OldLines,
+		LinesAdded:      f.LinesDeleted,
+		LinesDeleted:    f.LinesAdded,
+		LeadingContext:  f.LeadingContext,
+		TrailingContext: f.TrailingContext,
+		Lines:           make([]Line, 0, len(f.Lines)),
+	}
+
+	var added []Line
+	for _, line := range f.Lines {
+		line.OldLineNo, line.NewLineNo = line.NewLineNo, line.OldLineNo
+
+		switch line.Op {
+		case OpContext:
+			r.Lines = append(r.Lines, added...)
+			added = added[:0]
+			r.Lines = append(r.Lines, line)
+		case OpAdd:
+			line.Op = OpDelete
+			r.Lines = append(r.Lines, line)
+		case OpDelete:
+			line.Op = OpAdd
+			added = append(added, line)
+		default:
+			r.Lines = append(r.Lines, line)
+		}
+	}
+	r.Lines = append(r.Lines, added...)
+
+	return r
+}
+
+// SectionHeading returns the section heading of the fragment with each run of
+// whitespace and control characters replaced by a single space. It returns
+// an empty string if the fragment has no heading.
+func (f *TextFragment) SectionHeading() string {
+	return strings.Join(strings.FieldsFunc(f.Comment, func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsControl(r)
+	}), " ")
+}
+
+// LeadingContextLines returns the context lines before the first added or
+// deleted line in the fragment. The result shares memory with f.Lines.
+func (f *TextFragment) LeadingContextLines() []Line {
+	n := f.LeadingContext
+	if n > int64(len(f.Lines)) {
+		n = int64(len(f.Lines))
+	}
+	return f.Lines[:n]
+}
+
+// TrailingContextLines returns the context lines after the last added or
+// deleted line in the fragment. The result shares memory with f.Lines.
+func (f *TextFragment) TrailingContextLines() []Line {
+	n := f.TrailingContext
+	if n > int64(len(f.Lines)) {
+		n = int64(len(f.Lines))
+	}
+	return f.Lines[int64(len(f.Lines))-n:]
+}
+
+// Changes returns the added and deleted lines in the fragment, in order.
+func (f *TextFragment) Changes() []Line {
+	var changes []Line
+	for _, line := range f.Lines {
+		if line.Op != OpContext {
+			changes = append(changes, line)
+		}
+	}
+	return changes
+}
+
+// OldLinesText returns the text of the lines in the old content of the
+// fragment, including context and deleted lines. Each element includes the
+// line ending, if present.
+func (f *TextFragment) OldLinesText() []string {
+	var lines []string
+	for _, line := range f.Lines {
+		if line.Old() {
+			lines = append(lines, line.Line)
+		}
+	}
+	return lines
+}
+
+// NewLinesText returns the text of the lines in the new content of the
+// fragment, including context and added lines. Each element includes the line
+// ending, if present.
+func (f *TextFragment) NewLinesText() []string {
+	var lines []string
+	for _, line := range f.Lines {
+		if line.New() {
+			lines = append(lines, line.Line)
+		}
+	}
+	return lines
+}
+
 // Validate checks that the fragment is self-consistent and appliable. Validate
 // returns an error if and only if the fragment is invalid.
 func (f *TextFragment) Validate() error {
@@ -96,8 +537,21 @@ func (f *TextFragment) Validate() error {
 		contextLines, addedLines, deletedLines int64
 	)
 
+	parentLines := make([]int64, len(f.ParentRanges))
+
 	// count the types of lines in the fragment content
 	for i, line := range f.Lines {
+		if len(parentLines) > 0 {
+			if len(line.ParentOps) != len(parentLines) {
+				return fmt.Errorf("line %d has %d parent operations but fragment has %d parents", i+1, len(line.ParentOps), len(parentLines))
+			}
+			for j := range parentLines {
+				if line.inParent(j) {
+					parentLines[j]++
+				}
+			}
+		}
+
 		switch line.Op {
 		case OpContext:
 			oldLines++
@@ -121,6 +575,16 @@ func (f *TextFragment) Validate() error {
 		}
 	}
 
+	// in combined fragments, old lines are the lines of the first parent
+	for j, r := range f.ParentRanges {
+		if parentLines[j] != r.Lines {
+			return lineCountErr(fmt.Sprintf("parent %d", j+1), parentLines[j], r.Lines)
+		}
+		if j == 0 {
+			oldLines = parentLines[j]
+		}
+	}
+
 	// check the actual counts against the reported counts
 	if oldLines != f.OldLines {
 		return lineCountErr("old", oldLines, f.OldLines)
@@ -157,9 +621,45 @@ func lineCountErr(kind string, actual, reported int64) error {
 type Line struct {
 	Op   LineOp
 	Line string
+
+	// OldLineNo and NewLineNo are the one-indexed numbers of the line in the
+	// old and new content of the file. OldLineNo is zero for added lines and
+	// NewLineNo is zero for deleted lines. Both are zero if the fragment was
+	// not created by parsing a patch. For lines in combined fragments,
+	// OldLineNo is the number of the line in the first parent, if present.
+	OldLineNo int64
+	NewLineNo int64
+
+	// ParentOps is set for lines in combined fragments and contains the
+	// operation for the line relative to each parent. In this case, Op is
+	// OpDelete if the line was removed from any parent, OpAdd if the line was
+	// added relative to any parent, and OpContext otherwise.
+	ParentOps []LineOp
+
+	// NoNewlineAtEOF is true if the line was followed by a "\ No newline at
+	// end of file" marker in the parsed patch. When formatting, the marker is
+	// written after lines with this flag and after lines that do not end in a
+	// newline character.
+	NoNewlineAtEOF bool
+
+	// BareContext is true if the line is an empty context line that did not
+	// start with a space character in the parsed patch, as produced by newer
+	// versions of GNU diff. It is only set if the parser uses the
+	// WithBareContextLines option. By default, empty context lines are always
+	// formatted with a leading space; lines with this flag are formatted
+	// without it.
+	BareContext bool
 }
 
 func (fl Line) String() string {
+	if len(fl.ParentOps) > 0 {
+		var b strings.Builder
+		for _, op := range fl.ParentOps {
+			b.WriteString(op.String())
+		}
+		b.WriteString(fl.Line)
+		return b.String()
+	}
 	return fl.Op.String() + fl.Line
 }
 
@@ -173,6 +673,15 @@ func (fl Line) New() bool {
 	return fl.Op == OpContext || fl.Op == OpAdd
 }
 
+// inParent returns true if the line appears in the content of the parent
+// with index i in a combined fragment.
+func (fl Line) inParent(i int) bool {
+	if fl.Op == OpDelete {
+		return fl.ParentOps[i] == OpDelete
+	}
+	return fl.ParentOps[i] == OpContext
+}
+
 // NoEOL returns true if the line is missing a trailing newline character.
 func (fl Line) NoEOL() bool {
 	return len(fl.Line) == 0 || fl.Line[len(fl.Line)-1] != '\n'
@@ -1,6 +1,9 @@
 package gitdiff
 
 import (
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -159,3 +162,615 @@ func TestTextFragmentValidate(t *testing.T) {
 		})
 	}
 }
+
+func TestFilePredicates(t *testing.T) {
+	tests := map[string]struct {
+		File               File
+		IsModeChange       bool
+		HasContentChanges  bool
+		IsPureRename       bool
+		IsReversible       bool
+		IsSymlink          bool
+		IsGitlink          bool
+		IsContentIdentical bool
+	}{
+		"modeChange": {
+			File:               File{OldName: "file.txt", NewName: "file.txt", OldMode: 0o100644, NewMode: 0o100755},
+			IsModeChange:       true,
+			IsReversible:       true,
+			IsContentIdentical: true,
+		},
+		"sameMode": {
+			File:               File{OldName: "file.txt", NewName: "file.txt", OldMode: 0o100644, NewMode: 0o100644},
+			IsReversible:       true,
+			IsContentIdentical: true,
+		},
+		"newFile": {
+			File:         File{NewName: "file.txt", NewMode: 0o100644, IsNew: true},
+			IsReversible: true,
+		},
+		"textChange": {
+			File:              File{OldName: "file.txt", NewName: "file.txt", TextFragments: []*TextFragment{{}}},
+			HasContentChanges: true,
+			IsReversible:      true,
+		},
+		"binaryChange": {
+			File:              File{OldName: "file.bin", NewName: "file.bin", IsBinary: true},
+			HasContentChanges: true,
+		},
+		"binaryChangeWithReverse": {
+			File: File{
+				OldName:               "file.bin",
+				NewName:               "file.bin",
+				IsBinary:              true,
+				BinaryFragment:        &BinaryFragment{},
+				ReverseBinaryFragment: &BinaryFragment{},
+			},
+			HasContentChanges: true,
+			IsReversible:      true,
+		},
+		"combined": {
+			File:              File{OldName: "file.txt", NewName: "file.txt", IsCombined: true, TextFragments: []*TextFragment{{}}},
+			HasContentChanges: true,
+		},
+		"pureRename": {
+			File:               File{OldName: "old.txt", NewName: "new.txt", IsRename: true},
+			IsPureRename:       true,
+			IsReversible:       true,
+			IsContentIdentical: true,
+		},
+		"renameWithChanges": {
+			File:              File{OldName: "old.txt", NewName: "new.txt", IsRename: true, TextFragments: []*TextFragment{{}}},
+			HasContentChanges: true,
+			IsReversible:      true,
+		},
+		"renameWithModeChange": {
+			File:               File{OldName: "old.txt", NewName: "new.txt", IsRename: true, OldMode: 0o100644, NewMode: 0o100755},
+			IsModeChange:       true,
+			IsReversible:       true,
+			IsContentIdentical: true,
+		},
+		"newSymlink": {
+			File:         File{NewName: "link", NewMode: 0o120000, IsNew: true},
+			IsReversible: true,
+			IsSymlink:    true,
+		},
+		"fileToSymlink": {
+			File:               File{OldName: "link", NewName: "link", OldMode: 0o100644, NewMode: 0o120000},
+			IsModeChange:       true,
+			IsReversible:       true,
+			IsSymlink:          true,
+			IsContentIdentical: true,
+		},
+		"gitlink": {
+			File:              File{OldName: "sub", NewName: "sub", OldMode: 0o160000, NewMode: 0o160000, TextFragments: []*TextFragment{{}}},
+			HasContentChanges: true,
+			IsReversible:      true,
+			IsGitlink:         true,
+		},
+		"deletedGitlink": {
+			File:         File{OldName: "sub", OldMode: 0o160000, IsDelete: true},
+			IsReversible: true,
+			IsGitlink:    true,
+		},
+		"binaryFragmentSameSize": {
+			File: File{
+				OldName:               "file.bin",
+				NewName:               "file.bin",
+				IsBinary:              true,
+				BinaryFragment:        &BinaryFragment{Method: BinaryPatchLiteral, Size: 4, Data: []byte("new\n")},
+				ReverseBinaryFragment: &BinaryFragment{Method: BinaryPatchLiteral, Size: 4, Data: []byte("old\n")},
+			},
+			HasContentChanges: true,
+			IsReversible:      true,
+		},
+		"differentOIDs": {
+			File:         File{OldName: "file.txt", NewName: "file.txt", OldOIDPrefix: "1c23fcc", NewOIDPrefix: "40a1b33"},
+			IsReversible: true,
+		},
+		"sameOIDs": {
+			File:               File{OldName: "old.txt", NewName: "new.txt", IsRename: true, OldOIDPrefix: "1c23fcc", NewOIDPrefix: "1c23fcc42"},
+			IsPureRename:       true,
+			IsReversible:       true,
+			IsContentIdentical: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if actual := test.File.IsModeChange(); actual != test.IsModeChange {
+				t.Errorf("incorrect IsModeChange: expected %t, actual %t", test.IsModeChange, actual)
+			}
+			if actual := test.File.HasContentChanges(); actual != test.HasContentChanges {
+				t.Errorf("incorrect HasContentChanges: expected %t, actual %t", test.HasContentChanges, actual)
+			}
+			if actual := test.File.IsPureRename(); actual != test.IsPureRename {
+				t.Errorf("incorrect IsPureRename: expected %t, actual %t", test.IsPureRename, actual)
+			}
+			if actual := test.File.IsReversible(); actual != test.IsReversible {
+				t.Errorf("incorrect IsReversible: expected %t, actual %t", test.IsReversible, actual)
+			}
+			if actual := test.File.IsSymlink(); actual != test.IsSymlink {
+				t.Errorf("incorrect IsSymlink: expected %t, actual %t", test.IsSymlink, actual)
+			}
+			if actual := test.File.IsGitlink(); actual != test.IsGitlink {
+				t.Errorf("incorrect IsGitlink: expected %t, actual %t", test.IsGitlink, actual)
+			}
+			if actual := test.File.IsContentIdentical(); actual != test.IsContentIdentical {
+				t.Errorf("incorrect IsContentIdentical: expected %t, actual %t", test.IsContentIdentical, actual)
+			}
+		})
+	}
+}
+
+func TestTextFragmentContextLines(t *testing.T) {
+	frag := &TextFragment{
+		LinesAdded:      2,
+		LinesDeleted:    1,
+		LeadingContext:  2,
+		TrailingContext: 1,
+		Lines: []Line{
+			{Op: OpContext, Line: "context 1\n"},
+			{Op: OpContext, Line: "context 2\n"},
+			{Op: OpDelete, Line: "old line\n"},
+			{Op: OpAdd, Line: "new line 1\n"},
+			{Op: OpContext, Line: "context 3\n"},
+			{Op: OpAdd, Line: "new line 2\n"},
+			{Op: OpContext, Line: "context 4\n"},
+		},
+	}
+
+	if lines := frag.LeadingContextLines(); !reflect.DeepEqual(lines, frag.Lines[:2]) {
+		t.Errorf("incorrect leading context lines: %+v", lines)
+	}
+	if lines := frag.TrailingContextLines(); !reflect.DeepEqual(lines, frag.Lines[6:]) {
+		t.Errorf("incorrect trailing context lines: %+v", lines)
+	}
+
+	expected := []Line{frag.Lines[2], frag.Lines[3], frag.Lines[5]}
+	if lines := frag.Changes(); !reflect.DeepEqual(lines, expected) {
+		t.Errorf("incorrect changes\nexpected: %+v\n  actual: %+v", expected, lines)
+	}
+
+	empty := &TextFragment{LeadingContext: 2, TrailingContext: 2}
+	if len(empty.LeadingContextLines()) != 0 || len(empty.TrailingContextLines()) != 0 || len(empty.Changes()) != 0 {
+		t.Errorf("expected no lines for empty fragment")
+	}
+}
+
+func TestNewTextFragment(t *testing.T) {
+	tests := map[string]struct {
+		OldStart, NewStart int64
+		Old, New           []string
+		Output             string
+	}{
+		"modify": {
+			OldStart: 10,
+			NewStart: 12,
+			Old:      []string{"line 1\n", "line 2\n", "line 3\n", "line 4\n"},
+			New:      []string{"line 1\n", "line 2 changed\n", "line 2.5\n", "line 3\n"},
+			Output: `@@ -10,4 +12,4 @@
+ line 1
+-line 2
++line 2 changed
++line 2.5
+ line 3
+-line 4
+`,
+		},
+		"newFile": {
+			OldStart: 1,
+			NewStart: 1,
+			New:      []string{"line 1\n", "line 2\n"},
+			Output: `@@ -0,0 +1,2 @@
++line 1
++line 2
+`,
+		},
+		"deleteAll": {
+			OldStart: 1,
+			NewStart: 1,
+			Old:      []string{"line 1\n", "line 2\n"},
+			Output: `@@ -1,2 +0,0 @@
+-line 1
+-line 2
+`,
+		},
+		"noNewlineAtEOF": {
+			OldStart: 1,
+			NewStart: 1,
+			Old:      []string{"line 1\n", "line 2"},
+			New:      []string{"line 1\n", "line 2\n"},
+			Output: `@@ -1,2 +1,2 @@
+ line 1
+-line 2
+\ No newline at end of file
++line 2
+`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			frag := NewTextFragment(test.OldStart, test.NewStart, test.Old, test.New)
+			if err := frag.Validate(); err != nil {
+				t.Fatalf("fragment is invalid: %v", err)
+			}
+
+			var b strings.Builder
+			newFormatter(&b).FormatTextFragment(frag)
+			if b.String() != test.Output {
+				t.Errorf("incorrect fragment\nexpected:\n%s\nactual:\n%s", test.Output, b.String())
+			}
+
+			if !reflect.DeepEqual(test.Old, frag.OldLinesText()) {
+				t.Errorf("incorrect old lines\nexpected: %q\n  actual: %q", test.Old, frag.OldLinesText())
+			}
+			if !reflect.DeepEqual(test.New, frag.NewLinesText()) {
+				t.Errorf("incorrect new lines\nexpected: %q\n  actual: %q", test.New, frag.NewLinesText())
+			}
+
+			patch := "diff --git a/file.txt b/file.txt\n--- a/file.txt\n+++ b/file.txt\n" + b.String()
+			f := assertParseSingleFile(t, []byte(patch), "formatted fragment")
+			if len(f.TextFragments) != 1 || !f.TextFragments[0].Equal(frag) {
+				t.Errorf("reparsed fragment does not equal the original")
+			}
+		})
+	}
+}
+
+func TestFileRecomputePositions(t *testing.T) {
+	const patch = `diff --git a/file.txt b/file.txt
+--- a/file.txt
++++ b/file.txt
+@@ -1,3 +1,4 @@
+ line 1
++line 1.5
+ line 2
+ line 3
+@@ -5,3 +6,2 @@
+ line 5
+-line 6
+ line 7
+@@ -9,3 +9,4 @@
+ line 9
++line 9.5
+ line 10
+ line 11
+`
+
+	lines := func(s ...string) string {
+		return strings.Join(s, "\n") + "\n"
+	}
+	src := lines("line 1", "line 2", "line 3", "line 4", "line 5", "line 6", "line 7", "line 8", "line 9", "line 10", "line 11", "line 12")
+
+	tests := map[string]struct {
+		Remove  int
+		Headers []string
+		Output  string
+	}{
+		"removeFirst": {
+			Remove:  0,
+			Headers: []string{"@@ -5,3 +5,2 @@", "@@ -9,3 +8,4 @@"},
+			Output:  lines("line 1", "line 2", "line 3", "line 4", "line 5", "line 7", "line 8", "line 9", "line 9.5", "line 10", "line 11", "line 12"),
+		},
+		"removeMiddle": {
+			Remove:  1,
+			Headers: []string{"@@ -1,3 +1,4 @@", "@@ -9,3 +10,4 @@"},
+			Output:  lines("line 1", "line 1.5", "line 2", "line 3", "line 4", "line 5", "line 6", "line 7", "line 8", "line 9", "line 9.5", "line 10", "line 11", "line 12"),
+		},
+		"removeLast": {
+			Remove:  2,
+			Headers: []string{"@@ -1,3 +1,4 @@", "@@ -5,3 +6,2 @@"},
+			Output:  lines("line 1", "line 1.5", "line 2", "line 3", "line 4", "line 5", "line 7", "line 8", "line 9", "line 10", "line 11", "line 12"),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			f := assertParseSingleFile(t, []byte(patch), "patch")
+			f.TextFragments = append(f.TextFragments[:test.Remove], f.TextFragments[test.Remove+1:]...)
+			f.RecomputePositions()
+
+			var headers []string
+			for _, frag := range f.TextFragments {
+				headers = append(headers, frag.Header())
+			}
+			if !reflect.DeepEqual(test.Headers, headers) {
+				t.Errorf("incorrect fragment headers\nexpected: %q\n  actual: %q", test.Headers, headers)
+			}
+
+			var dst strings.Builder
+			if err := Apply(&dst, strings.NewReader(src), f); err != nil {
+				t.Fatalf("unexpected error applying patch: %v", err)
+			}
+			if dst.String() != test.Output {
+				t.Errorf("incorrect result\nexpected:\n%s\nactual:\n%s", test.Output, dst.String())
+			}
+
+			reparsed := assertParseSingleFile(t, []byte(f.String()), "formatted patch")
+			assertFilesEqual(t, f, reparsed)
+		})
+	}
+
+	t.Run("emptyRanges", func(t *testing.T) {
+		f := &File{
+			TextFragments: []*TextFragment{
+				{OldPosition: 2, OldLines: 0, NewPosition: 100, NewLines: 1, Lines: []Line{{Op: OpAdd, Line: "line 2.5\n"}}},
+				{OldPosition: 4, OldLines: 1, NewPosition: 100, NewLines: 0, Lines: []Line{{Op: OpDelete, Line: "line 4\n"}}},
+			},
+		}
+		f.RecomputePositions()
+
+		if pos := f.TextFragments[0].NewPosition; pos != 3 {
+			t.Errorf("incorrect new position of insertion: expected 3, actual %d", pos)
+		}
+		if pos := f.TextFragments[1].NewPosition; pos != 4 {
+			t.Errorf("incorrect new position of deletion: expected 4, actual %d", pos)
+		}
+	})
+}
+
+func TestFileSplitHunks(t *testing.T) {
+	const patch = `diff --git a/file.txt b/file.txt
+index 5bd1c2a..8a4f2e0 100644
+--- a/file.txt
++++ b/file.txt
+@@ -1,3 +1,4 @@
+ line 1
++line 1.5
+ line 2
+ line 3
+@@ -5,3 +6,2 @@
+ line 5
+-line 6
+ line 7
+@@ -9,3 +9,4 @@
+ line 9
++line 9.5
+ line 10
+ line 11
+`
+
+	lines := func(s ...string) string {
+		return strings.Join(s, "\n") + "\n"
+	}
+	src := lines("line 1", "line 2", "line 3", "line 4", "line 5", "line 6", "line 7", "line 8", "line 9", "line 10", "line 11", "line 12")
+
+	outputs := []string{
+		lines("line 1", "line 1.5", "line 2", "line 3", "line 4", "line 5", "line 6", "line 7", "line 8", "line 9", "line 10", "line 11", "line 12"),
+		lines("line 1", "line 2", "line 3", "line 4", "line 5", "line 7", "line 8", "line 9", "line 10", "line 11", "line 12"),
+		lines("line 1", "line 2", "line 3", "line 4", "line 5", "line 6", "line 7", "line 8", "line 9", "line 9.5", "line 10", "line 11", "line 12"),
+	}
+
+	f := assertParseSingleFile(t, []byte(patch), "patch")
+
+	files := f.SplitHunks()
+	if len(files) != len(outputs) {
+		t.Fatalf("incorrect number of files: expected %d, actual %d", len(outputs), len(files))
+	}
+
+	for i, split := range files {
+		if len(split.TextFragments) != 1 || split.TextFragments[0] != f.TextFragments[i] {
+			t.Errorf("file %d: incorrect fragments: %+v", i, split.TextFragments)
+			continue
+		}
+
+		header := *split
+		header.TextFragments = f.TextFragments
+		if !reflect.DeepEqual(f, &header) {
+			t.Errorf("file %d: incorrect header\nexpected: %+v\n  actual: %+v", i, f, split)
+		}
+
+		var dst strings.Builder
+		if err := Apply(&dst, strings.NewReader(src), split); err != nil {
+			t.Fatalf("file %d: unexpected error applying patch: %v", i, err)
+		}
+		if dst.String() != outputs[i] {
+			t.Errorf("file %d: incorrect result\nexpected:\n%s\nactual:\n%s", i, outputs[i], dst.String())
+		}
+	}
+
+	if len(f.TextFragments) != 3 {
+		t.Errorf("original file was modified: %d fragments", len(f.TextFragments))
+	}
+
+	t.Run("binary", func(t *testing.T) {
+		f := &File{
+			OldName:        "file.bin",
+			NewName:        "file.bin",
+			IsBinary:       true,
+			BinaryFragment: &BinaryFragment{Method: BinaryPatchLiteral, Size: 4, Data: []byte("data")},
+		}
+
+		files := f.SplitHunks()
+		if len(files) != 1 {
+			t.Fatalf("incorrect number of files: expected 1, actual %d", len(files))
+		}
+		if files[0] == f || !reflect.DeepEqual(f, files[0]) {
+			t.Errorf("incorrect file\nexpected: %+v\n  actual: %+v", f, files[0])
+		}
+	})
+}
+
+func TestFileChangedLineRanges(t *testing.T) {
+	tests := map[string]struct {
+		Input  string
+		Ranges []Range
+	}{
+		"multipleFragments": {
+			Input: `@@ -8,6 +10,7 @@
+ line 8
+ line 9
+-line 10
++line 10 changed
++line 10.5
+ line 11
+-line 12
++line 12 changed
+ line 13
+@@ -38,3 +41,3 @@
+ line 38
+-line 39
++line 39 changed
+ line 40
+`,
+			Ranges: []Range{
+				{Position: 12, Lines: 2},
+				{Position: 15, Lines: 1},
+				{Position: 42, Lines: 1},
+			},
+		},
+		"onlyDeletions": {
+			Input: `@@ -1,3 +1,2 @@
+ line 1
+-line 2
+ line 3
+`,
+			Ranges: nil,
+		},
+		"newFile": {
+			Input: `@@ -0,0 +1,2 @@
++line 1
++line 2
+`,
+			Ranges: []Range{
+				{Position: 1, Lines: 2},
+			},
+		},
+		"adjacentFragments": {
+			Input: `@@ -1,1 +1,2 @@
+-line 1
++line 1 changed
++line 1.5
+@@ -2,1 +3,1 @@
+-line 2
++line 2 changed
+`,
+			Ranges: []Range{
+				{Position: 1, Lines: 3},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			patch := "diff --git a/file.txt b/file.txt\n--- a/file.txt\n+++ b/file.txt\n" + test.Input
+			f := assertParseSingleFile(t, []byte(patch), "patch")
+
+			if ranges := f.ChangedLineRanges(); !reflect.DeepEqual(test.Ranges, ranges) {
+				t.Errorf("incorrect ranges\nexpected: %+v\n  actual: %+v", test.Ranges, ranges)
+			}
+		})
+	}
+}
+
+func TestTextFragmentLinesText(t *testing.T) {
+	frag := &TextFragment{
+		Lines: []Line{
+			{Op: OpContext, Line: "context 1\n"},
+			{Op: OpDelete, Line: "old line\n"},
+			{Op: OpAdd, Line: "new line 1\n"},
+			{Op: OpContext, Line: "context 2\n"},
+			{Op: OpAdd, Line: "new line 2", NoNewlineAtEOF: true},
+		},
+	}
+
+	expectedOld := []string{"context 1\n", "old line\n", "context 2\n"}
+	if lines := frag.OldLinesText(); !reflect.DeepEqual(lines, expectedOld) {
+		t.Errorf("incorrect old lines\nexpected: %q\n  actual: %q", expectedOld, lines)
+	}
+
+	expectedNew := []string{"context 1\n", "new line 1\n", "context 2\n", "new line 2"}
+	if lines := frag.NewLinesText(); !reflect.DeepEqual(lines, expectedNew) {
+		t.Errorf("incorrect new lines\nexpected: %q\n  actual: %q", expectedNew, lines)
+	}
+}
+
+func TestFileReverse(t *testing.T) {
+	patches := []string{
+		"binary_modify.patch",
+		"copy_modify.patch",
+		"delete.patch",
+		"mode_modify.patch",
+		"modify.patch",
+		"new.patch",
+		"new_mode.patch",
+		"rename_modify.patch",
+	}
+
+	for _, patch := range patches {
+		t.Run(patch, func(t *testing.T) {
+			b, err := os.ReadFile(filepath.Join("testdata", "string", patch))
+			if err != nil {
+				t.Fatalf("failed to read patch: %v", err)
+			}
+			f := assertParseSingleFile(t, b, "patch")
+
+			r := f.Reverse()
+			assertEqual(t, f.NewName, r.OldName, "OldName")
+			assertEqual(t, f.OldName, r.NewName, "NewName")
+			assertEqual(t, f.IsDelete, r.IsNew, "IsNew")
+			assertEqual(t, f.IsNew, r.IsDelete, "IsDelete")
+			if f.IsNew || f.IsDelete || f.IsModeChange() {
+				assertEqual(t, f.NewMode, r.OldMode, "OldMode")
+				assertEqual(t, f.OldMode, r.NewMode, "NewMode")
+			}
+			assertEqual(t, f.Additions(), r.Deletions(), "Deletions")
+			assertEqual(t, f.Deletions(), r.Additions(), "Additions")
+
+			assertFilesEqual(t, f, r.Reverse())
+			if f.String() != r.Reverse().String() {
+				t.Errorf("incorrect patch text after reversing twice\nexpected: %q\n  actual: %q", f.String(), r.Reverse().String())
+			}
+		})
+	}
+
+	t.Run("text", func(t *testing.T) {
+		b, err := os.ReadFile(filepath.Join("testdata", "string", "rename_modify.patch"))
+		if err != nil {
+			t.Fatalf("failed to read patch: %v", err)
+		}
+		f := assertParseSingleFile(t, b, "patch")
+
+		expected := `diff --git a/numbers.txt b/file.txt
+similarity index 77%
+rename from numbers.txt
+rename to file.txt
+index a6b31d6..c9e9e05 100644
+--- a/numbers.txt
++++ b/file.txt
+@@ -3,9 +3,8 @@ two
+ three
+ four
+ five
+-  six
++six
+ seven
+ eight
+ nine
+ ten
+-eleven
+`
+		if actual := f.Reverse().String(); actual != expected {
+			t.Errorf("incorrect reversed patch\nexpected: %q\n  actual: %q", expected, actual)
+		}
+	})
+}
+
+func TestTextFragmentSectionHeading(t *testing.T) {
+	tests := map[string]string{
+		"":                            "",
+		"func test(n int) {":          "func test(n int) {",
+		"func  test(n int,\tm int) {": "func test(n int, m int) {",
+		"section\x1b[0m heading\r":    "section [0m heading",
+		"\t \v":                       "",
+	}
+
+	for comment, expected := range tests {
+		frag := &TextFragment{Comment: comment}
+		if actual := frag.SectionHeading(); actual != expected {
+			t.Errorf("incorrect heading for %q: expected %q, actual %q", comment, expected, actual)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package gitdiff
 
 import (
+	"bytes"
 	"errors"
 	"io"
 )
@@ -9,6 +10,9 @@ const (
 	byteBufferSize  = 32 * 1024 // from io.Copy
 	lineBufferSize  = 32
 	indexBufferSize = 1024
+
+	// lineIndexInterval is the number of lines between index entries
+	lineIndexInterval = 64
 )
 
 // LineReaderAt is the interface that wraps the ReadLinesAt method.
@@ -26,10 +30,28 @@ type LineReaderAt interface {
 	ReadLinesAt(lines [][]byte, offset int64) (n int, err error)
 }
 
+// lineReaderAt implements LineReaderAt for an io.ReaderAt. Instead of the
+// position of every line, it records the byte offset of every
+// lineIndexInterval-th line and of the most recently read line, so its memory
+// use is a small fraction of the size of the source.
+//
+// The lines returned by ReadLinesAt share a buffer that is reused by the next
+// call, so callers must finish with the lines before reading more.
 type lineReaderAt struct {
 	r     io.ReaderAt
 	index []int64
+	buf   []byte
+	scan  [indexBufferSize]byte
+
+	// the position after the last line read, to avoid scanning from an index
+	// entry when reading lines in order
+	lastLine   int64
+	lastOffset int64
+
+	// the number of lines and bytes in the source, once it was read to EOF
 	eof   bool
+	lines int64
+	size  int64
 }
 
 func (r *lineReaderAt) ReadLinesAt(lines [][]byte, offset int64) (n int, err error) {
@@ -41,101 +63,180 @@ func (r *lineReaderAt) ReadLinesAt(lines [][]byte, offset int64) (n int, err err
 	}
 
 	count := len(lines)
-	startLine := offset
-	endLine := startLine + int64(count)
-
-	if endLine > int64(len(r.index)) && !r.eof {
-		if err := r.indexTo(endLine); err != nil {
-			return 0, err
-		}
+	start, ok, err := r.findLine(offset)
+	if err != nil {
+		return 0, err
 	}
-	if startLine >= int64(len(r.index)) {
+	if !ok || (r.eof && offset >= r.lines) {
 		return 0, io.EOF
 	}
 
-	buf, byteOffset, err := r.readBytes(startLine, int64(count))
+	end, ok, err := r.findLine(offset + int64(count))
 	if err != nil {
 		return 0, err
 	}
+	if !ok {
+		end = r.size
+	}
 
-	for n = 0; n < count && startLine+int64(n) < int64(len(r.index)); n++ {
-		lineno := startLine + int64(n)
-		start, end := int64(0), r.index[lineno]-byteOffset
-		if lineno > 0 {
-			start = r.index[lineno-1] - byteOffset
+	if int64(cap(r.buf)) < end-start {
+		r.buf = make([]byte, end-start)
+	}
+	buf := r.buf[:end-start]
+	if nr, err := r.r.ReadAt(buf, start); nr < len(buf) {
+		if err == nil || err == io.EOF {
+			err = errors.New("ReadLinesAt: corrupt line index or changed source data")
+		}
+		return 0, err
+	}
+
+	for n = 0; n < count && len(buf) > 0; n++ {
+		i := bytes.IndexByte(buf, '\n') + 1
+		if i == 0 {
+			i = len(buf)
 		}
-		lines[n] = buf[start:end]
+		lines[n], buf = buf[:i:i], buf[i:]
 	}
 
 	if n < count {
 		return n, io.EOF
 	}
+	r.lastLine, r.lastOffset = offset+int64(n), end
 	return n, nil
 }
 
-// indexTo reads data and computes the line index until there is information
-// for line or a read returns io.EOF. It returns an error if and only if there
-// is an error reading data.
-func (r *lineReaderAt) indexTo(line int64) error {
-	var buf [indexBufferSize]byte
+// findLine returns the byte offset of the start of line, reading the source
+// from the closest known position and recording index entries as needed. If
+// line is equal to the number of lines in the source, it returns the size of
+// the source. It returns false if line is after the end of the source.
+func (r *lineReaderAt) findLine(line int64) (int64, bool, error) {
+	if len(r.index) == 0 {
+		r.index = append(r.index, 0)
+	}
+	if r.eof && line > r.lines {
+		return 0, false, nil
+	}
+
+	k := line / lineIndexInterval
+	if k >= int64(len(r.index)) {
+		k = int64(len(r.index)) - 1
+	}
+	cur, offset := k*lineIndexInterval, r.index[k]
+	if r.lastLine > cur && r.lastLine <= line {
+		cur, offset = r.lastLine, r.lastOffset
+	}
 
-	offset := r.lastOffset()
-	for int64(len(r.index)) < line {
-		n, err := r.r.ReadAt(buf[:], offset)
+	lineStart := offset
+	for cur < line {
+		nr, err := r.r.ReadAt(r.scan[:], offset)
 		if err != nil && err != io.EOF {
-			return err
+			return 0, false, err
 		}
-		for _, b := range buf[:n] {
+
+		for _, b := range r.scan[:nr] {
 			offset++
 			if b == '\n' {
-				r.index = append(r.index, offset)
+				cur, lineStart = cur+1, offset
+				r.addIndex(cur, offset)
+				if cur == line {
+					return offset, true, nil
+				}
 			}
 		}
-		if err == io.EOF {
-			if offset > r.lastOffset() {
-				r.index = append(r.index, offset)
+
+		if err == io.EOF || nr == 0 {
+			if offset > lineStart {
+				// the last line does not end with a newline
+				cur++
+				r.addIndex(cur, offset)
 			}
-			r.eof = true
+			r.eof, r.lines, r.size = true, cur, offset
 			break
 		}
 	}
-	return nil
+
+	if cur != line {
+		return 0, false, nil
+	}
+	return offset, true, nil
 }
 
-func (r *lineReaderAt) lastOffset() int64 {
-	if n := len(r.index); n > 0 {
-		return r.index[n-1]
+// addIndex records offset as the start of line if line needs an index entry
+// that is not already recorded.
+func (r *lineReaderAt) addIndex(line, offset int64) {
+	if line%lineIndexInterval == 0 && line/lineIndexInterval == int64(len(r.index)) {
+		r.index = append(r.index, offset)
 	}
-	return 0
 }
 
-// readBytes reads the bytes of the n lines starting at line and returns the
-// bytes and the offset of the first byte in the underlying source.
-func (r *lineReaderAt) readBytes(line, n int64) (b []byte, offset int64, err error) {
-	indexLen := int64(len(r.index))
-
-	var size int64
-	if line > indexLen {
-		offset = r.index[indexLen-1]
-	} else if line > 0 {
-		offset = r.index[line-1]
-	}
-	if n > 0 {
-		if line+n > indexLen {
-			size = r.index[indexLen-1] - offset
-		} else {
-			size = r.index[line+n-1] - offset
+// BytesLineReaderAt is a LineReaderAt that reads lines from a byte slice. It
+// also implements io.ReaderAt, so it can be passed as the source to Apply and
+// NewTextApplier.
+type BytesLineReaderAt struct {
+	b     []byte
+	index []int
+}
+
+// NewBytesLineReaderAt creates a BytesLineReaderAt that reads from b. It finds
+// the line boundaries in b once, when it is created.
+//
+// The lines returned by ReadLinesAt are slices of b, not copies. Callers must
+// not modify b while the reader is in use and must not modify the returned
+// lines.
+func NewBytesLineReaderAt(b []byte) *BytesLineReaderAt {
+	var index []int
+	for i, c := range b {
+		if c == '\n' {
+			index = append(index, i+1)
 		}
 	}
+	if len(b) > 0 && b[len(b)-1] != '\n' {
+		index = append(index, len(b))
+	}
+	return &BytesLineReaderAt{b: b, index: index}
+}
 
-	b = make([]byte, size)
-	if _, err := r.r.ReadAt(b, offset); err != nil {
-		if err == io.EOF {
-			err = errors.New("ReadLinesAt: corrupt line index or changed source data")
+// ReadLinesAt implements LineReaderAt. The returned lines alias the input
+// slice and have a capacity equal to their length.
+func (r *BytesLineReaderAt) ReadLinesAt(lines [][]byte, offset int64) (n int, err error) {
+	if offset < 0 {
+		return 0, errors.New("ReadLinesAt: negative offset")
+	}
+	if len(lines) == 0 {
+		return 0, nil
+	}
+	if offset >= int64(len(r.index)) {
+		return 0, io.EOF
+	}
+
+	for n = 0; n < len(lines) && offset+int64(n) < int64(len(r.index)); n++ {
+		lineno := int(offset) + n
+		start, end := 0, r.index[lineno]
+		if lineno > 0 {
+			start = r.index[lineno-1]
 		}
-		return nil, 0, err
+		lines[n] = r.b[start:end:end]
+	}
+
+	if n < len(lines) {
+		return n, io.EOF
 	}
-	return b, offset, nil
+	return n, nil
+}
+
+// ReadAt implements io.ReaderAt.
+func (r *BytesLineReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errors.New("ReadAt: negative offset")
+	}
+	if off >= int64(len(r.b)) {
+		return 0, io.EOF
+	}
+	n = copy(p, r.b[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
 }
 
 func isLen(r io.ReaderAt, n int64) (bool, error) {
@@ -188,9 +289,22 @@ func copyFrom(dst io.Writer, src io.ReaderAt, off int64) (written int64, err err
 // the end of src or at the first error. copyLinesFrom returns the number of
 // lines written and any error.
 func copyLinesFrom(dst io.Writer, src LineReaderAt, off int64) (written int64, err error) {
+	return copyLines(dst, src, off, -1)
+}
+
+// copyLines writes n lines starting from line off in src to dst, or all lines
+// if n is negative, stopping at the end of src or at the first error. It reads
+// a fixed number of lines at a time, so memory use does not depend on n.
+// copyLines returns the number of lines written and any error. It does not
+// return an error if src ends before n lines.
+func copyLines(dst io.Writer, src LineReaderAt, off, n int64) (written int64, err error) {
 	buf := make([][]byte, lineBufferSize)
 ReadLoop:
-	for {
+	for n < 0 || written < n {
+		if n >= 0 && n-written < int64(len(buf)) {
+			buf = buf[:n-written]
+		}
+
 		nr, rerr := src.ReadLinesAt(buf, off)
 		if nr > 0 {
 			for _, line := range buf[0:nr] {
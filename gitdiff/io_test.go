@@ -168,6 +168,149 @@ func TestLineReaderAt(t *testing.T) {
 	}
 }
 
+func TestLineReaderAtRandomAccess(t *testing.T) {
+	const lines = 1000
+
+	var input bytes.Buffer
+	for i := 0; i < lines; i++ {
+		fmt.Fprintf(&input, "line %d%s\n", i, bytes.Repeat([]byte("x"), i%7))
+	}
+	expected := bytes.SplitAfter(input.Bytes(), []byte("\n"))
+
+	r := &lineReaderAt{r: bytes.NewReader(input.Bytes())}
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		offset := rng.Int63n(lines + 10)
+		buf := make([][]byte, 1+rng.Intn(2*lineIndexInterval))
+
+		n, err := r.ReadLinesAt(buf, offset)
+		count := len(buf)
+		if offset+int64(count) > lines {
+			count = 0
+			if offset < lines {
+				count = lines - int(offset)
+			}
+			if err != io.EOF {
+				t.Fatalf("expected EOF reading %d lines at %d, but got: %v", len(buf), offset, err)
+			}
+		} else if err != nil {
+			t.Fatalf("unexpected error reading %d lines at %d: %v", len(buf), offset, err)
+		}
+
+		if n != count {
+			t.Fatalf("incorrect number of lines read at %d: expected %d, actual %d", offset, count, n)
+		}
+		for j := 0; j < n; j++ {
+			if !bytes.Equal(expected[offset+int64(j)], buf[j]) {
+				t.Fatalf("incorrect content in line %d:\nexpected: %q\nactual: %q", offset+int64(j), expected[offset+int64(j)], buf[j])
+			}
+		}
+	}
+}
+
+func TestBytesLineReaderAt(t *testing.T) {
+	tests := map[string]struct {
+		Input  string
+		Offset int64
+		Count  int
+		Lines  []string
+		Err    error
+	}{
+		"readLines": {
+			Input:  "line 0\nline 1\nline 2\nline 3\n",
+			Offset: 1,
+			Count:  2,
+			Lines:  []string{"line 1\n", "line 2\n"},
+		},
+		"readThroughEOF": {
+			Input:  "line 0\nline 1\nline 2\n",
+			Offset: 1,
+			Count:  4,
+			Lines:  []string{"line 1\n", "line 2\n"},
+			Err:    io.EOF,
+		},
+		"noFinalNewline": {
+			Input:  "line 0\nline 1",
+			Offset: 0,
+			Count:  2,
+			Lines:  []string{"line 0\n", "line 1"},
+		},
+		"emptyLines": {
+			Input:  "\n\nline 2\n",
+			Offset: 0,
+			Count:  3,
+			Lines:  []string{"\n", "\n", "line 2\n"},
+		},
+		"emptyInput": {
+			Input: "",
+			Count: 2,
+			Err:   io.EOF,
+		},
+		"offsetAfterEOF": {
+			Input:  "line 0\n",
+			Offset: 2,
+			Count:  1,
+			Err:    io.EOF,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := NewBytesLineReaderAt([]byte(test.Input))
+			lines := make([][]byte, test.Count)
+
+			n, err := r.ReadLinesAt(lines, test.Offset)
+			if err != test.Err {
+				t.Fatalf("incorrect error: expected %v, actual %v", test.Err, err)
+			}
+			if n != len(test.Lines) {
+				t.Fatalf("incorrect number of lines read: expected %d, actual %d", len(test.Lines), n)
+			}
+			for i := 0; i < n; i++ {
+				if string(lines[i]) != test.Lines[i] {
+					t.Errorf("incorrect content in line %d:\nexpected: %q\nactual: %q", i, test.Lines[i], lines[i])
+				}
+				if cap(lines[i]) != len(lines[i]) {
+					t.Errorf("incorrect capacity of line %d: expected %d, actual %d", i, len(lines[i]), cap(lines[i]))
+				}
+			}
+		})
+	}
+
+	t.Run("negativeOffset", func(t *testing.T) {
+		r := NewBytesLineReaderAt([]byte("line 0\n"))
+		if _, err := r.ReadLinesAt(make([][]byte, 1), -1); err == nil {
+			t.Fatal("expected error reading lines, but got nil")
+		}
+	})
+
+	t.Run("aliasesInput", func(t *testing.T) {
+		input := []byte("line 0\nline 1\n")
+		r := NewBytesLineReaderAt(input)
+
+		lines := make([][]byte, 1)
+		if _, err := r.ReadLinesAt(lines, 1); err != nil {
+			t.Fatalf("unexpected error reading lines: %v", err)
+		}
+		if &lines[0][0] != &input[7] {
+			t.Error("line does not alias the input")
+		}
+	})
+
+	t.Run("readAt", func(t *testing.T) {
+		r := NewBytesLineReaderAt([]byte("line 0\nline 1\n"))
+
+		b := make([]byte, 8)
+		n, err := r.ReadAt(b, 10)
+		if err != io.EOF {
+			t.Fatalf("incorrect error: expected %v, actual %v", io.EOF, err)
+		}
+		if string(b[:n]) != "e 1\n" {
+			t.Errorf("incorrect content: expected %q, actual %q", "e 1\n", b[:n])
+		}
+	})
+}
+
 func TestCopyFrom(t *testing.T) {
 	tests := map[string]struct {
 		Bytes  int64
@@ -0,0 +1,170 @@
+package gitdiff
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"unicode/utf8"
+)
+
+// MarshalJSON implements json.Marshaler for File. It uses the default
+// encoding, except that file modes are encoded as octal strings like "100644".
+// Operations and binary patch methods are encoded as described by the
+// MarshalText methods of LineOp and BinaryPatchMethod, and lines are encoded
+// as described by Line.MarshalJSON.
+func (f *File) MarshalJSON() ([]byte, error) {
+	type file File
+
+	parentModes := make([]jsonFileMode, len(f.ParentModes))
+	for i, m := range f.ParentModes {
+		parentModes[i] = jsonFileMode(m)
+	}
+	if f.ParentModes == nil {
+		parentModes = nil
+	}
+
+	return json.Marshal(struct {
+		*file
+		OldMode     jsonFileMode
+		NewMode     jsonFileMode
+		ParentModes []jsonFileMode
+	}{
+		file:        (*file)(f),
+		OldMode:     jsonFileMode(f.OldMode),
+		NewMode:     jsonFileMode(f.NewMode),
+		ParentModes: parentModes,
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler for File. It accepts the format
+// produced by MarshalJSON.
+func (f *File) UnmarshalJSON(data []byte) error {
+	type file File
+
+	var v struct {
+		*file
+		OldMode     jsonFileMode
+		NewMode     jsonFileMode
+		ParentModes []jsonFileMode
+	}
+	v.file = (*file)(f)
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	f.OldMode = os.FileMode(v.OldMode)
+	f.NewMode = os.FileMode(v.NewMode)
+	f.ParentModes = nil
+	for _, m := range v.ParentModes {
+		f.ParentModes = append(f.ParentModes, os.FileMode(m))
+	}
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler for Line. It uses the default
+// encoding, except that JSON strings must be valid UTF-8, so a line that is
+// not valid UTF-8 is encoded as base64 in a LineBase64 field instead of in the
+// Line field.
+func (l Line) MarshalJSON() ([]byte, error) {
+	type line Line
+
+	if utf8.ValidString(l.Line) {
+		return json.Marshal(line(l))
+	}
+	return json.Marshal(struct {
+		line
+		Line       *string `json:",omitempty"`
+		LineBase64 []byte
+	}{
+		line:       line(l),
+		LineBase64: []byte(l.Line),
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler for Line. It accepts the format
+// produced by MarshalJSON.
+func (l *Line) UnmarshalJSON(data []byte) error {
+	type line Line
+
+	var v struct {
+		*line
+		LineBase64 []byte
+	}
+	v.line = (*line)(l)
+
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if v.LineBase64 != nil {
+		l.Line = string(v.LineBase64)
+	}
+	return nil
+}
+
+// jsonFileMode is a file mode that is encoded as an octal string.
+type jsonFileMode os.FileMode
+
+func (m jsonFileMode) MarshalText() ([]byte, error) {
+	return strconv.AppendUint(nil, uint64(m), 8), nil
+}
+
+func (m *jsonFileMode) UnmarshalText(text []byte) error {
+	mode, err := strconv.ParseUint(string(text), 8, 32)
+	if err != nil {
+		return fmt.Errorf("invalid file mode: %q", text)
+	}
+	*m = jsonFileMode(mode)
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler for LineOp. The operation is
+// encoded as the character that marks it in a patch: " ", "-", or "+".
+func (op LineOp) MarshalText() ([]byte, error) {
+	switch op {
+	case OpContext, OpDelete, OpAdd:
+		return []byte(op.String()), nil
+	}
+	return nil, fmt.Errorf("unknown line operation: %d", int(op))
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler for LineOp.
+func (op *LineOp) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case " ":
+		*op = OpContext
+	case "-":
+		*op = OpDelete
+	case "+":
+		*op = OpAdd
+	default:
+		return fmt.Errorf("invalid line operation: %q", text)
+	}
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler for BinaryPatchMethod. The
+// method is encoded as "delta" or "literal", as in a patch.
+func (m BinaryPatchMethod) MarshalText() ([]byte, error) {
+	switch m {
+	case BinaryPatchDelta:
+		return []byte("delta"), nil
+	case BinaryPatchLiteral:
+		return []byte("literal"), nil
+	}
+	return nil, fmt.Errorf("unknown binary patch method: %d", int(m))
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler for BinaryPatchMethod.
+func (m *BinaryPatchMethod) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "delta":
+		*m = BinaryPatchDelta
+	case "literal":
+		*m = BinaryPatchLiteral
+	default:
+		return fmt.Errorf("invalid binary patch method: %q", text)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package gitdiff
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileJSONRoundtrip(t *testing.T) {
+	patches := []string{
+		"binary_modify.patch",
+		"binary_new.patch",
+		"combined.patch",
+		"combined_mode.patch",
+		"copy_modify.patch",
+		"delete.patch",
+		"mode_modify.patch",
+		"new_empty.patch",
+		"rename_modify.patch",
+	}
+
+	for _, patch := range patches {
+		t.Run(patch, func(t *testing.T) {
+			b, err := os.ReadFile(filepath.Join("testdata", "string", patch))
+			if err != nil {
+				t.Fatalf("failed to read patch: %v", err)
+			}
+			original := assertParseSingleFile(t, b, "patch")
+
+			data, err := json.Marshal(original)
+			if err != nil {
+				t.Fatalf("failed to marshal file: %v", err)
+			}
+
+			var decoded File
+			if err := json.Unmarshal(data, &decoded); err != nil {
+				t.Fatalf("failed to unmarshal file: %v", err)
+			}
+
+			assertFilesEqual(t, original, &decoded)
+			if original.String() != decoded.String() {
+				t.Errorf("incorrect patch text\nexpected: %q\n  actual: %q", original.String(), decoded.String())
+			}
+		})
+	}
+}
+
+func TestFileJSONRoundtripInvalidUTF8(t *testing.T) {
+	const patch = "diff --git a/menu.txt b/menu.txt\n" +
+		"index 1c23fcc..40a1b33 100644\n" +
+		"--- a/menu.txt\n" +
+		"+++ b/menu.txt\n" +
+		"@@ -1,2 +1,2 @@\n" +
+		" th\xe9\n" +
+		"-caf\xe9\n" +
+		"+caf\xe9 au lait\n"
+
+	original := assertParseSingleFile(t, []byte(patch), "patch")
+
+	data, err := json.Marshal(original)
+	if err != nil {
+		t.Fatalf("failed to marshal file: %v", err)
+	}
+	if expected := `"LineBase64":"Y2Fm6Qo="`; !strings.Contains(string(data), expected) {
+		t.Errorf("JSON does not contain %s\n%s", expected, data)
+	}
+
+	var decoded File
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("failed to unmarshal file: %v", err)
+	}
+
+	assertFilesEqual(t, original, &decoded)
+	if original.String() != decoded.String() {
+		t.Errorf("incorrect patch text\nexpected: %q\n  actual: %q", original.String(), decoded.String())
+	}
+}
+
+func TestFileMarshalJSON(t *testing.T) {
+	b, err := os.ReadFile(filepath.Join("testdata", "string", "mode_modify.patch"))
+	if err != nil {
+		t.Fatalf("failed to read patch: %v", err)
+	}
+	f := assertParseSingleFile(t, b, "patch")
+
+	data, err := json.Marshal(f)
+	if err != nil {
+		t.Fatalf("failed to marshal file: %v", err)
+	}
+
+	for _, expected := range []string{
+		`"OldMode":"100644"`,
+		`"NewMode":"100755"`,
+		`{"Op":" ","Line":"#!/bin/bash\n"`,
+		`{"Op":"-","Line":"echo \"Hello World\"\n"`,
+		`{"Op":"+","Line":"echo \"Hello, World!\"\n"`,
+	} {
+		if !strings.Contains(string(data), expected) {
+			t.Errorf("JSON does not contain %s\n%s", expected, data)
+		}
+	}
+}
+
+func TestFileUnmarshalJSONErrors(t *testing.T) {
+	tests := map[string]string{
+		"invalidMode":   `{"OldMode":"100899"}`,
+		"invalidOp":     `{"TextFragments":[{"Lines":[{"Op":"?","Line":"a\n"}]}]}`,
+		"invalidMethod": `{"BinaryFragment":{"Method":"zstd"}}`,
+	}
+
+	for name, input := range tests {
+		t.Run(name, func(t *testing.T) {
+			var f File
+			if err := json.Unmarshal([]byte(input), &f); err == nil {
+				t.Fatalf("expected error unmarshaling JSON, but got nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package gitdiff
+
+import (
+	"bufio"
+	"fmt"
+)
+
+// LimitError is returned when parsing input that exceeds a limit set by a
+// parser option, like WithMaxLineLength.
+type LimitError struct {
+	// Limit describes the limit that was exceeded, like "line length"
+	Limit string
+	// Max is the value of the limit
+	Max int64
+	// Line is the one-indexed line number in the input where the limit was
+	// exceeded
+	Line int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("gitdiff: line %d: %s exceeds limit of %d", e.Line, e.Limit, e.Max)
+}
+
+// limitedLineReader reads lines from a bufio.Reader and returns a *LimitError
+// instead of reading a line that is longer than max bytes, including the
+// newline character.
+type limitedLineReader struct {
+	r     *bufio.Reader
+	max   int
+	lines int64
+}
+
+func (lr *limitedLineReader) ReadString(delim byte) (string, error) {
+	var line []byte
+	for {
+		b, err := lr.r.ReadSlice(delim)
+		if len(line)+len(b) > lr.max {
+			return "", &LimitError{Limit: "line length", Max: int64(lr.max), Line: lr.lines + 1}
+		}
+		line = append(line, b...)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if len(line) > 0 {
+			lr.lines++
+		}
+		return string(line), err
+	}
+}
+
+// checkFragmentLimit returns a *LimitError if a file that already has n
+// fragments cannot have another fragment.
+func (p *parser) checkFragmentLimit(n int) error {
+	if max := p.opts.maxFragments; max > 0 && n >= max {
+		return &LimitError{Limit: "fragments per file", Max: int64(max), Line: p.lineno - 1}
+	}
+	return nil
+}
+
+// checkFileSizeLimit returns a *LimitError if the size of the fragments of
+// the current file exceeds the limit. It is called before advancing to the
+// next line.
+func (p *parser) checkFileSizeLimit() error {
+	max := p.opts.maxFileSize
+	if max <= 0 || !p.inFragments {
+		return nil
+	}
+	if size := p.offset + int64(len(p.raw[0])) - p.fragmentStart; size > max {
+		return &LimitError{Limit: "file size", Max: max, Line: p.lineno}
+	}
+	return nil
+}
@@ -0,0 +1,237 @@
+package gitdiff
+
+import (
+	"bufio"
+	"io"
+	"mime"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	mailboxSeparatorDate = "Mon Sep 17 00:00:00 2001"
+	mailboxZeroSHA       = "0000000000000000000000000000000000000000"
+	mailDateFormat       = "Mon, 2 Jan 2006 15:04:05 -0700"
+)
+
+// Patch is a single patch from a mailbox, containing the parsed header and
+// the files changed by the patch.
+type Patch struct {
+	Header *PatchHeader
+	Files  []*File
+}
+
+// ParseMailbox parses a UNIX mailbox containing one or more patches, like the
+// output of `git format-patch --stdout` for a series of commits. It splits the
+// input into messages at each "From " separator line and parses the header and
+// files of each message. Lines in the message that were escaped with a leading
+// ">" character, like ">From ", are unescaped before parsing.
+//
+// Options are applied when parsing the header of each message. If an error
+// occurs while parsing, ParseMailbox returns all patches parsed before the
+// error.
+func ParseMailbox(r io.Reader, options ...PatchHeaderOption) ([]*Patch, error) {
+	var patches []*Patch
+
+	parseMessage := func(msg string) error {
+		if strings.TrimSpace(msg) == "" {
+			return nil
+		}
+
+		files, preamble, err := Parse(strings.NewReader(msg))
+		if err != nil {
+			return err
+		}
+		header, err := ParsePatchHeader(preamble, options...)
+		if err != nil {
+			return err
+		}
+
+		patches = append(patches, &Patch{Header: header, Files: files})
+		return nil
+	}
+
+	br := bufio.NewReader(r)
+
+	var msg strings.Builder
+	prevBlank := true
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return patches, err
+		}
+		if line == "" {
+			break
+		}
+
+		if prevBlank && isMailboxSeparator(line) {
+			if perr := parseMessage(msg.String()); perr != nil {
+				return patches, perr
+			}
+			msg.Reset()
+		}
+		prevBlank = strings.TrimRight(line, "\r\n") == ""
+
+		msg.WriteString(unescapeMailboxLine(line))
+		if err == io.EOF {
+			break
+		}
+	}
+
+	if err := parseMessage(msg.String()); err != nil {
+		return patches, err
+	}
+	return patches, nil
+}
+
+// Format writes the header and files as a mailbox message, like the output of
+// `git format-patch`. The message starts with a "From " separator line, has
+// From, Date, and Subject headers using RFC 2047 encoding for non-ASCII text,
+// and contains the commit message, a "---" line, a diffstat of the files, and
+// the diff of each file. The BodyAppendix, if any, appears after the "---"
+// line. Lines in the body that start with "From ", possibly after ">"
+// characters, are escaped with a leading ">" so ParseMailbox can restore them. If SubjectPrefix is empty, the subject uses the prefix "[PATCH]".
+//
+// Options are applied when formatting each file. Format returns the number of
+// bytes written and any error that occurred while writing.
+func (h *PatchHeader) Format(w io.Writer, files []*File, options ...FormatOption) (int64, error) {
+	fm := newFormatter(w)
+	for _, opt := range options {
+		opt(&fm.opts)
+	}
+
+	sha := h.SHA
+	if sha == "" {
+		sha = mailboxZeroSHA
+	}
+	fm.WriteString(mailHeaderPrefix + sha + " " + mailboxSeparatorDate + "\n")
+
+	if h.Author != nil {
+		fm.WriteString("From: " + formatMailIdentity(*h.Author) + "\n")
+	}
+	if !h.AuthorDate.IsZero() {
+		fm.WriteString("Date: " + h.AuthorDate.Format(mailDateFormat) + "\n")
+	}
+
+	prefix := h.SubjectPrefix
+	if prefix == "" {
+		prefix = "[PATCH]"
+	}
+	if !strings.HasSuffix(prefix, " ") {
+		prefix += " "
+	}
+	// only encode the title so parsers can remove the prefix before decoding
+	fm.WriteString("Subject: " + prefix + mime.QEncoding.Encode("UTF-8", h.Title) + "\n")
+
+	if !isASCII(h.Title) || !isASCII(h.Body) || !isASCII(h.BodyAppendix) {
+		fm.WriteString("MIME-Version: 1.0\n")
+		fm.WriteString("Content-Type: text/plain; charset=UTF-8\n")
+		fm.WriteString("Content-Transfer-Encoding: 8bit\n")
+	}
+	fm.WriteByte('\n')
+
+	if h.Body != "" {
+		fm.WriteString(escapeMailboxLines(strings.TrimRight(h.Body, "\n")) + "\n")
+	}
+	fm.WriteString("---\n")
+	if h.BodyAppendix != "" {
+		fm.WriteString(escapeMailboxLines(strings.TrimRight(h.BodyAppendix, "\n")) + "\n\n")
+	}
+
+	if len(files) > 0 {
+		fm.WriteString(Stat(files).String())
+		fm.WriteByte('\n')
+	}
+	for _, f := range files {
+		fm.FormatFile(f)
+	}
+	return fm.n, fm.err
+}
+
+// formatMailIdentity formats an identity for a mail header. Like Git, it
+// encodes names with non-ASCII characters and quotes names that contain
+// special characters.
+func formatMailIdentity(id PatchIdentity) string {
+	const specials = "()<>[]:;@\\,.\""
+
+	name := id.Name
+	switch {
+	case name == "":
+		return id.Email
+	case !isASCII(name):
+		name = mime.QEncoding.Encode("UTF-8", name)
+	case strings.ContainsAny(name, specials):
+		name = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(name) + `"`
+	}
+	return name + " <" + id.Email + ">"
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// isMailboxSeparator returns true if line is a "From " line that starts a new
+// message in a mailbox. Like `git mailsplit`, it requires a date after the
+// sender to avoid splitting on message lines that happen to start with "From".
+func isMailboxSeparator(line string) bool {
+	_, ok := parseMailboxSeparator(line)
+	return ok
+}
+
+// parseMailboxSeparator parses the date from a mailbox "From " line. It
+// returns false if the line is not a valid separator.
+func parseMailboxSeparator(line string) (time.Time, bool) {
+	const (
+		dateLayout     = "Mon Jan _2 15:04:05 2006"
+		dateZoneLayout = "Mon Jan _2 15:04:05 2006 -0700"
+	)
+
+	if !strings.HasPrefix(line, mailHeaderPrefix) {
+		return time.Time{}, false
+	}
+
+	line = strings.TrimSpace(line[len(mailHeaderPrefix):])
+	sp := strings.IndexByte(line, ' ')
+	if sp < 0 {
+		return time.Time{}, false
+	}
+	date := strings.TrimSpace(line[sp+1:])
+
+	for _, layout := range []string{dateLayout, dateZoneLayout} {
+		if t, err := time.Parse(layout, date); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// escapeMailboxLines adds a leading '>' character to lines in s that match the
+// pattern "^>*From ", so that unescapeMailboxLine restores them.
+func escapeMailboxLines(s string) string {
+	lines := strings.SplitAfter(s, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimLeft(line, ">"), mailHeaderPrefix) {
+			lines[i] = ">" + line
+		}
+	}
+	return strings.Join(lines, "")
+}
+
+// unescapeMailboxLine removes one leading '>' character from lines that match
+// the pattern "^>+From ", as done by readers of the mboxrd format.
+func unescapeMailboxLine(line string) string {
+	i := 0
+	for i < len(line) && line[i] == '>' {
+		i++
+	}
+	if i > 0 && strings.HasPrefix(line[i:], mailHeaderPrefix) {
+		return line[1:]
+	}
+	return line
+}
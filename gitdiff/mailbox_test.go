@@ -0,0 +1,241 @@
+package gitdiff
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseMailbox(t *testing.T) {
+	f, err := os.Open("testdata/mailbox.mbox")
+	if err != nil {
+		t.Fatalf("unexpected error opening input file: %v", err)
+	}
+	defer f.Close()
+
+	patches, err := ParseMailbox(f)
+	if err != nil {
+		t.Fatalf("unexpected error parsing mailbox: %v", err)
+	}
+	if len(patches) != 2 {
+		t.Fatalf("incorrect number of patches: expected 2, actual %d", len(patches))
+	}
+
+	expected := []struct {
+		SHA      string
+		Title    string
+		Body     string
+		NewOID   string
+		NewLines int64
+	}{
+		{
+			SHA:      "a18f293a8114656cc1f0e3a46b1ddca03cc26216",
+			Title:    "Add line b",
+			Body:     "From the start, this line was needed.",
+			NewOID:   "422c2b7",
+			NewLines: 2,
+		},
+		{
+			SHA:      "3f223a736d3c482baa9293d082089843a82deb4b",
+			Title:    "Add line c",
+			NewOID:   "de98044",
+			NewLines: 3,
+		},
+	}
+
+	for i, exp := range expected {
+		p := patches[i]
+		if p.Header.SHA != exp.SHA {
+			t.Errorf("patch %d: incorrect SHA: expected %q, actual %q", i, exp.SHA, p.Header.SHA)
+		}
+		if p.Header.Title != exp.Title {
+			t.Errorf("patch %d: incorrect title: expected %q, actual %q", i, exp.Title, p.Header.Title)
+		}
+		if p.Header.Body != exp.Body {
+			t.Errorf("patch %d: incorrect body: expected %q, actual %q", i, exp.Body, p.Header.Body)
+		}
+		if p.Header.Author == nil || p.Header.Author.Email != "morton@example.com" {
+			t.Errorf("patch %d: incorrect author: %v", i, p.Header.Author)
+		}
+		if len(p.Files) != 1 {
+			t.Fatalf("patch %d: incorrect number of files: expected 1, actual %d", i, len(p.Files))
+		}
+		if p.Files[0].NewOIDPrefix != exp.NewOID {
+			t.Errorf("patch %d: incorrect new OID: expected %q, actual %q", i, exp.NewOID, p.Files[0].NewOIDPrefix)
+		}
+		if n := p.Files[0].TextFragments[0].NewLines; n != exp.NewLines {
+			t.Errorf("patch %d: incorrect new lines: expected %d, actual %d", i, exp.NewLines, n)
+		}
+	}
+}
+
+func TestParseMailboxSeparator(t *testing.T) {
+	tests := map[string]bool{
+		"From a18f293a8114656cc1f0e3a46b1ddca03cc26216 Mon Sep 17 00:00:00 2001\n":  true,
+		"From sender@example.com Thu Nov  5 12:00:00 2020\n":                        true,
+		"From sender@example.com Thu Nov  5 12:00:00 2020 -0700\n":                  true,
+		"From the start, this line was needed.\n":                                   false,
+		"From: Morton Haypenny <morton@example.com>\n":                              false,
+		">From a18f293a8114656cc1f0e3a46b1ddca03cc26216 Mon Sep 17 00:00:00 2001\n": false,
+	}
+
+	for line, expected := range tests {
+		if actual := isMailboxSeparator(line); actual != expected {
+			t.Errorf("incorrect result for %q: expected %t, actual %t", strings.TrimSpace(line), expected, actual)
+		}
+	}
+}
+
+func TestUnescapeMailboxLine(t *testing.T) {
+	tests := map[string]string{
+		">From the start\n":  "From the start\n",
+		">>From the start\n": ">From the start\n",
+		"> From the start\n": "> From the start\n",
+		">quoted text\n":     ">quoted text\n",
+		"From the start\n":   "From the start\n",
+	}
+
+	for input, expected := range tests {
+		if actual := unescapeMailboxLine(input); actual != expected {
+			t.Errorf("incorrect result for %q: expected %q, actual %q", input, expected, actual)
+		}
+	}
+}
+
+func TestPatchHeaderFormat(t *testing.T) {
+	const diff = `diff --git a/dir/file.txt b/dir/file.txt
+index 1c23fcc..40a1b33 100644
+--- a/dir/file.txt
++++ b/dir/file.txt
+@@ -1,2 +1,2 @@
+ one
+-two
++zwei
+`
+
+	files, _, err := Parse(strings.NewReader(diff))
+	if err != nil {
+		t.Fatalf("failed to parse diff: %v", err)
+	}
+
+	tests := map[string]struct {
+		Header *PatchHeader
+		Output string
+	}{
+		"ascii": {
+			Header: &PatchHeader{
+				SHA: "61f5cd90bed4d204ee3feb3aa41ee91d4734855b",
+				Author: &PatchIdentity{
+					Name:  "Morton Haypenny",
+					Email: "mhaypenny@example.com",
+				},
+				AuthorDate: time.Date(2020, 4, 11, 15, 21, 23, 0, time.FixedZone("PDT", -7*60*60)),
+				Title:      "A sample commit to test header parsing",
+				Body:       "The medium format shows the body, which\nmay wrap on to multiple lines.",
+			},
+			Output: `From 61f5cd90bed4d204ee3feb3aa41ee91d4734855b Mon Sep 17 00:00:00 2001
+From: Morton Haypenny <mhaypenny@example.com>
+Date: Sat, 11 Apr 2020 15:21:23 -0700
+Subject: [PATCH] A sample commit to test header parsing
+
+The medium format shows the body, which
+may wrap on to multiple lines.
+---
+ dir/file.txt | 2 +-
+ 1 file changed, 1 insertion(+), 1 deletion(-)
+
+` + diff,
+		},
+		"fromLines": {
+			Header: &PatchHeader{
+				Author: &PatchIdentity{
+					Name:  "Morton Haypenny",
+					Email: "mhaypenny@example.com",
+				},
+				Title: "Quote a mail in the message",
+				Body:  "From the original report:\n\n>From the logs\nFrom here on, it fails.",
+			},
+			Output: `From 0000000000000000000000000000000000000000 Mon Sep 17 00:00:00 2001
+From: Morton Haypenny <mhaypenny@example.com>
+Subject: [PATCH] Quote a mail in the message
+
+>From the original report:
+
+>>From the logs
+>From here on, it fails.
+---
+ dir/file.txt | 2 +-
+ 1 file changed, 1 insertion(+), 1 deletion(-)
+
+` + diff,
+		},
+		"nonASCII": {
+			Header: &PatchHeader{
+				Author: &PatchIdentity{
+					Name:  "Mörton Haypenny",
+					Email: "mhaypenny@example.com",
+				},
+				SubjectPrefix: "[PATCH v2 1/2]",
+				Title:         "Füge Übersetzung hinzu",
+				BodyAppendix:  "Changes since v1: none",
+			},
+			Output: `From 0000000000000000000000000000000000000000 Mon Sep 17 00:00:00 2001
+From: =?UTF-8?q?M=C3=B6rton_Haypenny?= <mhaypenny@example.com>
+Subject: [PATCH v2 1/2] =?UTF-8?q?F=C3=BCge_=C3=9Cbersetzung_hinzu?=
+MIME-Version: 1.0
+Content-Type: text/plain; charset=UTF-8
+Content-Transfer-Encoding: 8bit
+
+---
+Changes since v1: none
+
+ dir/file.txt | 2 +-
+ 1 file changed, 1 insertion(+), 1 deletion(-)
+
+` + diff,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var b strings.Builder
+			n, err := test.Header.Format(&b, files)
+			if err != nil {
+				t.Fatalf("unexpected error formatting header: %v", err)
+			}
+			if b.String() != test.Output {
+				t.Errorf("incorrect output\nexpected:\n%s\nactual:\n%s", test.Output, b.String())
+			}
+			if n != int64(b.Len()) {
+				t.Errorf("incorrect byte count: expected %d, actual %d", b.Len(), n)
+			}
+
+			patches, err := ParseMailbox(strings.NewReader(b.String()))
+			if err != nil {
+				t.Fatalf("failed to parse formatted mailbox: %v", err)
+			}
+			if len(patches) != 1 {
+				t.Fatalf("incorrect number of patches: expected 1, actual %d", len(patches))
+			}
+
+			h := patches[0].Header
+			if !reflect.DeepEqual(h.Author, test.Header.Author) {
+				t.Errorf("incorrect author: expected %+v, actual %+v", test.Header.Author, h.Author)
+			}
+			if !h.AuthorDate.Equal(test.Header.AuthorDate) {
+				t.Errorf("incorrect author date: expected %v, actual %v", test.Header.AuthorDate, h.AuthorDate)
+			}
+			if h.Title != test.Header.Title {
+				t.Errorf("incorrect title: expected %q, actual %q", test.Header.Title, h.Title)
+			}
+			if h.Body != test.Header.Body {
+				t.Errorf("incorrect body: expected %q, actual %q", test.Header.Body, h.Body)
+			}
+			if len(patches[0].Files) != 1 || patches[0].Files[0].String() != diff {
+				t.Errorf("incorrect files after round trip: %+v", patches[0].Files)
+			}
+		})
+	}
+}
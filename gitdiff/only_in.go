@@ -0,0 +1,144 @@
+package gitdiff
+
+import (
+	"io"
+	"strings"
+)
+
+const onlyInPrefix = "Only in "
+
+// maxOnlyInReadAhead is the maximum number of files a FileReader reads while
+// waiting to find the side of a file from an "Only in" line.
+const maxOnlyInReadAhead = 64
+
+// ParseOnlyInHeader parses an "Only in <dir>: <name>" line, as generated by
+// `diff -r` for files that exist in only one of the compared directories. The
+// line is the only information about the file, so it acts as the header of a
+// file with no fragments.
+//
+// Lines that look like "Only in" lines are common in other text, like commit
+// messages, so the parser only recognizes them after it finds a traditional
+// file header or a `diff` command line, which show that the input is from a
+// recursive diff. As a result, "Only in" lines are never recognized in Git
+// patches.
+//
+// The line does not say which side of the diff the directory is on, so the
+// file is pending until the parser finds the root directories of the diff.
+// See resolveOnlyIn for details. Callers must not parse fragments for a file
+// returned by this method.
+func (p *parser) ParseOnlyInHeader() (*File, error) {
+	line := p.Line(0)
+	if !p.recursive || !strings.HasPrefix(line, onlyInPrefix) {
+		return nil, nil
+	}
+
+	dir, name, ok := strings.Cut(strings.TrimSuffix(line[len(onlyInPrefix):], "\n"), ": ")
+	if !ok || dir == "" || name == "" {
+		return nil, nil
+	}
+
+	if err := p.Next(); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	f := &File{}
+	if p.onlyIn == nil {
+		p.onlyIn = make(map[*File]string)
+	}
+	p.onlyIn[f] = strings.TrimSuffix(dir, "/") + "/" + name
+	return f, nil
+}
+
+// isPendingOnlyIn returns true if f is from an "Only in" line and the side of
+// the diff that contains it is not known yet.
+func (p *parser) isPendingOnlyIn(f *File) bool {
+	_, ok := p.onlyIn[f]
+	return ok
+}
+
+// resolveOnlyIn sets the names and the IsNew or IsDelete flag of a pending
+// file from an "Only in" line if its path is in one of the root directories
+// of the diff. It returns false if the side of the file is still unknown.
+func (p *parser) resolveOnlyIn(f *File) bool {
+	path, ok := p.onlyIn[f]
+	if !ok {
+		return true
+	}
+
+	name := cleanName(path, p.traditionalStripLevel())
+	switch {
+	case hasPathPrefix(path, p.oldRoot):
+		f.IsDelete = true
+		f.OldName = name
+	case hasPathPrefix(path, p.newRoot):
+		f.IsNew = true
+		f.NewName = name
+	default:
+		return false
+	}
+
+	delete(p.onlyIn, f)
+	p.transformNames(f)
+	return true
+}
+
+// finishOnlyIn resolves a pending file from an "Only in" line if possible. If
+// the side of the file is still unknown, it sets both names to the path of
+// the file and leaves IsNew and IsDelete unset.
+func (p *parser) finishOnlyIn(f *File) {
+	if p.resolveOnlyIn(f) {
+		return
+	}
+
+	name := cleanName(p.onlyIn[f], p.traditionalStripLevel())
+	f.OldName, f.NewName = name, name
+	delete(p.onlyIn, f)
+	p.transformNames(f)
+}
+
+// ParseDiffCommandRoots records the root directories of a recursive diff from
+// a command line like "diff -ru a/dir/file b/dir/file", which `diff -r`
+// prints before the changes to each file. After a command line, the parser
+// recognizes "Only in" lines. It does not advance the parser.
+func (p *parser) ParseDiffCommandRoots() {
+	line := p.Line(0)
+	if !strings.HasPrefix(line, "diff ") || strings.HasPrefix(line, "diff --") {
+		return
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) < 3 {
+		return
+	}
+	p.recursive = true
+	p.setDiffRoots(fields[len(fields)-2], fields[len(fields)-1])
+}
+
+// setDiffRoots records the root directories of a recursive diff from the
+// paths of a file on each side. The roots are the paths without their common
+// trailing components. Only the first valid pair of roots is recorded.
+func (p *parser) setDiffRoots(oldPath, newPath string) {
+	if p.oldRoot != "" || oldPath == devNull || newPath == devNull {
+		return
+	}
+
+	oldParts := strings.Split(oldPath, "/")
+	newParts := strings.Split(newPath, "/")
+	for len(oldParts) > 1 && len(newParts) > 1 && oldParts[len(oldParts)-1] == newParts[len(newParts)-1] {
+		oldParts = oldParts[:len(oldParts)-1]
+		newParts = newParts[:len(newParts)-1]
+	}
+
+	oldRoot, newRoot := strings.Join(oldParts, "/"), strings.Join(newParts, "/")
+	if oldRoot == newRoot || hasPathPrefix(oldRoot, newRoot) || hasPathPrefix(newRoot, oldRoot) {
+		return
+	}
+	p.oldRoot, p.newRoot = oldRoot, newRoot
+}
+
+func hasPathPrefix(path, prefix string) bool {
+	if prefix == "" {
+		return false
+	}
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
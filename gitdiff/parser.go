@@ -1,67 +1,497 @@
 // Package gitdiff parses and applies patches generated by Git. It supports
 // line-oriented text patches, binary patches, and can also parse standard
-// unified diffs generated by other tools.
+// unified and context diffs generated by other tools.
 package gitdiff
 
 import (
 	"bufio"
+	"bytes"
+	"errors"
 	"fmt"
 	"io"
+	"strings"
 )
 
 // Parse parses a patch with changes to one or more files. Any content before
-// the first file is returned as the second value. If an error occurs while
-// parsing, it returns all files parsed before the error.
+// the first file is returned as the second value. Content after the last file
+// is not returned; use a FileReader and its Postamble method to access it. If
+// an error occurs while parsing, it returns all files parsed before the error.
+//
+// Files that exist on only one side of a recursive diff, reported by "Only in"
+// lines in the output of `diff -r`, are returned as files with no fragments
+// and with IsNew or IsDelete set. The side is determined from the "diff"
+// command lines that `diff -r` prints before each changed file. If the patch
+// has no such lines, both names are set to the path of the file and neither
+// flag is set. "Only in" lines are only recognized after a traditional file
+// header or a "diff" command line, so they are never files in Git patches.
+//
+// If the input is the output of `git range-diff`, Parse returns ErrRangeDiff
+// instead of treating the nested diffs as patches. Use ParseRangeDiff to
+// parse this input.
 //
 // Parse expects to receive a single patch. If the input may contain multiple
 // patches (for example, if it is an mbox file), callers should split it into
 // individual patches and call Parse on each one.
+//
+// Parse is equivalent to calling Parse on a Parser created with no options.
 func Parse(r io.Reader) ([]*File, string, error) {
-	p := newParser(r)
+	return NewParser().Parse(r)
+}
+
+// SplitFiles splits a patch into the raw text of each file. Each element of
+// the result starts at the header of a file and ends at the header of the next
+// file or at the end of the input. Any content before the first file is
+// returned as the second value. The elements share a single underlying array.
+//
+// SplitFiles uses the same logic as Parse to find the boundaries between
+// files, including parsing fragments so that lines within fragments are never
+// mistaken for headers. It does not keep the lines of fragments, so the input
+// is the only copy of the patch in memory. If an error occurs, SplitFiles
+// returns no files.
+func SplitFiles(r io.Reader) ([][]byte, string, error) {
+	var raw bytes.Buffer
+	fr := NewParser(WithHeadersOnly()).NewFileReader(io.TeeReader(r, &raw))
 
-	if err := p.Next(); err != nil {
+	var offsets []int64
+	for {
+		f, err := fr.Next()
 		if err == io.EOF {
-			return nil, "", nil
+			break
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		offsets = append(offsets, f.HeaderOffset)
+	}
+
+	b := raw.Bytes()
+	start := int64(0)
+	if bytes.HasPrefix(b, []byte(utf8BOM)) {
+		start = int64(len(utf8BOM))
+	}
+	if len(offsets) == 0 {
+		return nil, string(b[start:]), nil
+	}
+
+	chunks := make([][]byte, len(offsets))
+	for i, offset := range offsets {
+		end := int64(len(b))
+		if i+1 < len(offsets) {
+			end = offsets[i+1]
+		}
+		chunks[i] = b[offset:end:end]
+	}
+	return chunks, string(b[start:offsets[0]]), nil
+}
+
+// Parser parses patches using a fixed configuration. A Parser may be reused
+// for multiple patches and is safe for concurrent use.
+type Parser struct {
+	opts parserOptions
+}
+
+// A ParserOption modifies the behavior of a Parser.
+type ParserOption func(*parserOptions)
+
+// WithMaxOIDLength enables validation of the object IDs on "index" lines.
+// Parsing fails if an ID is longer than n characters or contains characters
+// that are not hexadecimal digits. Use 40 for repositories with SHA1 hashes
+// and 64 for repositories with SHA256 hashes. By default, IDs are not
+// validated.
+func WithMaxOIDLength(n int) ParserOption {
+	return func(opts *parserOptions) {
+		opts.maxOIDLength = n
+	}
+}
+
+// WithReadBufferSize sets the size of the buffer used to read input. It has no
+// effect if the input already implements a ReadString method, like
+// *bufio.Reader and *bytes.Buffer. By default, uses the default size of
+// bufio.Reader.
+func WithReadBufferSize(size int) ParserOption {
+	return func(opts *parserOptions) {
+		opts.readBufferSize = size
+	}
+}
+
+// WithStripLevel sets the number of leading path components removed from file
+// names in file headers, like the -p flag of `git apply` and `patch`. With n
+// equal to zero, names are used exactly as they appear in the patch, including
+// any "a/" or "b/" prefixes. The special name "/dev/null" is never modified.
+//
+// By default, one component is removed from names in Git headers and no
+// components are removed from names in traditional headers. Names on "rename"
+// and "copy" lines never have prefixes and are not affected by this option.
+func WithStripLevel(n int) ParserOption {
+	return func(opts *parserOptions) {
+		if n >= 0 {
+			opts.stripLevel = n
 		}
-		return nil, "", err
+	}
+}
+
+// WithNormalizeCRLF converts CRLF line endings to LF line endings in the
+// content lines of text fragments and in the preamble. Use this option if a
+// patch was converted to use CRLF line endings, but the files it modifies use
+// LF line endings. By default, the line endings of content lines are
+// preserved.
+//
+// Line endings in headers and other structural lines are always normalized, so
+// patches with CRLF line endings can be parsed without this option.
+func WithNormalizeCRLF() ParserOption {
+	return func(opts *parserOptions) {
+		opts.normalizeCRLF = true
+	}
+}
+
+// ErrorMode controls how a Parser handles errors in individual files.
+type ErrorMode int
+
+const (
+	// FailOnError stops parsing at the first error. This is the default.
+	FailOnError ErrorMode = iota
+
+	// ContinueOnError skips files that cannot be parsed. After an error, the
+	// parser resumes at the next line that looks like the start of a file
+	// header. Errors reading the input still stop parsing.
+	ContinueOnError
+)
+
+// WithErrorMode sets how the parser handles errors in individual files. See
+// ErrorMode for the available modes. By default, parsing stops at the first
+// error.
+func WithErrorMode(m ErrorMode) ParserOption {
+	return func(opts *parserOptions) {
+		opts.errorMode = m
+	}
+}
+
+// WithRawHeaders sets the RawHeader field of each parsed File to the exact
+// text of its file header. By default, the parser does not keep raw headers.
+func WithRawHeaders() ParserOption {
+	return func(opts *parserOptions) {
+		opts.rawHeaders = true
+	}
+}
+
+// WithCompressor sets the Compressor used to decompress the data in binary
+// patches. By default, the parser uses the compress/zlib package.
+func WithCompressor(c Compressor) ParserOption {
+	return func(opts *parserOptions) {
+		opts.compressor = c
+	}
+}
+
+// WithMaxLineLength sets the maximum length in bytes of a line in the input,
+// including the newline character. If a line is longer, the parser stops and
+// returns a *LimitError without reading the rest of the line, so the memory
+// used for a single line is at most n plus the size of the read buffer. By
+// default, lines can have any length.
+func WithMaxLineLength(n int) ParserOption {
+	return func(opts *parserOptions) {
+		opts.maxLineLength = n
+	}
+}
+
+// WithMaxFragmentsPerFile sets the maximum number of text fragments in a
+// single file. If a file has more fragments, the parser returns a
+// *LimitError. By default, files can have any number of fragments.
+func WithMaxFragmentsPerFile(n int) ParserOption {
+	return func(opts *parserOptions) {
+		opts.maxFragments = n
+	}
+}
+
+// WithMaxFileSize sets the maximum size in bytes of the changes to a single
+// file. For text files, this is the size of the fragments of the file in the
+// input. For binary files, it is also the size of the decoded data of each
+// binary fragment, which is checked before decoding. If a file is larger, the
+// parser returns a *LimitError. By default, files can have any size.
+func WithMaxFileSize(n int64) ParserOption {
+	return func(opts *parserOptions) {
+		opts.maxFileSize = n
+	}
+}
+
+// WithBareContextLines sets the BareContext field of empty context lines that
+// do not start with a space character, so that formatting the parsed files
+// reproduces the original lines. By default, these lines are parsed like any
+// other context line and are formatted with a leading space.
+func WithBareContextLines() ParserOption {
+	return func(opts *parserOptions) {
+		opts.bareContextLines = true
+	}
+}
+
+// WithNameTransform sets a function that rewrites the names of each file, like
+// removing or replacing a directory prefix. The parser calls fn with the old
+// and new names of a file once they are known, before parsing the fragments of
+// the file, and sets the names to the values it returns. The old name is
+// empty for new files and the new name is empty for deleted files. The
+// function is not called for files with no names. By default, names are not
+// transformed.
+func WithNameTransform(fn func(oldName, newName string) (string, string)) ParserOption {
+	return func(opts *parserOptions) {
+		opts.nameTransform = fn
+	}
+}
+
+// WithHeadersOnly parses text fragments without keeping their lines, which
+// reduces memory use when only the names, modes, and line counts of each file
+// are needed. The parser still reads and validates every line of a fragment
+// to set its positions and counts, but the Lines field of each fragment is
+// empty and information derived from lines, like the commits of submodules,
+// is not set. Files parsed with this option cannot be applied or formatted.
+func WithHeadersOnly() ParserOption {
+	return func(opts *parserOptions) {
+		opts.headersOnly = true
+	}
+}
+
+type parserOptions struct {
+	maxOIDLength   int
+	readBufferSize int
+	stripLevel     int
+	normalizeCRLF  bool
+	errorMode      ErrorMode
+	rawHeaders     bool
+	compressor     Compressor
+	maxLineLength  int
+	maxFragments   int
+	maxFileSize    int64
+
+	bareContextLines bool
+	nameTransform    func(oldName, newName string) (string, string)
+	headersOnly      bool
+}
+
+func defaultParserOptions() parserOptions {
+	return parserOptions{
+		stripLevel: -1, // use the default for each header type
+	}
+}
+
+// NewParser creates a Parser with the given options.
+func NewParser(options ...ParserOption) *Parser {
+	opts := defaultParserOptions()
+	for _, optFn := range options {
+		optFn(&opts)
+	}
+	return &Parser{opts: opts}
+}
+
+// Parse parses a patch with changes to one or more files. See the Parse
+// function for details on the return values. If the Parser uses the
+// ContinueOnError mode, Parse returns all files that were parsed and an error
+// joining the errors for the skipped files, as created by errors.Join. If
+// there is only one error, Parse returns it unchanged.
+func (pr *Parser) Parse(r io.Reader) ([]*File, string, error) {
+	fr := pr.NewFileReader(r)
+
+	preamble, err := fr.Preamble()
+	if err != nil {
+		return nil, preamble, joinErrors(fr.Errors(), err)
 	}
 
-	var preamble string
 	var files []*File
 	for {
-		file, pre, err := p.ParseNextFileHeader()
+		file, err := fr.Next()
+		if err == io.EOF {
+			break
+		}
 		if err != nil {
-			return files, preamble, err
+			return files, preamble, joinErrors(fr.Errors(), err)
 		}
-		if len(files) == 0 {
-			preamble = pre
+		files = append(files, file)
+	}
+	return files, preamble, joinErrors(fr.Errors(), nil)
+}
+
+// joinErrors joins the errors for skipped files and err, if it is not nil.
+// If there is only one error, it returns that error unchanged.
+func joinErrors(errs []error, err error) error {
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return errors.Join(errs...)
+}
+
+// NewFileReader creates a FileReader that parses the patch in r using the
+// options of the Parser.
+func (pr *Parser) NewFileReader(r io.Reader) *FileReader {
+	return &FileReader{p: newParser(r, pr.opts)}
+}
+
+// FileReader parses the files in a patch one at a time. Unlike Parse, it does
+// not hold all of the files in memory, so it can process very large patches.
+type FileReader struct {
+	p *parser
+
+	init      bool
+	preamble  string
+	postamble string
+	file      *File
+	files     []*File
+	errs      []error
+	err       error
+}
+
+// NewFileReader creates a FileReader that parses the patch in r. It is
+// equivalent to calling NewFileReader on a Parser created with options.
+func NewFileReader(r io.Reader, options ...ParserOption) *FileReader {
+	return NewParser(options...).NewFileReader(r)
+}
+
+// Preamble returns any content before the first file in the patch. It reads
+// input until it finds the first file header, but does not parse the file.
+func (fr *FileReader) Preamble() (string, error) {
+	fr.start()
+	if fr.err != nil && fr.err != io.EOF {
+		return fr.preamble, fr.err
+	}
+	return fr.preamble, nil
+}
+
+// Next parses and returns the next file in the patch. It returns io.EOF when
+// there are no more files. After Next returns an error, all future calls
+// return the same error.
+//
+// Files from "Only in" lines in recursive diffs are returned once the parser
+// knows which side of the diff contains them, so Next may read ahead in the
+// input. If the side is still unknown after a limited number of files, the
+// file is returned as if the patch had no "diff" command lines. Files are
+// always returned in the order they appear in the patch.
+func (fr *FileReader) Next() (*File, error) {
+	fr.start()
+
+	for fr.err == nil && (len(fr.files) == 0 || fr.readAheadOnlyIn()) {
+		fr.readFile()
+	}
+
+	if len(fr.files) == 0 {
+		return nil, fr.err
+	}
+
+	file := fr.files[0]
+	fr.files = fr.files[1:]
+	fr.p.finishOnlyIn(file)
+	return file, nil
+}
+
+// Postamble returns any content after the last file in the patch, like the
+// signature of an email. It is empty until Next returns io.EOF and is always
+// empty if the patch contains no files.
+func (fr *FileReader) Postamble() string {
+	return fr.postamble
+}
+
+// Errors returns the errors for files that were skipped because the parser
+// uses the ContinueOnError mode. It does not include the error returned by
+// Next, if any.
+func (fr *FileReader) Errors() []error {
+	return fr.errs
+}
+
+// readAheadOnlyIn returns true if the next file is from an "Only in" line
+// and the reader should read more files to find the side that contains it.
+func (fr *FileReader) readAheadOnlyIn() bool {
+	return fr.p.isPendingOnlyIn(fr.files[0]) && len(fr.files) < maxOnlyInReadAhead
+}
+
+// readFile parses the next file in the patch and adds it to the queue of files
+// to return.
+func (fr *FileReader) readFile() {
+	for {
+		file, err := fr.parseFile()
+		if err != nil {
+			if !fr.skipFile(err) {
+				fr.err = err
+				return
+			}
+			continue
 		}
 		if file == nil {
-			break
+			fr.err = io.EOF
+			return
 		}
 
-		for _, fn := range []func(*File) (int, error){
-			p.ParseTextFragments,
-			p.ParseBinaryFragments,
-		} {
-			n, err := fn(file)
-			if err != nil {
-				return files, preamble, err
-			}
-			if n > 0 {
-				break
-			}
+		fr.files = append(fr.files, file)
+		for _, f := range fr.files {
+			fr.p.resolveOnlyIn(f)
 		}
+		return
+	}
+}
 
-		files = append(files, file)
+func (fr *FileReader) parseFile() (*File, error) {
+	file := fr.file
+	fr.file = nil
+
+	if file == nil {
+		var pre string
+		var err error
+		if file, pre, err = fr.p.ParseNextFileHeader(); err != nil {
+			return nil, err
+		}
+		if file == nil {
+			fr.postamble = pre
+			return nil, nil
+		}
 	}
 
-	return files, preamble, nil
+	// files from "Only in" lines have no fragments
+	if !fr.p.isPendingOnlyIn(file) {
+		fr.p.transformNames(file)
+		if err := fr.p.ParseFragments(file); err != nil {
+			return nil, err
+		}
+	}
+	return file, nil
 }
 
-// TODO(bkeyes): consider exporting the parser type with configuration
-// this would enable OID validation, p-value guessing, and prefix stripping
-// by allowing users to set or override defaults
+// skipFile records err and advances the parser to the next file header if
+// the parser uses the ContinueOnError mode. It returns false if the error
+// must stop parsing.
+func (fr *FileReader) skipFile(err error) bool {
+	if fr.p.opts.errorMode != ContinueOnError || fr.p.readErr != nil || err == ErrRangeDiff {
+		return false
+	}
+	fr.errs = append(fr.errs, err)
+
+	if err := fr.p.Resync(); err != nil && err != io.EOF {
+		fr.errs = fr.errs[:len(fr.errs)-1]
+		return false
+	}
+	return true
+}
+
+// start initializes the parser and finds the first file header.
+func (fr *FileReader) start() {
+	if fr.init {
+		return
+	}
+	fr.init = true
+
+	if err := fr.p.Next(); err != nil {
+		fr.err = err
+		return
+	}
+
+	file, preamble, err := fr.p.ParseNextFileHeader()
+	switch {
+	case err != nil:
+		if !fr.skipFile(err) {
+			fr.err = err
+		}
+	case file == nil:
+		fr.err = io.EOF
+	}
+	fr.file = file
+	fr.preamble = preamble
+}
 
 // parser invariants:
 // - methods that parse objects:
@@ -71,23 +501,84 @@ func Parse(r io.Reader) ([]*File, string, error) {
 //     - if returning an object, advance to the first line after the object
 // - any exported parsing methods must initialize the parser by calling Next()
 
+const utf8BOM = "\ufeff"
+
 type stringReader interface {
 	ReadString(delim byte) (string, error)
 }
 
 type parser struct {
-	r stringReader
+	r    stringReader
+	opts parserOptions
 
 	eof    bool
 	lineno int64
+	offset int64
 	lines  [3]string
+	raw    [3]string
+
+	started bool
+	readErr error
+
+	// the start of the fragments of the current file, for size limits
+	inFragments   bool
+	fragmentStart int64
+
+	// the raw lines of the current file header, if the parser keeps them
+	recordRaw bool
+	rawHeader strings.Builder
+
+	// state for files from "Only in" lines in recursive diffs
+	recursive bool
+	oldRoot   string
+	newRoot   string
+	onlyIn    map[*File]string
 }
 
-func newParser(r io.Reader) *parser {
+func newParser(r io.Reader, opts parserOptions) *parser {
+	if opts.maxLineLength > 0 {
+		br, ok := r.(*bufio.Reader)
+		if !ok {
+			br = newBufferedReader(r, opts)
+		}
+		return &parser{r: &limitedLineReader{r: br, max: opts.maxLineLength}, opts: opts}
+	}
 	if r, ok := r.(stringReader); ok {
-		return &parser{r: r}
+		return &parser{r: r, opts: opts}
+	}
+	return &parser{r: newBufferedReader(r, opts), opts: opts}
+}
+
+// newBufferedReader wraps r in a bufio.Reader with the configured buffer size
+// or the default size if none is set.
+func newBufferedReader(r io.Reader, opts parserOptions) *bufio.Reader {
+	if opts.readBufferSize > 0 {
+		return bufio.NewReaderSize(r, opts.readBufferSize)
+	}
+	return bufio.NewReader(r)
+}
+
+// ParseFragments parses the text or binary fragments of a file and adds them
+// to the file.
+func (p *parser) ParseFragments(f *File) error {
+	p.inFragments, p.fragmentStart = true, p.offset
+	defer func() { p.inFragments = false }()
+
+	for _, fn := range []func(*File) (int, error){
+		p.ParseTextFragments,
+		p.ParseContextFragments,
+		p.ParseBinaryFragments,
+	} {
+		n, err := fn(f)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			break
+		}
 	}
-	return &parser{r: bufio.NewReader(r)}
+	setSubmoduleCommits(f)
+	return nil
 }
 
 // Next advances the parser by one line. It returns any error encountered while
@@ -106,12 +597,22 @@ func (p *parser) Next() error {
 		}
 	}
 
+	if err := p.checkFileSizeLimit(); err != nil {
+		return err
+	}
+
+	offset := p.offset + int64(len(p.raw[0]))
+	if p.recordRaw {
+		p.rawHeader.WriteString(p.raw[0])
+	}
+
 	err := p.shiftLines()
 	if err != nil && err != io.EOF {
 		return err
 	}
 
 	p.lineno++
+	p.offset = offset
 	if p.lines[0] == "" {
 		p.eof = true
 		return io.EOF
@@ -122,20 +623,96 @@ func (p *parser) Next() error {
 func (p *parser) shiftLines() (err error) {
 	for i := 0; i < len(p.lines)-1; i++ {
 		p.lines[i] = p.lines[i+1]
+		p.raw[i] = p.raw[i+1]
 	}
-	p.lines[len(p.lines)-1], err = p.r.ReadString('\n')
+
+	last := len(p.lines) - 1
+	p.raw[last], err = p.r.ReadString('\n')
+	if err != nil && err != io.EOF {
+		p.readErr = err
+	}
+	if !p.started {
+		// ignore a byte order mark at the start of the input, but count it in
+		// the offsets of the lines that follow it
+		p.started = true
+		if strings.HasPrefix(p.raw[last], utf8BOM) {
+			p.raw[last] = p.raw[last][len(utf8BOM):]
+			p.offset = int64(len(utf8BOM))
+		}
+	}
+	p.lines[last] = normalizeEOL(p.raw[last])
 	return
 }
 
+// Resync advances the parser to the next line that may start a file header,
+// skipping the rest of a file after an error. It always advances at least one
+// line.
+func (p *parser) Resync() error {
+	for {
+		if err := p.Next(); err != nil {
+			return err
+		}
+
+		line, next := p.Line(0), p.Line(1)
+		switch {
+		case strings.HasPrefix(line, "diff "):
+			return nil
+		case strings.HasPrefix(line, "--- ") && strings.HasPrefix(next, "+++ "):
+			return nil
+		case strings.HasPrefix(line, "*** ") && strings.HasPrefix(next, "--- "):
+			return nil
+		}
+	}
+}
+
 // Line returns a line from the parser without advancing it. A delta of 0
 // returns the current line, while higher deltas return read-ahead lines. It
 // returns an empty string if the delta is higher than the available lines,
 // either because of the buffer size or because the parser reached the end of
 // the input. Valid lines always contain at least a newline character.
+//
+// Lines returned by Line always end with a LF, even if the input uses CRLF.
 func (p *parser) Line(delta uint) string {
 	return p.lines[delta]
 }
 
+// ContentLine is like Line, but returns the line with its original line
+// ending unless the parser normalizes line endings. Use ContentLine when
+// reading lines that are part of a file rather than part of the patch
+// structure.
+func (p *parser) ContentLine(delta uint) string {
+	if p.opts.normalizeCRLF {
+		return p.lines[delta]
+	}
+	return p.raw[delta]
+}
+
+// normalizeEOL converts a CRLF line ending to a LF line ending.
+func normalizeEOL(line string) string {
+	if strings.HasSuffix(line, "\r\n") {
+		return line[:len(line)-2] + "\n"
+	}
+	return line
+}
+
+// gitStripLevel returns the number of path components to remove from names in
+// Git file headers.
+func (p *parser) gitStripLevel() int {
+	if p.opts.stripLevel < 0 {
+		return 1
+	}
+	return p.opts.stripLevel
+}
+
+// traditionalStripLevel returns the number of path components to remove from
+// names in traditional file headers.
+func (p *parser) traditionalStripLevel() int {
+	if p.opts.stripLevel < 0 {
+		return 0
+	}
+	return p.opts.stripLevel
+}
+
 // Errorf generates an error and appends the current line information.
 func (p *parser) Errorf(delta int64, msg string, args ...interface{}) error {
 	return fmt.Errorf("gitdiff: line %d: %s", p.lineno+delta, fmt.Sprintf(msg, args...))
@@ -4,9 +4,12 @@ import (
 	"bytes"
 	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"io"
 	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -253,6 +256,8 @@ index cc34da1..1acbae5 100644
 				OldMode:      os.FileMode(0100644),
 				OldOIDPrefix: "cc34da1",
 				NewOIDPrefix: "1acbae5",
+				HeaderLine:   7,
+				HeaderOffset: 171,
 			},
 			Preamble: `commit 1acbae563cd6ef5750a82ee64e116c6eb065cb94
 Author:	Morton Haypenny <mhaypenny@example.com>
@@ -269,11 +274,26 @@ Date:	Tue Apr 2 22:30:00 2019 -0700
 @@ -1,3 +1,4 @@
 `,
 			Output: &File{
-				OldName: "file.txt",
-				NewName: "file.txt",
+				OldName:      "file.txt",
+				NewName:      "file.txt",
+				HeaderLine:   2,
+				HeaderOffset: 1,
 			},
 			Preamble: "\n",
 		},
+		"traditionalBinaryHeader": {
+			Input: `diff -r old/image.png new/image.png
+Binary files old/image.png and new/image.png differ
+`,
+			Output: &File{
+				OldName:      "new/image.png",
+				NewName:      "new/image.png",
+				IsBinary:     true,
+				HeaderLine:   2,
+				HeaderOffset: 36,
+			},
+			Preamble: "diff -r old/image.png new/image.png\n",
+		},
 		"noHeaders": {
 			Input: `
 this is a line
@@ -327,6 +347,10 @@ a wild fragment appears?
 			if test.Preamble != pre {
 				t.Errorf("incorrect preamble\nexpected: %q\n  actual: %q", test.Preamble, pre)
 			}
+			if test.Output != nil {
+				// the file records the same preamble that is returned
+				test.Output.Preamble = test.Preamble
+			}
 			if !reflect.DeepEqual(test.Output, f) {
 				t.Errorf("incorrect file\nexpected: %+v\n  actual: %+v", test.Output, f)
 			}
@@ -343,17 +367,17 @@ func TestParse(t *testing.T) {
 			NewLines:    8,
 			Comment:     "fragment 1",
 			Lines: []Line{
-				{OpContext, "context line\n"},
-				{OpDelete, "old line 1\n"},
-				{OpDelete, "old line 2\n"},
-				{OpContext, "context line\n"},
-				{OpAdd, "new line 1\n"},
-				{OpAdd, "new line 2\n"},
-				{OpAdd, "new line 3\n"},
-				{OpContext, "context line\n"},
-				{OpDelete, "old line 3\n"},
-				{OpAdd, "new line 4\n"},
-				{OpAdd, "new line 5\n"},
+				{Op: OpContext, Line: "context line\n", OldLineNo: 3, NewLineNo: 3},
+				{Op: OpDelete, Line: "old line 1\n", OldLineNo: 4},
+				{Op: OpDelete, Line: "old line 2\n", OldLineNo: 5},
+				{Op: OpContext, Line: "context line\n", OldLineNo: 6, NewLineNo: 4},
+				{Op: OpAdd, Line: "new line 1\n", NewLineNo: 5},
+				{Op: OpAdd, Line: "new line 2\n", NewLineNo: 6},
+				{Op: OpAdd, Line: "new line 3\n", NewLineNo: 7},
+				{Op: OpContext, Line: "context line\n", OldLineNo: 7, NewLineNo: 8},
+				{Op: OpDelete, Line: "old line 3\n", OldLineNo: 8},
+				{Op: OpAdd, Line: "new line 4\n", NewLineNo: 9},
+				{Op: OpAdd, Line: "new line 5\n", NewLineNo: 10},
 			},
 			LinesAdded:     5,
 			LinesDeleted:   3,
@@ -366,9 +390,9 @@ func TestParse(t *testing.T) {
 			NewLines:    2,
 			Comment:     "fragment 2",
 			Lines: []Line{
-				{OpContext, "context line\n"},
-				{OpDelete, "old line 4\n"},
-				{OpAdd, "new line 6\n"},
+				{Op: OpContext, Line: "context line\n", OldLineNo: 31, NewLineNo: 33},
+				{Op: OpDelete, Line: "old line 4\n", OldLineNo: 32},
+				{Op: OpAdd, Line: "new line 6\n", NewLineNo: 34},
 			},
 			LinesAdded:     1,
 			LinesDeleted:   1,
@@ -409,6 +433,9 @@ Date:   Tue Apr 2 22:55:40 2019 -0700
 					OldOIDPrefix:  "ebe9fa54",
 					NewOIDPrefix:  "fe103e1d",
 					TextFragments: textFragments,
+					HeaderLine:    9,
+					HeaderOffset:  203,
+					Preamble:      textPreamble,
 				},
 			},
 			Preamble: textPreamble,
@@ -423,6 +450,9 @@ Date:   Tue Apr 2 22:55:40 2019 -0700
 					OldOIDPrefix:  "ebe9fa54",
 					NewOIDPrefix:  "fe103e1d",
 					TextFragments: textFragments,
+					HeaderLine:    9,
+					HeaderOffset:  203,
+					Preamble:      textPreamble,
 				},
 				{
 					OldName:       "dir/file2.txt",
@@ -431,6 +461,8 @@ Date:   Tue Apr 2 22:55:40 2019 -0700
 					OldOIDPrefix:  "417ebc70",
 					NewOIDPrefix:  "67514b7f",
 					TextFragments: textFragments,
+					HeaderLine:    29,
+					HeaderOffset:  550,
 				},
 			},
 			Preamble: textPreamble,
@@ -461,6 +493,9 @@ Date:   Tue Apr 2 22:55:40 2019 -0700
 						Size:   0,
 						Data:   []byte{},
 					},
+					HeaderLine:   7,
+					HeaderOffset: 191,
+					Preamble:     binaryPreamble,
 				},
 			},
 			Preamble: binaryPreamble,
@@ -502,10 +537,1021 @@ Date:   Tue Apr 2 22:55:40 2019 -0700
 	}
 }
 
+func TestParseFilePreamble(t *testing.T) {
+	input := `commit 1acbae563cd6ef5750a82ee64e116c6eb065cb94
+Author: Morton Haypenny <mhaypenny@example.com>
+
+    First commit
+
+diff --git a/file1.txt b/file1.txt
+--- a/file1.txt
++++ b/file1.txt
+@@ -1 +1 @@
+-old
++new
+diff --git a/file2.txt b/file2.txt
+--- a/file2.txt
++++ b/file2.txt
+@@ -1 +1 @@
+-old
++new
+
+commit 0d9b5f1c1a2ba9ba2d8e1b5d2e33c1e5d3a0f9b4
+Author: Morton Haypenny <mhaypenny@example.com>
+
+    Second commit
+
+diff --git a/file3.txt b/file3.txt
+--- a/file3.txt
++++ b/file3.txt
+@@ -1 +1 @@
+-old
++new
+`
+
+	files, pre, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("unexpected error parsing patch: %v", err)
+	}
+	if len(files) != 3 {
+		t.Fatalf("incorrect number of files: expected 3, actual %d", len(files))
+	}
+
+	first := "commit 1acbae563cd6ef5750a82ee64e116c6eb065cb94\nAuthor: Morton Haypenny <mhaypenny@example.com>\n\n    First commit\n\n"
+	second := "\ncommit 0d9b5f1c1a2ba9ba2d8e1b5d2e33c1e5d3a0f9b4\nAuthor: Morton Haypenny <mhaypenny@example.com>\n\n    Second commit\n\n"
+
+	if pre != first {
+		t.Errorf("incorrect preamble\nexpected: %q\n  actual: %q", first, pre)
+	}
+	for i, expected := range []string{first, "", second} {
+		if files[i].Preamble != expected {
+			t.Errorf("incorrect preamble for file %d\nexpected: %q\n  actual: %q", i, expected, files[i].Preamble)
+		}
+	}
+}
+
+func TestFileReader(t *testing.T) {
+	t.Run("twoFiles", func(t *testing.T) {
+		f, err := os.Open("testdata/two_files.patch")
+		if err != nil {
+			t.Fatalf("unexpected error opening input file: %v", err)
+		}
+		defer f.Close()
+
+		fr := NewFileReader(f)
+
+		pre, err := fr.Preamble()
+		if err != nil {
+			t.Fatalf("unexpected error reading preamble: %v", err)
+		}
+		if !strings.HasPrefix(pre, "commit 5d9790fec7d95aa223f3d20936340bf55ff3dcbe\n") {
+			t.Errorf("incorrect preamble: %q", pre)
+		}
+
+		for _, name := range []string{"dir/file1.txt", "dir/file2.txt"} {
+			file, err := fr.Next()
+			if err != nil {
+				t.Fatalf("unexpected error reading file: %v", err)
+			}
+			if file.NewName != name {
+				t.Errorf("incorrect file name: expected %q, actual %q", name, file.NewName)
+			}
+			if len(file.TextFragments) != 2 {
+				t.Errorf("incorrect number of fragments: expected 2, actual %d", len(file.TextFragments))
+			}
+		}
+
+		for i := 0; i < 2; i++ {
+			if _, err := fr.Next(); err != io.EOF {
+				t.Fatalf("expected io.EOF after last file, but got %v", err)
+			}
+		}
+	})
+
+	t.Run("noPreambleCall", func(t *testing.T) {
+		fr := NewFileReader(strings.NewReader("diff --git a/file.txt b/file.txt\nold mode 100644\nnew mode 100755\n"))
+
+		file, err := fr.Next()
+		if err != nil {
+			t.Fatalf("unexpected error reading file: %v", err)
+		}
+		if file.NewMode != os.FileMode(0100755) {
+			t.Errorf("incorrect new mode: %o", file.NewMode)
+		}
+		if pre, err := fr.Preamble(); err != nil || pre != "" {
+			t.Errorf("incorrect preamble: %q (err: %v)", pre, err)
+		}
+	})
+
+	t.Run("postamble", func(t *testing.T) {
+		fr := NewFileReader(strings.NewReader("diff --git a/file.txt b/file.txt\n" +
+			"--- a/file.txt\n" +
+			"+++ b/file.txt\n" +
+			"@@ -1 +1 @@\n" +
+			"-old line\n" +
+			"+new line\n" +
+			"-- \n" +
+			"2.40.0\n" +
+			"\n"))
+
+		if _, err := fr.Next(); err != nil {
+			t.Fatalf("unexpected error reading file: %v", err)
+		}
+		if post := fr.Postamble(); post != "" {
+			t.Errorf("expected empty postamble before end of input, but got %q", post)
+		}
+		if _, err := fr.Next(); err != io.EOF {
+			t.Fatalf("expected io.EOF, but got %v", err)
+		}
+		if post := fr.Postamble(); post != "-- \n2.40.0\n\n" {
+			t.Errorf("incorrect postamble: %q", post)
+		}
+	})
+
+	t.Run("noFiles", func(t *testing.T) {
+		fr := NewFileReader(strings.NewReader("just some text\n"))
+
+		pre, err := fr.Preamble()
+		if err != nil {
+			t.Fatalf("unexpected error reading preamble: %v", err)
+		}
+		if pre != "just some text\n" {
+			t.Errorf("incorrect preamble: %q", pre)
+		}
+		if _, err := fr.Next(); err != io.EOF {
+			t.Fatalf("expected io.EOF, but got %v", err)
+		}
+		if post := fr.Postamble(); post != "" {
+			t.Errorf("incorrect postamble: %q", post)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		fr := NewFileReader(strings.NewReader(`diff --git a/file.txt b/file.txt
+--- a/file.txt
++++ b/file.txt
+@@ -1 +1 @@
+-old line
+diff --git a/other.txt b/other.txt
+old mode 100644
+new mode 100755
+`))
+
+		_, err := fr.Next()
+		assertError(t, "invalid line operation", err, "reading file")
+
+		_, nextErr := fr.Next()
+		if nextErr != err {
+			t.Fatalf("expected same error from later call, but got %v", nextErr)
+		}
+	})
+}
+
+func TestParserOptions(t *testing.T) {
+	const input = `diff --git a/dir/file.txt b/dir/file.txt
+index 1c23fcc..40a1b33 100644
+--- a/dir/file.txt
++++ b/dir/file.txt
+@@ -1 +1 @@
+-old line
++new line
+`
+
+	tests := map[string]struct {
+		Input   string
+		Options []ParserOption
+		Err     interface{}
+	}{
+		"default": {
+			Input: input,
+		},
+		"maxOIDLength": {
+			Input:   input,
+			Options: []ParserOption{WithMaxOIDLength(40)},
+		},
+		"maxOIDLengthTooLong": {
+			Input:   input,
+			Options: []ParserOption{WithMaxOIDLength(6)},
+			Err:     "longer than 6 characters",
+		},
+		"maxOIDLengthNotHex": {
+			Input:   strings.Replace(input, "1c23fcc", "1c23fcz", 1),
+			Options: []ParserOption{WithMaxOIDLength(40)},
+			Err:     "non-hex character",
+		},
+		"readBufferSize": {
+			Input:   input,
+			Options: []ParserOption{WithReadBufferSize(16)},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := NewParser(test.Options...)
+
+			// wrap the input to hide the ReadString method from the parser
+			files, _, err := p.Parse(struct{ io.Reader }{strings.NewReader(test.Input)})
+			if test.Err != nil {
+				assertError(t, test.Err, err, "parsing patch")
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error parsing patch: %v", err)
+			}
+			if len(files) != 1 {
+				t.Fatalf("incorrect number of parsed files: expected 1, actual %d", len(files))
+			}
+			if len(files[0].TextFragments) != 1 {
+				t.Fatalf("incorrect number of fragments: expected 1, actual %d", len(files[0].TextFragments))
+			}
+		})
+	}
+}
+
+func TestParseHeadersOnly(t *testing.T) {
+	for _, name := range []string{"one_file.patch", "two_files.patch", "new_binary_file.patch", "no_index.patch"} {
+		t.Run(name, func(t *testing.T) {
+			b, err := os.ReadFile(filepath.Join("testdata", name))
+			if err != nil {
+				t.Fatalf("unexpected error reading input file: %v", err)
+			}
+
+			expected, expectedPreamble, err := Parse(bytes.NewReader(b))
+			if err != nil {
+				t.Fatalf("unexpected error parsing patch: %v", err)
+			}
+			for _, f := range expected {
+				for _, frag := range f.TextFragments {
+					frag.Lines = nil
+				}
+			}
+
+			files, preamble, err := NewParser(WithHeadersOnly()).Parse(bytes.NewReader(b))
+			if err != nil {
+				t.Fatalf("unexpected error parsing patch with headers only: %v", err)
+			}
+			if preamble != expectedPreamble {
+				t.Errorf("incorrect preamble\nexpected: %q\n  actual: %q", expectedPreamble, preamble)
+			}
+			if !reflect.DeepEqual(expected, files) {
+				exp, _ := json.MarshalIndent(expected, "", "  ")
+				act, _ := json.MarshalIndent(files, "", "  ")
+				t.Errorf("incorrect files\nexpected: %s\n  actual: %s", exp, act)
+			}
+		})
+	}
+
+	t.Run("invalidFragment", func(t *testing.T) {
+		const input = `diff --git a/file.txt b/file.txt
+--- a/file.txt
++++ b/file.txt
+@@ -1,2 +1,2 @@
+ line 1
+-line 2
+`
+		_, _, err := NewParser(WithHeadersOnly()).Parse(strings.NewReader(input))
+		assertError(t, "miscounts lines", err, "parsing patch with headers only")
+	})
+
+	t.Run("context", func(t *testing.T) {
+		const input = `*** file.txt.orig
+--- file.txt
+***************
+*** 1,2 ****
+  line 1
+! line 2
+--- 1,2 ----
+  line 1
+! line 2 changed
+`
+		files, _, err := NewParser(WithHeadersOnly()).Parse(strings.NewReader(input))
+		if err != nil {
+			t.Fatalf("unexpected error parsing patch with headers only: %v", err)
+		}
+		frag := files[0].TextFragments[0]
+		if len(frag.Lines) != 0 {
+			t.Errorf("expected no lines, but got %d", len(frag.Lines))
+		}
+		if frag.LinesAdded != 1 || frag.LinesDeleted != 1 {
+			t.Errorf("incorrect counts: expected +1 -1, actual +%d -%d", frag.LinesAdded, frag.LinesDeleted)
+		}
+	})
+}
+
+func TestParseNoIndex(t *testing.T) {
+	type fileSummary struct {
+		OldName, NewName  string
+		OldMode, NewMode  os.FileMode
+		IsNew, IsDelete   bool
+		TextFragmentCount int
+	}
+
+	// generated by running `git diff --no-index` on two pairs of directories
+	f, err := os.Open("testdata/no_index.patch")
+	if err != nil {
+		t.Fatalf("unexpected error opening input file: %v", err)
+	}
+	defer f.Close()
+
+	files, _, err := Parse(f)
+	if err != nil {
+		t.Fatalf("unexpected error parsing patch: %v", err)
+	}
+
+	expected := []fileSummary{
+		{OldName: "a/del.txt", OldMode: 0100644, IsDelete: true, TextFragmentCount: 1},
+		{OldName: "a/mod.txt", NewName: "b/mod.txt", OldMode: 0100644, NewMode: 0100755, TextFragmentCount: 1},
+		{NewName: "b/new.txt", NewMode: 0100644, IsNew: true, TextFragmentCount: 1},
+		{OldName: "c/e.txt", OldMode: 0100644, IsDelete: true, TextFragmentCount: 1},
+		{NewName: "d/empty.txt", NewMode: 0100644, IsNew: true},
+		{OldName: "c/m.sh", NewName: "d/m.sh", OldMode: 0100644, NewMode: 0100755},
+	}
+
+	var actual []fileSummary
+	for _, f := range files {
+		actual = append(actual, fileSummary{
+			OldName:           f.OldName,
+			NewName:           f.NewName,
+			OldMode:           f.OldMode,
+			NewMode:           f.NewMode,
+			IsNew:             f.IsNew,
+			IsDelete:          f.IsDelete,
+			TextFragmentCount: len(f.TextFragments),
+		})
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("incorrect files\nexpected: %+v\n  actual: %+v", expected, actual)
+	}
+}
+
+func TestParseNameTransform(t *testing.T) {
+	type names struct {
+		OldName, NewName string
+	}
+
+	removeVendor := func(oldName, newName string) (string, string) {
+		return strings.Replace(oldName, "vendor/", "", 1), strings.Replace(newName, "vendor/", "", 1)
+	}
+
+	tests := map[string]struct {
+		Input  string
+		Output []names
+	}{
+		"gitHeader": {
+			Input: `diff --git a/vendor/lib/a.txt b/vendor/lib/b.txt
+similarity index 90%
+rename from vendor/lib/a.txt
+rename to vendor/lib/b.txt
+--- a/vendor/lib/a.txt
++++ b/vendor/lib/b.txt
+@@ -1 +1 @@
+-old line
++new line
+diff --git a/vendor/lib/new.txt b/vendor/lib/new.txt
+new file mode 100644
+--- /dev/null
++++ b/vendor/lib/new.txt
+@@ -0,0 +1 @@
++new line
+`,
+			Output: []names{
+				{"lib/a.txt", "lib/b.txt"},
+				{"", "lib/new.txt"},
+			},
+		},
+		"traditionalHeader": {
+			Input: `--- vendor/lib/file.txt
++++ vendor/lib/file.txt
+@@ -1 +1 @@
+-old line
++new line
+`,
+			Output: []names{
+				{"lib/file.txt", "lib/file.txt"},
+			},
+		},
+		"binaryMarkerNames": {
+			Input: `diff --git a/vendor/my file.bin b/vendor/my file.bin
+index 1c23fcc..40a1b33 100644
+Binary files a/vendor/my file.bin and b/vendor/my file.bin differ
+`,
+			Output: []names{
+				{"my file.bin", "my file.bin"},
+			},
+		},
+		"onlyIn": {
+			Input: `diff -ru old/vendor/file.txt new/vendor/file.txt
+--- old/vendor/file.txt
++++ new/vendor/file.txt
+@@ -1 +1 @@
+-old line
++new line
+Only in old/vendor: removed.txt
+Only in new/vendor: added.txt
+`,
+			Output: []names{
+				{"new/file.txt", "new/file.txt"},
+				{"old/removed.txt", ""},
+				{"", "new/added.txt"},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			files, _, err := NewParser(WithNameTransform(removeVendor)).Parse(strings.NewReader(test.Input))
+			if err != nil {
+				t.Fatalf("unexpected error parsing patch: %v", err)
+			}
+
+			var actual []names
+			for _, f := range files {
+				actual = append(actual, names{f.OldName, f.NewName})
+			}
+			if !reflect.DeepEqual(test.Output, actual) {
+				t.Errorf("incorrect names\nexpected: %+v\n  actual: %+v", test.Output, actual)
+			}
+		})
+	}
+}
+
+func TestParseCRLF(t *testing.T) {
+	for _, name := range []string{"one_file.patch", "two_files.patch", "new_binary_file.patch"} {
+		t.Run(name, func(t *testing.T) {
+			b, err := os.ReadFile(filepath.Join("testdata", name))
+			if err != nil {
+				t.Fatalf("unexpected error reading input file: %v", err)
+			}
+			input := string(b)
+			crlfInput := strings.ReplaceAll(input, "\n", "\r\n")
+
+			expected, expectedPre, err := Parse(strings.NewReader(input))
+			if err != nil {
+				t.Fatalf("unexpected error parsing patch: %v", err)
+			}
+
+			files, pre, err := Parse(strings.NewReader(crlfInput))
+			if err != nil {
+				t.Fatalf("unexpected error parsing CRLF patch: %v", err)
+			}
+			if len(files) != len(expected) {
+				t.Fatalf("incorrect number of parsed files: expected %d, actual %d", len(expected), len(files))
+			}
+			if pre != strings.ReplaceAll(expectedPre, "\n", "\r\n") {
+				t.Errorf("preamble does not preserve line endings: %q", pre)
+			}
+			for i, f := range files {
+				for j, frag := range f.TextFragments {
+					for k, line := range frag.Lines {
+						exp := expected[i].TextFragments[j].Lines[k].Line
+						if line.Line != strings.ReplaceAll(exp, "\n", "\r\n") {
+							t.Errorf("line does not preserve line ending: expected %q, actual %q", exp, line.Line)
+						}
+					}
+				}
+			}
+
+			files, pre, err = NewParser(WithNormalizeCRLF()).Parse(strings.NewReader(crlfInput))
+			if err != nil {
+				t.Fatalf("unexpected error parsing CRLF patch with normalization: %v", err)
+			}
+			if pre != expectedPre {
+				t.Errorf("incorrect preamble\nexpected: %q\n  actual: %q", expectedPre, pre)
+			}
+			for i, f := range files {
+				if f.HeaderLine != expected[i].HeaderLine {
+					t.Errorf("incorrect header line: expected %d, actual %d", expected[i].HeaderLine, f.HeaderLine)
+				}
+				f.HeaderOffset = expected[i].HeaderOffset
+				if !reflect.DeepEqual(expected[i], f) {
+					t.Errorf("incorrect file at position %d\nexpected: %+v\n  actual: %+v", i, expected[i], f)
+				}
+			}
+		})
+	}
+}
+
 func newTestParser(input string, init bool) *parser {
-	p := newParser(bytes.NewBufferString(input))
+	p := newParser(bytes.NewBufferString(input), defaultParserOptions())
 	if init {
 		_ = p.Next()
 	}
 	return p
 }
+
+func TestParseOnlyIn(t *testing.T) {
+	type fileSummary struct {
+		OldName, NewName  string
+		IsNew, IsDelete   bool
+		TextFragmentCount int
+	}
+
+	tests := map[string]struct {
+		Input    string
+		Files    []fileSummary
+		Preamble string
+	}{
+		"recursiveDiff": {
+			Input: `diff -ru old/sub/common.txt new/sub/common.txt
+--- old/sub/common.txt
++++ new/sub/common.txt
+@@ -1,2 +1,2 @@
+ a
+-b
++c
+Only in old: removed.txt
+Only in new/sub: added.txt
+Only in old/sub: later.txt
+`,
+			Files: []fileSummary{
+				{OldName: "new/sub/common.txt", NewName: "new/sub/common.txt", TextFragmentCount: 1},
+				{OldName: "old/removed.txt", IsDelete: true},
+				{NewName: "new/sub/added.txt", IsNew: true},
+				{OldName: "old/sub/later.txt", IsDelete: true},
+			},
+			Preamble: "diff -ru old/sub/common.txt new/sub/common.txt\n",
+		},
+		"unknownRoots": {
+			Input: `--- common.txt
++++ common.txt
+@@ -1 +1 @@
+-a
++b
+Only in old: removed.txt
+Only in new/sub: added.txt
+`,
+			Files: []fileSummary{
+				{OldName: "common.txt", NewName: "common.txt", TextFragmentCount: 1},
+				{OldName: "old/removed.txt", NewName: "old/removed.txt"},
+				{OldName: "new/sub/added.txt", NewName: "new/sub/added.txt"},
+			},
+		},
+		"noRecursiveDiff": {
+			Input: `Only in old: removed.txt
+Only in new/sub: added.txt
+`,
+			Preamble: `Only in old: removed.txt
+Only in new/sub: added.txt
+`,
+		},
+		"gitPatch": {
+			Input: `From 61f5cd90bed4d204ee3feb3aa41ee91d4734855b Mon Sep 17 00:00:00 2001
+From: Morton Haypenny <mhaypenny@example.com>
+Date: Tue, 2 Apr 2019 13:55:36 -0700
+Subject: [PATCH] Skip the read-ahead
+
+Only in rare cases: the parser reads ahead.
+---
+ file.txt | 2 +-
+ 1 file changed, 1 insertion(+), 1 deletion(-)
+
+diff --git a/file.txt b/file.txt
+index 1c23fcc..40a1b33 100644
+--- a/file.txt
++++ b/file.txt
+@@ -1 +1 @@
+-a
++b
+`,
+			Files: []fileSummary{
+				{OldName: "file.txt", NewName: "file.txt", TextFragmentCount: 1},
+			},
+			Preamble: `From 61f5cd90bed4d204ee3feb3aa41ee91d4734855b Mon Sep 17 00:00:00 2001
+From: Morton Haypenny <mhaypenny@example.com>
+Date: Tue, 2 Apr 2019 13:55:36 -0700
+Subject: [PATCH] Skip the read-ahead
+
+Only in rare cases: the parser reads ahead.
+---
+ file.txt | 2 +-
+ 1 file changed, 1 insertion(+), 1 deletion(-)
+
+`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			files, preamble, err := Parse(strings.NewReader(test.Input))
+			if err != nil {
+				t.Fatalf("unexpected error parsing patch: %v", err)
+			}
+
+			var summaries []fileSummary
+			for _, f := range files {
+				summaries = append(summaries, fileSummary{
+					OldName:           f.OldName,
+					NewName:           f.NewName,
+					IsNew:             f.IsNew,
+					IsDelete:          f.IsDelete,
+					TextFragmentCount: len(f.TextFragments),
+				})
+			}
+			if !reflect.DeepEqual(test.Files, summaries) {
+				t.Errorf("incorrect files\nexpected: %+v\n  actual: %+v", test.Files, summaries)
+			}
+			if preamble != test.Preamble {
+				t.Errorf("incorrect preamble\nexpected: %q\n  actual: %q", test.Preamble, preamble)
+			}
+		})
+	}
+}
+
+func TestFileReaderOnlyInReadAhead(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("--- common.txt\n+++ common.txt\n@@ -1 +1 @@\n-a\n+b\n")
+	b.WriteString("Only in old: removed.txt\n")
+	for i := 0; i < 100*maxOnlyInReadAhead; i++ {
+		b.WriteString("--- file.txt\n+++ file.txt\n@@ -1 +1 @@\n-a\n+b\n")
+	}
+	input := b.String()
+
+	r := &countingReader{r: strings.NewReader(input)}
+	fr := NewParser(WithReadBufferSize(4096)).NewFileReader(r)
+
+	for _, name := range []string{"common.txt", "old/removed.txt"} {
+		f, err := fr.Next()
+		if err != nil {
+			t.Fatalf("unexpected error reading file: %v", err)
+		}
+		if f.OldName != name || f.NewName != name {
+			t.Errorf("incorrect names: expected %q, actual %q and %q", name, f.OldName, f.NewName)
+		}
+		if f.IsNew || f.IsDelete {
+			t.Errorf("incorrect flags: IsNew=%t, IsDelete=%t", f.IsNew, f.IsDelete)
+		}
+	}
+	if r.n >= int64(len(input)) {
+		t.Errorf("reader read all %d bytes of input before returning the file", r.n)
+	}
+}
+
+func TestParseBOM(t *testing.T) {
+	const patch = `From 61f5cd90bed4d204ee3feb3aa41ee91d4734855b Mon Sep 17 00:00:00 2001
+From: Morton Haypenny <mhaypenny@example.com>
+Subject: [PATCH] A sample commit
+
+---
+diff --git a/file.txt b/file.txt
+--- a/file.txt
++++ b/file.txt
+@@ -1 +1 @@
+-old line
++new line
+`
+
+	files, preamble, err := Parse(strings.NewReader(patch))
+	if err != nil {
+		t.Fatalf("unexpected error parsing patch: %v", err)
+	}
+	bomFiles, bomPreamble, err := Parse(strings.NewReader("\ufeff" + patch))
+	if err != nil {
+		t.Fatalf("unexpected error parsing patch with BOM: %v", err)
+	}
+
+	if preamble != bomPreamble {
+		t.Errorf("incorrect preamble\nexpected: %q\n  actual: %q", preamble, bomPreamble)
+	}
+	if len(files) != 1 || len(bomFiles) != 1 {
+		t.Fatalf("incorrect number of files: expected 1, actual %d and %d", len(files), len(bomFiles))
+	}
+	if files[0].String() != bomFiles[0].String() {
+		t.Errorf("incorrect file\nexpected: %q\n  actual: %q", files[0].String(), bomFiles[0].String())
+	}
+	if bomFiles[0].HeaderOffset != files[0].HeaderOffset+3 {
+		t.Errorf("incorrect header offset: expected %d, actual %d", files[0].HeaderOffset+3, bomFiles[0].HeaderOffset)
+	}
+
+	header, err := ParsePatchHeader("\ufeff" + preamble)
+	if err != nil {
+		t.Fatalf("unexpected error parsing header with BOM: %v", err)
+	}
+	if header.Title != "A sample commit" {
+		t.Errorf("incorrect title: expected %q, actual %q", "A sample commit", header.Title)
+	}
+}
+
+func TestParseContinueOnError(t *testing.T) {
+	const patch = `diff --git a/first.txt b/first.txt
+--- a/first.txt
++++ b/first.txt
+@@ -1 +1 @@
+-a
++b
+diff --git a/bad_mode.txt b/bad_mode.txt
+old mode 10o644
+new mode 100755
+diff --git a/bad_fragment.txt b/bad_fragment.txt
+--- a/bad_fragment.txt
++++ b/bad_fragment.txt
+@@ -1,2 +1,2 @@
+ a
+?b
++c
+--- last.txt
++++ last.txt
+@@ -1 +1 @@
+-x
++y
+`
+
+	files, _, err := Parse(strings.NewReader(patch))
+	if err == nil {
+		t.Fatal("expected error parsing patch in default mode, but got nil")
+	}
+	if len(files) != 1 {
+		t.Errorf("incorrect number of files in default mode: expected 1, actual %d", len(files))
+	}
+
+	p := NewParser(WithErrorMode(ContinueOnError))
+	files, _, err = p.Parse(strings.NewReader(patch))
+	if err == nil {
+		t.Fatal("expected error parsing patch, but got nil")
+	}
+
+	var names []string
+	for _, f := range files {
+		names = append(names, f.NewName)
+	}
+	if expected := []string{"first.txt", "last.txt"}; !reflect.DeepEqual(expected, names) {
+		t.Errorf("incorrect files: expected %q, actual %q", expected, names)
+	}
+
+	fr := p.NewFileReader(strings.NewReader(patch))
+	for {
+		if _, err := fr.Next(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("unexpected error reading file: %v", err)
+		}
+	}
+	errs := fr.Errors()
+	if len(errs) != 2 {
+		t.Fatalf("incorrect number of errors: expected 2, actual %d: %v", len(errs), errs)
+	}
+	for i, substr := range []string{"mode", "line operation"} {
+		if !strings.Contains(errs[i].Error(), substr) {
+			t.Errorf("incorrect error %d: expected it to contain %q, actual %v", i, substr, errs[i])
+		}
+	}
+}
+
+func TestParseRawHeaders(t *testing.T) {
+	const gitHeader = "diff --git a/file.sh b/file.sh\r\n" +
+		"old mode 100644\r\n" +
+		"new mode 100755\r\n" +
+		"index 1c23fcc..40a1b33\r\n" +
+		"--- a/file.sh\r\n" +
+		"+++ b/file.sh\r\n"
+	const traditionalHeader = "--- other.txt.orig\n" +
+		"+++ other.txt\n"
+	const binaryHeader = "diff --git a/image.png b/image.png\n" +
+		"index 1c23fcc..40a1b33 100644\n"
+
+	patch := "preamble\n" + gitHeader +
+		"@@ -1 +1 @@\r\n" +
+		"-echo old\r\n" +
+		"+echo new\r\n" +
+		traditionalHeader +
+		"@@ -1 +1 @@\n" +
+		"-a\n" +
+		"+b\n" +
+		binaryHeader +
+		"Binary files a/image.png and b/image.png differ\n"
+
+	files, _, err := NewParser(WithRawHeaders()).Parse(strings.NewReader(patch))
+	if err != nil {
+		t.Fatalf("unexpected error parsing patch: %v", err)
+	}
+
+	expected := []string{gitHeader, traditionalHeader, binaryHeader}
+	if len(files) != len(expected) {
+		t.Fatalf("incorrect number of files: expected %d, actual %d", len(expected), len(files))
+	}
+	for i, f := range files {
+		if f.RawHeader != expected[i] {
+			t.Errorf("incorrect raw header for file %d\nexpected: %q\n  actual: %q", i, expected[i], f.RawHeader)
+		}
+	}
+
+	files, _, err = Parse(strings.NewReader(patch))
+	if err != nil {
+		t.Fatalf("unexpected error parsing patch: %v", err)
+	}
+	for i, f := range files {
+		if f.RawHeader != "" {
+			t.Errorf("expected empty raw header for file %d by default, but got %q", i, f.RawHeader)
+		}
+	}
+}
+
+func TestSplitFiles(t *testing.T) {
+	const preamble = "From 61f5cd90bed4d204ee3feb3aa41ee91d4734855b Mon Sep 17 00:00:00 2001\n" +
+		"Subject: [PATCH] A sample commit\n" +
+		"\n" +
+		"---\n"
+	const first = "diff --git a/file.txt b/file.txt\n" +
+		"--- a/file.txt\n" +
+		"+++ b/file.txt\n" +
+		"@@ -1,2 +1,2 @@\n" +
+		"--- not a header\n" +
+		"+++ not a header\n" +
+		" @@ -1 +1 @@\n"
+	const second = "diff --git a/image.png b/image.png\n" +
+		"index 1c23fcc..40a1b33 100644\n" +
+		"Binary files a/image.png and b/image.png differ\n"
+	const third = "--- other.txt\r\n" +
+		"+++ other.txt\r\n" +
+		"@@ -1 +1 @@\r\n" +
+		"-a\r\n" +
+		"+b\r\n" +
+		"-- \r\n" +
+		"2.30.0\r\n"
+
+	for name, bom := range map[string]string{"noBOM": "", "BOM": "\ufeff"} {
+		t.Run(name, func(t *testing.T) {
+			files, actualPreamble, err := SplitFiles(strings.NewReader(bom + preamble + first + second + third))
+			if err != nil {
+				t.Fatalf("unexpected error splitting patch: %v", err)
+			}
+
+			if actualPreamble != preamble {
+				t.Errorf("incorrect preamble\nexpected: %q\n  actual: %q", preamble, actualPreamble)
+			}
+
+			expected := []string{first, second, third}
+			if len(files) != len(expected) {
+				t.Fatalf("incorrect number of files: expected %d, actual %d", len(expected), len(files))
+			}
+			for i, f := range files {
+				if string(f) != expected[i] {
+					t.Errorf("incorrect file %d\nexpected: %q\n  actual: %q", i, expected[i], f)
+				}
+			}
+		})
+	}
+
+	t.Run("noFiles", func(t *testing.T) {
+		files, actualPreamble, err := SplitFiles(strings.NewReader(preamble))
+		if err != nil {
+			t.Fatalf("unexpected error splitting patch: %v", err)
+		}
+		if len(files) != 0 {
+			t.Errorf("expected no files, but got %d", len(files))
+		}
+		if actualPreamble != preamble {
+			t.Errorf("incorrect preamble\nexpected: %q\n  actual: %q", preamble, actualPreamble)
+		}
+	})
+}
+
+func TestParserLimits(t *testing.T) {
+	const textPatch = `diff --git a/file.txt b/file.txt
+--- a/file.txt
++++ b/file.txt
+@@ -1 +1 @@
+-a
++b
+@@ -10 +10 @@
+-a very long line in the second fragment
++another very long line in the second fragment
+`
+
+	binaryPatch, err := os.ReadFile(filepath.Join("testdata", "string", "binary_new.patch"))
+	if err != nil {
+		t.Fatalf("failed to read patch: %v", err)
+	}
+
+	tests := map[string]struct {
+		Input   string
+		Options []ParserOption
+		Err     *LimitError
+	}{
+		"underLimits": {
+			Input: textPatch,
+			Options: []ParserOption{
+				WithMaxLineLength(64),
+				WithMaxFragmentsPerFile(2),
+				WithMaxFileSize(1024),
+			},
+		},
+		"lineLength": {
+			Input:   textPatch,
+			Options: []ParserOption{WithMaxLineLength(40)},
+			Err:     &LimitError{Limit: "line length", Max: 40, Line: 8},
+		},
+		"lineLengthSmallBuffer": {
+			Input:   textPatch,
+			Options: []ParserOption{WithMaxLineLength(40), WithReadBufferSize(16)},
+			Err:     &LimitError{Limit: "line length", Max: 40, Line: 8},
+		},
+		"fragmentsPerFile": {
+			Input:   textPatch,
+			Options: []ParserOption{WithMaxFragmentsPerFile(1)},
+			Err:     &LimitError{Limit: "fragments per file", Max: 1, Line: 7},
+		},
+		"fileSize": {
+			Input:   textPatch,
+			Options: []ParserOption{WithMaxFileSize(32)},
+			Err:     &LimitError{Limit: "file size", Max: 32, Line: 8},
+		},
+		"binaryFileSize": {
+			Input:   string(binaryPatch),
+			Options: []ParserOption{WithMaxFileSize(32)},
+			Err:     &LimitError{Limit: "file size", Max: 32, Line: 5},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, _, err := NewParser(test.Options...).Parse(strings.NewReader(test.Input))
+			if test.Err == nil {
+				if err != nil {
+					t.Fatalf("unexpected error parsing patch: %v", err)
+				}
+				return
+			}
+
+			var lerr *LimitError
+			if !errors.As(err, &lerr) {
+				t.Fatalf("expected *LimitError, but got %T: %v", err, err)
+			}
+			if *lerr != *test.Err {
+				t.Errorf("incorrect error\nexpected: %+v\n  actual: %+v", *test.Err, *lerr)
+			}
+		})
+	}
+}
+
+func TestParseLineLengthLimitStopsReading(t *testing.T) {
+	const header = `diff --git a/app.min.js b/app.min.js
+new file mode 100644
+index 0000000..1c23fcc
+--- /dev/null
++++ b/app.min.js
+@@ -0,0 +1 @@
++`
+	const (
+		lineSize = 64 << 20
+		maxLine  = 1024
+	)
+
+	r := &countingReader{r: io.MultiReader(
+		strings.NewReader(header),
+		io.LimitReader(repeatReader('a'), lineSize),
+		strings.NewReader("\n"),
+	)}
+
+	_, _, err := NewParser(WithMaxLineLength(maxLine), WithReadBufferSize(4096)).Parse(r)
+
+	var lerr *LimitError
+	if !errors.As(err, &lerr) {
+		t.Fatalf("expected *LimitError, but got %T: %v", err, err)
+	}
+	if lerr.Line != 7 {
+		t.Errorf("incorrect error line: expected 7, actual %d", lerr.Line)
+	}
+	if r.n > int64(len(header)+maxLine+4096) {
+		t.Errorf("parser read %d bytes of input before stopping", r.n)
+	}
+}
+
+func TestParseLineLengthLimitBufferSize(t *testing.T) {
+	b, err := os.ReadFile(filepath.Join("testdata", "two_files.patch"))
+	if err != nil {
+		t.Fatalf("unexpected error reading input file: %v", err)
+	}
+
+	r := &countingReader{r: bytes.NewReader(b)}
+	if _, _, err := NewParser(WithMaxLineLength(1024)).Parse(r); err != nil {
+		t.Fatalf("unexpected error parsing patch: %v", err)
+	}
+
+	// the default buffer holds the whole patch, so the parser only needs a
+	// few reads to reach the end of the input
+	if r.reads > 4 {
+		t.Errorf("parser made %d reads of a %d byte patch", r.reads, len(b))
+	}
+}
+
+type countingReader struct {
+	r     io.Reader
+	n     int64
+	reads int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	r.reads++
+	return n, err
+}
+
+type repeatReader byte
+
+func (r repeatReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = byte(r)
+	}
+	return len(p), nil
+}
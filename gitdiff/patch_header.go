@@ -5,8 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
-	"mime/quotedprintable"
+	"mime"
 	"net/mail"
 	"strconv"
 	"strings"
@@ -18,6 +17,10 @@ const (
 	mailHeaderPrefix        = "From "
 	prettyHeaderPrefix      = "commit "
 	mailMinimumHeaderPrefix = "From:"
+
+	// mailMagicDate is the fixed date that `git format-patch` writes on the
+	// separator line instead of the commit date
+	mailMagicDate = "Mon Sep 17 00:00:00 2001"
 )
 
 // PatchHeader is a parsed version of the preamble content that appears before
@@ -29,7 +32,9 @@ type PatchHeader struct {
 	SHA string
 
 	// The author details of the patch. If these details are not included in
-	// the header, Author is nil and AuthorDate is the zero time.
+	// the header, Author is nil and AuthorDate is the zero time. For mail
+	// headers without a Date field, AuthorDate is the date on the "From "
+	// separator line, unless it is the fixed date used by `git format-patch`.
 	Author     *PatchIdentity
 	AuthorDate time.Time
 
@@ -73,6 +78,14 @@ func (h *PatchHeader) Message() string {
 // short, raw, unix, and default formats (with local variants) used by the
 // --date flag in Git.
 func ParsePatchDate(s string) (time.Time, error) {
+	return ParsePatchDateWith(s)
+}
+
+// ParsePatchDateWith is like ParsePatchDate, but also tries the additional
+// layouts if s does not match any of the Git formats. Layouts use the format
+// of the time package. Times without a time zone are parsed in the local time
+// zone.
+func ParsePatchDateWith(s string, layouts ...string) (time.Time, error) {
 	const (
 		isoFormat          = "2006-01-02 15:04:05 -0700"
 		isoStrictFormat    = "2006-01-02T15:04:05-07:00"
@@ -113,6 +126,12 @@ func ParsePatchDate(s string) (time.Time, error) {
 		}
 	}
 
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
 	return time.Time{}, fmt.Errorf("unknown date format: %s", s)
 }
 
@@ -146,8 +165,28 @@ func WithSubjectCleanMode(m SubjectCleanMode) PatchHeaderOption {
 	}
 }
 
+// WithDateLayouts adds layouts that are tried when parsing dates in the header
+// if a date does not match any of the formats used by Git. See
+// ParsePatchDateWith for details.
+func WithDateLayouts(layouts ...string) PatchHeaderOption {
+	return func(opts *patchHeaderOptions) {
+		opts.dateLayouts = append(opts.dateLayouts, layouts...)
+	}
+}
+
+// WithRejectLeadingBlankLines makes parsing fail if the header starts with
+// one or more blank lines, which can indicate a malformed or forwarded
+// message. By default, leading blank lines are ignored.
+func WithRejectLeadingBlankLines() PatchHeaderOption {
+	return func(opts *patchHeaderOptions) {
+		opts.rejectLeadingBlankLines = true
+	}
+}
+
 type patchHeaderOptions struct {
-	subjectCleanMode SubjectCleanMode
+	subjectCleanMode        SubjectCleanMode
+	dateLayouts             []string
+	rejectLeadingBlankLines bool
 }
 
 // ParsePatchHeader parses the preamble string returned by [Parse] into a
@@ -173,6 +212,13 @@ type patchHeaderOptions struct {
 // returning errors, but will return errors if well-identified content like
 // dates or identies uses unknown or invalid formats.
 func ParsePatchHeader(header string, options ...PatchHeaderOption) (*PatchHeader, error) {
+	return ParsePatchHeaderReader(strings.NewReader(header), options...)
+}
+
+// ParsePatchHeaderReader is like ParsePatchHeader, but reads the header from
+// r instead of a string. It supports the same formats and options and avoids
+// buffering large headers in memory before parsing.
+func ParsePatchHeaderReader(r io.Reader, options ...PatchHeaderOption) (*PatchHeader, error) {
 	opts := patchHeaderOptions{
 		subjectCleanMode: SubjectCleanAll, // match git defaults
 	}
@@ -180,37 +226,66 @@ func ParsePatchHeader(header string, options ...PatchHeaderOption) (*PatchHeader
 		optFn(&opts)
 	}
 
-	header = strings.TrimSpace(header)
-	if header == "" {
-		return &PatchHeader{}, nil
+	br := bufio.NewReader(r)
+	blankLines, err := skipHeaderPrefix(br)
+	if err != nil {
+		if err == io.EOF {
+			return &PatchHeader{}, nil
+		}
+		return nil, err
+	}
+	if blankLines > 0 && opts.rejectLeadingBlankLines {
+		return nil, fmt.Errorf("patch header starts with %d blank line(s)", blankLines)
 	}
 
-	var firstLine, rest string
-	if idx := strings.IndexByte(header, '\n'); idx >= 0 {
-		firstLine = header[:idx]
-		rest = header[idx+1:]
-	} else {
-		firstLine = header
-		rest = ""
+	firstLine, err := br.ReadString('\n')
+	switch {
+	case err == io.EOF:
+		firstLine = strings.TrimRightFunc(firstLine, unicode.IsSpace)
+	case err != nil:
+		return nil, err
+	default:
+		firstLine = strings.TrimSuffix(firstLine, "\n")
 	}
 
 	switch {
 	case strings.HasPrefix(firstLine, mailHeaderPrefix):
-		return parseHeaderMail(firstLine, strings.NewReader(rest), opts)
+		return parseHeaderMail(firstLine, br, opts)
 
 	case strings.HasPrefix(firstLine, mailMinimumHeaderPrefix):
 		// With a minimum header, the first line is part of the actual mail
 		// content and needs to be parsed as part of the "rest"
-		return parseHeaderMail("", strings.NewReader(header), opts)
+		return parseHeaderMail("", io.MultiReader(strings.NewReader(firstLine+"\n"), br), opts)
 
 	case strings.HasPrefix(firstLine, prettyHeaderPrefix):
-		return parseHeaderPretty(firstLine, strings.NewReader(rest))
+		return parseHeaderPretty(firstLine, br, opts)
 	}
 
 	return nil, errors.New("unrecognized patch header format")
 }
 
-func parseHeaderPretty(prettyLine string, r io.Reader) (*PatchHeader, error) {
+// skipHeaderPrefix discards a byte order mark and any leading whitespace from
+// r and returns the number of blank lines it discarded. It returns io.EOF if r
+// contains no other content.
+func skipHeaderPrefix(r *bufio.Reader) (blankLines int, err error) {
+	if b, err := r.Peek(len(utf8BOM)); err == nil && string(b) == utf8BOM {
+		_, _ = r.Discard(len(utf8BOM))
+	}
+	for {
+		c, _, err := r.ReadRune()
+		if err != nil {
+			return blankLines, err
+		}
+		if c == '\n' {
+			blankLines++
+		}
+		if !unicode.IsSpace(c) {
+			return blankLines, r.UnreadRune()
+		}
+	}
+}
+
+func parseHeaderPretty(prettyLine string, r io.Reader, opts patchHeaderOptions) (*PatchHeader, error) {
 	const (
 		authorPrefix     = "Author:"
 		commitPrefix     = "Commit:"
@@ -253,21 +328,21 @@ func parseHeaderPretty(prettyLine string, r io.Reader) (*PatchHeader, error) {
 			h.Committer = &u
 
 		case strings.HasPrefix(line, datePrefix):
-			d, err := ParsePatchDate(strings.TrimSpace(line[len(datePrefix):]))
+			d, err := ParsePatchDateWith(strings.TrimSpace(line[len(datePrefix):]), opts.dateLayouts...)
 			if err != nil {
 				return nil, err
 			}
 			h.AuthorDate = d
 
 		case strings.HasPrefix(line, authorDatePrefix):
-			d, err := ParsePatchDate(strings.TrimSpace(line[len(authorDatePrefix):]))
+			d, err := ParsePatchDateWith(strings.TrimSpace(line[len(authorDatePrefix):]), opts.dateLayouts...)
 			if err != nil {
 				return nil, err
 			}
 			h.AuthorDate = d
 
 		case strings.HasPrefix(line, commitDatePrefix):
-			d, err := ParsePatchDate(strings.TrimSpace(line[len(commitDatePrefix):]))
+			d, err := ParsePatchDateWith(strings.TrimSpace(line[len(commitDatePrefix):]), opts.dateLayouts...)
 			if err != nil {
 				return nil, err
 			}
@@ -362,14 +437,16 @@ func parseHeaderMail(mailLine string, r io.Reader, opts patchHeaderOptions) (*Pa
 
 	h := &PatchHeader{}
 
+	var mailDate string
 	if strings.HasPrefix(mailLine, mailHeaderPrefix) {
 		mailLine = strings.TrimPrefix(mailLine, mailHeaderPrefix)
 		if i := strings.IndexByte(mailLine, ' '); i > 0 {
 			h.SHA = mailLine[:i]
+			mailDate = strings.Join(strings.Fields(mailLine[i+1:]), " ")
 		}
 	}
 
-	from := msg.Header.Get("From")
+	from := decodeHeader(msg.Header.Get("From"))
 	if from != "" {
 		u, err := ParsePatchIdentity(from)
 		if err != nil {
@@ -380,11 +457,17 @@ func parseHeaderMail(mailLine string, r io.Reader, opts patchHeaderOptions) (*Pa
 
 	date := msg.Header.Get("Date")
 	if date != "" {
-		d, err := ParsePatchDate(date)
+		d, err := ParsePatchDateWith(date, opts.dateLayouts...)
 		if err != nil {
 			return nil, err
 		}
 		h.AuthorDate = d
+	} else if mailDate != "" && mailDate != mailMagicDate {
+		// the separator line is not a required part of the header, so ignore
+		// dates that do not parse instead of failing
+		if d, err := ParsePatchDateWith(mailDate, opts.dateLayouts...); err == nil {
+			h.AuthorDate = d
+		}
 	}
 
 	subject := msg.Header.Get("Subject")
@@ -403,7 +486,7 @@ func cleanSubject(s string, mode SubjectCleanMode) (prefix string, subject strin
 	switch mode {
 	case SubjectCleanAll, SubjectCleanPatchOnly:
 	case SubjectCleanWhitespace:
-		return "", strings.TrimSpace(decodeSubject(s))
+		return "", strings.TrimSpace(decodeHeader(s))
 	default:
 		panic(fmt.Sprintf("unknown clean mode: %d", mode))
 	}
@@ -440,31 +523,19 @@ func cleanSubject(s string, mode SubjectCleanMode) (prefix string, subject strin
 	}
 
 	prefix = strings.TrimLeftFunc(s[:at], unicode.IsSpace)
-	subject = strings.TrimRightFunc(decodeSubject(s[at:]), unicode.IsSpace)
+	subject = strings.TrimRightFunc(decodeHeader(s[at:]), unicode.IsSpace)
 	return
 }
 
-// Decodes a subject line. Currently only supports quoted-printable UTF-8. This format is the result
-// of a `git format-patch` when the commit title has a non-ASCII character (i.e. an emoji).
-// See for reference: https://stackoverflow.com/questions/27695749/gmail-api-not-respecting-utf-encoding-in-subject
-func decodeSubject(encoded string) string {
-	if !strings.HasPrefix(encoded, "=?UTF-8?q?") {
-		// not UTF-8 encoded
-		return encoded
-	}
-
-	// If the subject is too long, `git format-patch` may produce a subject line across
-	// multiple lines. When parsed, this can look like the following:
-	// <UTF8-prefix><first-line> <UTF8-prefix><second-line>
-	payload := " " + encoded
-	payload = strings.ReplaceAll(payload, " =?UTF-8?q?", "")
-	payload = strings.ReplaceAll(payload, "?=", "")
-
-	decoded, err := ioutil.ReadAll(quotedprintable.NewReader(strings.NewReader(payload)))
+// decodeHeader decodes any RFC 2047 encoded-words in a mail header value.
+// Adjacent encoded-words, like those produced when `git format-patch` folds
+// a long subject, are joined without the whitespace between them. If the
+// value cannot be decoded, decodeHeader returns it unchanged.
+func decodeHeader(encoded string) string {
+	var dec mime.WordDecoder
+	decoded, err := dec.DecodeHeader(encoded)
 	if err != nil {
-		// if err, abort decoding and return original subject
 		return encoded
 	}
-
-	return string(decoded)
+	return decoded
 }
@@ -1,7 +1,12 @@
 package gitdiff
 
 import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
 	"testing"
+	"testing/iotest"
 	"time"
 )
 
@@ -9,9 +14,10 @@ func TestParsePatchDate(t *testing.T) {
 	expected := time.Date(2020, 4, 9, 8, 7, 6, 0, time.UTC)
 
 	tests := map[string]struct {
-		Input  string
-		Output time.Time
-		Err    interface{}
+		Input   string
+		Layouts []string
+		Output  time.Time
+		Err     interface{}
 	}{
 		"default": {
 			Input:  "Thu Apr 9 01:07:06 2020 -0700",
@@ -49,6 +55,16 @@ func TestParsePatchDate(t *testing.T) {
 			Input: "4/9/2020 01:07:06 PDT",
 			Err:   "unknown date format",
 		},
+		"customLayout": {
+			Input:   "4/9/2020 01:07:06 -0700",
+			Layouts: []string{"2006/01/02", "1/2/2006 15:04:05 -0700"},
+			Output:  expected,
+		},
+		"customLayoutNoMatch": {
+			Input:   "4/9/2020 01:07:06 PDT",
+			Layouts: []string{"2006/01/02"},
+			Err:     "unknown date format",
+		},
 		"empty": {
 			Input: "",
 		},
@@ -56,7 +72,7 @@ func TestParsePatchDate(t *testing.T) {
 
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
-			d, err := ParsePatchDate(test.Input)
+			d, err := ParsePatchDateWith(test.Input, test.Layouts...)
 			if test.Err != nil {
 				assertError(t, test.Err, err, "parsing date")
 				return
@@ -233,6 +249,127 @@ Another body line.
 				Body:       expectedBody,
 			},
 		},
+		"mailboxCustomDate": {
+			Input: `From 61f5cd90bed4d204ee3feb3aa41ee91d4734855b Mon Sep 17 00:00:00 2001
+From: Morton Haypenny <mhaypenny@example.com>
+Date: 4/11/2020 15:21:23 -0700
+Subject: [PATCH] A sample commit to test header parsing
+`,
+			Options: []PatchHeaderOption{
+				WithDateLayouts("1/2/2006 15:04:05 -0700"),
+			},
+			Header: PatchHeader{
+				SHA:           expectedSHA,
+				Author:        expectedIdentity,
+				AuthorDate:    expectedDate,
+				Title:         expectedTitle,
+				SubjectPrefix: "[PATCH] ",
+			},
+		},
+		"mailboxSeparatorDate": {
+			Input: `From 61f5cd90bed4d204ee3feb3aa41ee91d4734855b Sat Apr 11 15:21:23 2020 -0700
+From: Morton Haypenny <mhaypenny@example.com>
+Subject: [PATCH] A sample commit to test header parsing
+`,
+			Header: PatchHeader{
+				SHA:           expectedSHA,
+				Author:        expectedIdentity,
+				AuthorDate:    expectedDate,
+				Title:         expectedTitle,
+				SubjectPrefix: "[PATCH] ",
+			},
+		},
+		"mailboxSeparatorDateCustom": {
+			Input: `From 61f5cd90bed4d204ee3feb3aa41ee91d4734855b 4/11/2020  15:21:23 -0700
+From: Morton Haypenny <mhaypenny@example.com>
+Subject: [PATCH] A sample commit to test header parsing
+`,
+			Options: []PatchHeaderOption{
+				WithDateLayouts("1/2/2006 15:04:05 -0700"),
+			},
+			Header: PatchHeader{
+				SHA:           expectedSHA,
+				Author:        expectedIdentity,
+				AuthorDate:    expectedDate,
+				Title:         expectedTitle,
+				SubjectPrefix: "[PATCH] ",
+			},
+		},
+		"mailboxSeparatorDateIgnored": {
+			Input: `From 61f5cd90bed4d204ee3feb3aa41ee91d4734855b Sun Apr 12 09:00:00 2020 -0700
+From: Morton Haypenny <mhaypenny@example.com>
+Date: Sat, 11 Apr 2020 15:21:23 -0700
+Subject: [PATCH] A sample commit to test header parsing
+`,
+			Header: PatchHeader{
+				SHA:           expectedSHA,
+				Author:        expectedIdentity,
+				AuthorDate:    expectedDate,
+				Title:         expectedTitle,
+				SubjectPrefix: "[PATCH] ",
+			},
+		},
+		"mailboxSeparatorMagicDate": {
+			Input: `From 61f5cd90bed4d204ee3feb3aa41ee91d4734855b Mon Sep 17 00:00:00 2001
+From: Morton Haypenny <mhaypenny@example.com>
+Subject: [PATCH] A sample commit to test header parsing
+`,
+			Header: PatchHeader{
+				SHA:           expectedSHA,
+				Author:        expectedIdentity,
+				Title:         expectedTitle,
+				SubjectPrefix: "[PATCH] ",
+			},
+		},
+		"mailboxSeparatorInvalidDate": {
+			Input: `From 61f5cd90bed4d204ee3feb3aa41ee91d4734855b not a date
+From: Morton Haypenny <mhaypenny@example.com>
+Subject: [PATCH] A sample commit to test header parsing
+`,
+			Header: PatchHeader{
+				SHA:           expectedSHA,
+				Author:        expectedIdentity,
+				Title:         expectedTitle,
+				SubjectPrefix: "[PATCH] ",
+			},
+		},
+		"prettyCustomDate": {
+			Input: `commit 61f5cd90bed4d204ee3feb3aa41ee91d4734855b
+Author: Morton Haypenny <mhaypenny@example.com>
+Date:   4/11/2020 15:21:23 -0700
+
+    A sample commit to test header parsing
+`,
+			Options: []PatchHeaderOption{
+				WithDateLayouts("1/2/2006 15:04:05 -0700"),
+			},
+			Header: PatchHeader{
+				SHA:        expectedSHA,
+				Author:     expectedIdentity,
+				AuthorDate: expectedDate,
+				Title:      expectedTitle,
+			},
+		},
+		"prettyQuotedNameWithComma": {
+			Input: `commit 61f5cd90bed4d204ee3feb3aa41ee91d4734855b
+Author: "Haypenny, Morton" <mhaypenny@example.com>
+Commit: Haypenny, Morton <mhaypenny@example.com>
+
+    A sample commit to test header parsing
+`,
+			Header: PatchHeader{
+				SHA: expectedSHA,
+				Author: &PatchIdentity{
+					Name:  "Haypenny, Morton",
+					Email: "mhaypenny@example.com",
+				},
+				Committer: &PatchIdentity{
+					Name:  "Haypenny, Morton",
+					Email: "mhaypenny@example.com",
+				},
+				Title: expectedTitle,
+			},
+		},
 		"mailboxEmojiOneLine": {
 			Input: `From 61f5cd90bed4d204ee3feb3aa41ee91d4734855b Mon Sep 17 00:00:00 2001
 From: Morton Haypenny <mhaypenny@example.com>
@@ -272,6 +409,37 @@ Another body line.
 				Body:       expectedBody,
 			},
 		},
+		"mailboxEncodedAuthor": {
+			Input: `From 61f5cd90bed4d204ee3feb3aa41ee91d4734855b Mon Sep 17 00:00:00 2001
+From: =?UTF-8?q?J=C3=B6rg=20Haypenny?= <mhaypenny@example.com>
+Date: Sat, 11 Apr 2020 15:21:23 -0700
+Subject: [PATCH] =?utf-8?b?QSBzYW1wbGUgY29tbWl0IHRvIHRlc3QgaGVhZGVyIHBhcnNpbmc=?=
+`,
+			Header: PatchHeader{
+				SHA: expectedSHA,
+				Author: &PatchIdentity{
+					Name:  "J\u00f6rg Haypenny",
+					Email: "mhaypenny@example.com",
+				},
+				AuthorDate:    expectedDate,
+				Title:         expectedTitle,
+				SubjectPrefix: "[PATCH] ",
+			},
+		},
+		"mailboxUnknownCharset": {
+			Input: `From 61f5cd90bed4d204ee3feb3aa41ee91d4734855b Mon Sep 17 00:00:00 2001
+From: Morton Haypenny <mhaypenny@example.com>
+Date: Sat, 11 Apr 2020 15:21:23 -0700
+Subject: [PATCH] =?x-unknown?q?A=20sample?=
+`,
+			Header: PatchHeader{
+				SHA:           expectedSHA,
+				Author:        expectedIdentity,
+				AuthorDate:    expectedDate,
+				Title:         "=?x-unknown?q?A=20sample?=",
+				SubjectPrefix: "[PATCH] ",
+			},
+		},
 		"mailboxRFC5322SpecialCharacters": {
 			Input: `From 61f5cd90bed4d204ee3feb3aa41ee91d4734855b Mon Sep 17 00:00:00 2001
 From: "dependabot[bot]" <12345+dependabot[bot]@users.noreply.github.com>
@@ -400,6 +568,34 @@ Author: Morton Haypenny <mhaypenny@example.com>
 				Title:  expectedTitle,
 			},
 		},
+		"rejectLeadingBlankLines": {
+			Input: `
+
+commit 61f5cd90bed4d204ee3feb3aa41ee91d4734855b
+Author: Morton Haypenny <mhaypenny@example.com>
+
+    A sample commit to test header parsing
+`,
+			Options: []PatchHeaderOption{
+				WithRejectLeadingBlankLines(),
+			},
+			Err: "starts with 2 blank line(s)",
+		},
+		"rejectLeadingBlankLinesNoBlankLines": {
+			Input: "\ufeff" + `commit 61f5cd90bed4d204ee3feb3aa41ee91d4734855b
+Author: Morton Haypenny <mhaypenny@example.com>
+
+    A sample commit to test header parsing
+`,
+			Options: []PatchHeaderOption{
+				WithRejectLeadingBlankLines(),
+			},
+			Header: PatchHeader{
+				SHA:    expectedSHA,
+				Author: expectedIdentity,
+				Title:  expectedTitle,
+			},
+		},
 		"emptyHeader": {
 			Input:  "",
 			Header: PatchHeader{},
@@ -463,6 +659,64 @@ func assertPatchIdentity(t *testing.T, kind string, exp, act *PatchIdentity) {
 	}
 }
 
+func TestParsePatchHeaderReader(t *testing.T) {
+	inputs := map[string]string{
+		"pretty": "\ufeff" + `
+commit 61f5cd90bed4d204ee3feb3aa41ee91d4734855b
+Author: Morton Haypenny <mhaypenny@example.com>
+Date:   Sat Apr 11 15:21:23 2020 -0700
+
+    A sample commit to test header parsing
+
+    The medium format shows the body.
+
+`,
+		"mail": `From 61f5cd90bed4d204ee3feb3aa41ee91d4734855b Mon Sep 17 00:00:00 2001
+From: Morton Haypenny <mhaypenny@example.com>
+Date: Sat, 11 Apr 2020 15:21:23 -0700
+Subject: [PATCH] A sample commit to test header parsing
+
+The medium format shows the body.
+---
+ file.txt | 1 +
+`,
+		"mailMinimum": `From: Morton Haypenny <mhaypenny@example.com>
+Subject: [PATCH] A sample commit to test header parsing
+
+The medium format shows the body.
+`,
+		"singleLine": "  commit 61f5cd90bed4d204ee3feb3aa41ee91d4734855b  ",
+		"whitespace": " \n\t\n",
+	}
+
+	for name, input := range inputs {
+		t.Run(name, func(t *testing.T) {
+			expected, err := ParsePatchHeader(input)
+			if err != nil {
+				t.Fatalf("unexpected error parsing patch header string: %v", err)
+			}
+
+			h, err := ParsePatchHeaderReader(iotest.OneByteReader(strings.NewReader(input)))
+			if err != nil {
+				t.Fatalf("unexpected error parsing patch header reader: %v", err)
+			}
+			if !reflect.DeepEqual(expected, h) {
+				t.Errorf("incorrect header\nexpected: %+v\n  actual: %+v", expected, h)
+			}
+		})
+	}
+
+	t.Run("readError", func(t *testing.T) {
+		readErr := errors.New("read failed")
+		r := io.MultiReader(strings.NewReader("commit 61f5cd90bed4d204ee3feb3aa41ee91d4734855b\n"), iotest.ErrReader(readErr))
+
+		_, err := ParsePatchHeaderReader(r)
+		if !errors.Is(err, readErr) {
+			t.Errorf("expected read error, but got: %v", err)
+		}
+	})
+}
+
 func TestCleanSubject(t *testing.T) {
 	expectedSubject := "A sample commit to test header parsing"
 
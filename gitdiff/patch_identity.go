@@ -25,6 +25,10 @@ func (i PatchIdentity) String() string {
 //
 //	author@example.com
 //	Author Name <author@example.com>
+//	"Name, Author" <author@example.com>
+//
+// Quotes around the name are removed, but the quoted text is kept exactly,
+// including any commas. Unlike net/mail, a comma never separates addresses.
 //
 // If the input is not one of these formats, ParsePatchIdentity applies a
 // heuristic to separate the name and email portions. If both the name and
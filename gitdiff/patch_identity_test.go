@@ -66,6 +66,27 @@ func TestParsePatchIdentity(t *testing.T) {
 				Email: "mhaypenny@example.com",
 			},
 		},
+		"nameWithComma": {
+			Input: "Haypenny, Morton <mhaypenny@example.com>",
+			Output: PatchIdentity{
+				Name:  "Haypenny, Morton",
+				Email: "mhaypenny@example.com",
+			},
+		},
+		"quotedNameWithComma": {
+			Input: `"Kleine-König, Uwe" <u.kleine-koenig@example.com>`,
+			Output: PatchIdentity{
+				Name:  "Kleine-König, Uwe",
+				Email: "u.kleine-koenig@example.com",
+			},
+		},
+		"quotedNameWithCommaAndParens": {
+			Input: `"Haypenny, Morton (Sales)" <mhaypenny@example.com>`,
+			Output: PatchIdentity{
+				Name:  "Haypenny, Morton (Sales)",
+				Email: "mhaypenny@example.com",
+			},
+		},
 		"emptyEmail": {
 			Input: "Morton Haypenny <>",
 			Output: PatchIdentity{
@@ -0,0 +1,170 @@
+package gitdiff
+
+import (
+	"errors"
+	"io"
+	"strings"
+)
+
+// ErrRangeDiff is returned when parsing input that is the output of `git
+// range-diff` instead of a patch. Use ParseRangeDiff to parse this input.
+var ErrRangeDiff = errors.New("gitdiff: input is a range-diff, not a patch")
+
+// RangeDiffStatus describes how a commit changed between two ranges in the
+// output of `git range-diff`. Each status is the character that marks it in
+// the output.
+type RangeDiffStatus byte
+
+const (
+	// RangeDiffEqual means the two versions of the commit are the same.
+	RangeDiffEqual RangeDiffStatus = '='
+	// RangeDiffModified means the two versions of the commit are different.
+	RangeDiffModified RangeDiffStatus = '!'
+	// RangeDiffRemoved means the commit is only in the old range.
+	RangeDiffRemoved RangeDiffStatus = '<'
+	// RangeDiffAdded means the commit is only in the new range.
+	RangeDiffAdded RangeDiffStatus = '>'
+)
+
+// RangeDiffPair is a pair of commits from the output of `git range-diff`.
+type RangeDiffPair struct {
+	// OldIndex and NewIndex are the one-indexed positions of the commits in
+	// the old and new ranges. OldCommit and NewCommit are the abbreviated
+	// commit IDs. For commits that are only in one range, the index is 0 and
+	// the commit ID is empty on the other side.
+	OldIndex  int
+	OldCommit string
+	NewIndex  int
+	NewCommit string
+
+	Status  RangeDiffStatus
+	Subject string
+
+	// Diff is the diff between the two versions of the commit, with the
+	// indentation used by `git range-diff` removed. It is not a patch that
+	// can be parsed by Parse: it compares the text of two patches, including
+	// their commit messages. It is empty if the versions are the same.
+	Diff string
+}
+
+// ParseRangeDiff parses the output of `git range-diff`. It returns the commit
+// pairs in the order they appear in the input. Any lines before the first pair
+// are ignored. The input must not contain color codes.
+func ParseRangeDiff(r io.Reader) ([]*RangeDiffPair, error) {
+	p := newParser(r, parserOptions{})
+	if err := p.Next(); err != nil {
+		if err == io.EOF {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var pairs []*RangeDiffPair
+	var diff strings.Builder
+	setDiff := func() {
+		if n := len(pairs); n > 0 {
+			pairs[n-1].Diff = diff.String()
+		}
+		diff.Reset()
+	}
+
+	for {
+		line := p.Line(0)
+		if pair, ok := parseRangeDiffPair(line); ok {
+			setDiff()
+			pairs = append(pairs, pair)
+		} else if len(pairs) > 0 {
+			diff.WriteString(strings.TrimPrefix(line, "    "))
+		}
+
+		if err := p.Next(); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+	}
+	setDiff()
+
+	return pairs, nil
+}
+
+// isRangeDiffPair returns true if line is a commit pair line from the output
+// of `git range-diff`, like
+//
+//	1:  a1b2c3d ! 1:  e4f5a6b Commit subject
+func isRangeDiffPair(line string) bool {
+	_, ok := parseRangeDiffPair(line)
+	return ok
+}
+
+func parseRangeDiffPair(line string) (*RangeDiffPair, bool) {
+	s := strings.TrimSuffix(line, "\n")
+	next := func() string {
+		s = strings.TrimLeft(s, " ")
+		field, rest, _ := strings.Cut(s, " ")
+		s = rest
+		return field
+	}
+
+	var pair RangeDiffPair
+	var ok bool
+	if pair.OldIndex, pair.OldCommit, ok = parseRangeDiffCommit(next(), next()); !ok {
+		return nil, false
+	}
+
+	status := next()
+	if len(status) != 1 {
+		return nil, false
+	}
+	switch pair.Status = RangeDiffStatus(status[0]); pair.Status {
+	case RangeDiffEqual, RangeDiffModified, RangeDiffRemoved, RangeDiffAdded:
+	default:
+		return nil, false
+	}
+
+	if pair.NewIndex, pair.NewCommit, ok = parseRangeDiffCommit(next(), next()); !ok {
+		return nil, false
+	}
+	if (pair.OldCommit == "") != (pair.Status == RangeDiffAdded) || (pair.NewCommit == "") != (pair.Status == RangeDiffRemoved) {
+		return nil, false
+	}
+
+	pair.Subject = s
+	return &pair, true
+}
+
+// parseRangeDiffCommit parses the index and commit ID for one side of a
+// commit pair line. A commit that is not in the range has an index of "-:"
+// and an ID of dashes.
+func parseRangeDiffCommit(index, commit string) (int, string, bool) {
+	if !strings.HasSuffix(index, ":") || commit == "" {
+		return 0, "", false
+	}
+	index = index[:len(index)-1]
+
+	if index == "-" {
+		if strings.Trim(commit, "-") != "" {
+			return 0, "", false
+		}
+		return 0, "", true
+	}
+
+	n := 0
+	for _, c := range index {
+		if c < '0' || c > '9' {
+			return 0, "", false
+		}
+		n = n*10 + int(c-'0')
+	}
+	if n == 0 {
+		return 0, "", false
+	}
+
+	for i := 0; i < len(commit); i++ {
+		if !isHex(commit[i]) {
+			return 0, "", false
+		}
+	}
+	return n, commit, true
+}
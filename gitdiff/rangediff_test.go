@@ -0,0 +1,119 @@
+package gitdiff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testRangeDiff = `1:  a1b2c3d = 1:  e4f5a6b Add the first feature
+2:  1234567 ! 2:  89abcde Fix the parser
+    @@ Metadata
+      ## Commit message ##
+         Fix the parser
+     
+    +    Handle empty input.
+    +
+     ## parser.go ##
+    @@ parser.go: func parse(s string) {
+    -+	if s == "" {
+    ++	if len(s) == 0 {
+3:  fedcba9 < -:  ------- Remove old code
+-:  ------- > 3:  0a1b2c3 Add tests
+`
+
+func TestParseRangeDiff(t *testing.T) {
+	pairs, err := ParseRangeDiff(strings.NewReader("Range-diff:\n" + testRangeDiff))
+	if err != nil {
+		t.Fatalf("unexpected error parsing range-diff: %v", err)
+	}
+
+	expected := []*RangeDiffPair{
+		{
+			OldIndex:  1,
+			OldCommit: "a1b2c3d",
+			NewIndex:  1,
+			NewCommit: "e4f5a6b",
+			Status:    RangeDiffEqual,
+			Subject:   "Add the first feature",
+		},
+		{
+			OldIndex:  2,
+			OldCommit: "1234567",
+			NewIndex:  2,
+			NewCommit: "89abcde",
+			Status:    RangeDiffModified,
+			Subject:   "Fix the parser",
+			Diff: `@@ Metadata
+  ## Commit message ##
+     Fix the parser
+ 
++    Handle empty input.
++
+ ## parser.go ##
+@@ parser.go: func parse(s string) {
+-+	if s == "" {
+++	if len(s) == 0 {
+`,
+		},
+		{
+			OldIndex:  3,
+			OldCommit: "fedcba9",
+			Status:    RangeDiffRemoved,
+			Subject:   "Remove old code",
+		},
+		{
+			NewIndex:  3,
+			NewCommit: "0a1b2c3",
+			Status:    RangeDiffAdded,
+			Subject:   "Add tests",
+		},
+	}
+
+	if len(pairs) != len(expected) {
+		t.Fatalf("incorrect number of pairs: expected %d, actual %d", len(expected), len(pairs))
+	}
+	for i := range expected {
+		if !reflect.DeepEqual(expected[i], pairs[i]) {
+			t.Errorf("incorrect pair %d\nexpected: %+v\n  actual: %+v", i, expected[i], pairs[i])
+		}
+	}
+}
+
+func TestParseRangeDiffPair(t *testing.T) {
+	tests := map[string]bool{
+		"1:  a1b2c3d = 1:  e4f5a6b Subject\n":   true,
+		"10:  a1b2c3d ! 12:  e4f5a6b Subject\n": true,
+		"-:  ------- > 1:  e4f5a6b Subject\n":   true,
+		"1:  a1b2c3d < -:  ------- Subject\n":   true,
+		"1:  a1b2c3d > -:  ------- Subject\n":   false,
+		"1:  a1b2c3d ? 1:  e4f5a6b Subject\n":   false,
+		"1:  not-hex = 1:  e4f5a6b Subject\n":   false,
+		"0:  a1b2c3d = 1:  e4f5a6b Subject\n":   false,
+		"Note: this is = not: a pair\n":         false,
+	}
+
+	for line, expected := range tests {
+		if actual := isRangeDiffPair(line); actual != expected {
+			t.Errorf("incorrect result for %q: expected %t, actual %t", line, expected, actual)
+		}
+	}
+}
+
+func TestParseRangeDiffInput(t *testing.T) {
+	files, _, err := Parse(strings.NewReader(testRangeDiff))
+	if err != ErrRangeDiff {
+		t.Fatalf("expected ErrRangeDiff, but got %v", err)
+	}
+	if len(files) != 0 {
+		t.Errorf("expected no files, but got %d", len(files))
+	}
+
+	files, _, err = NewParser(WithErrorMode(ContinueOnError)).Parse(strings.NewReader(testRangeDiff))
+	if err != ErrRangeDiff {
+		t.Fatalf("expected ErrRangeDiff with ContinueOnError, but got %v", err)
+	}
+	if len(files) != 0 {
+		t.Errorf("expected no files with ContinueOnError, but got %d", len(files))
+	}
+}
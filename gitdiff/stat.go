@@ -0,0 +1,259 @@
+package gitdiff
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	statWidth = 80
+)
+
+// FileStat summarizes the changes to a single file, like a line in the
+// output of `git diff --stat`.
+type FileStat struct {
+	// Name is the display name of the file. For renames and copies, it uses
+	// the "old => new" form, with common path components outside of braces.
+	Name string
+
+	LinesAdded   int64
+	LinesDeleted int64
+
+	// IsBinary is true if the file is a binary file. Binary files do not
+	// report added or deleted lines.
+	IsBinary bool
+}
+
+// PatchStat summarizes the changes to a set of files, like the output of
+// `git diff --stat`.
+type PatchStat struct {
+	Files []FileStat
+
+	LinesAdded   int64
+	LinesDeleted int64
+}
+
+// Stat returns a summary of the changes to the file.
+func (f *File) Stat() FileStat {
+	s := FileStat{IsBinary: f.IsBinary}
+
+	switch {
+	case (f.IsRename || f.IsCopy) && f.OldName != "" && f.NewName != "":
+		s.Name = statRenameName(f.OldName, f.NewName)
+	case f.NewName != "":
+		s.Name = f.NewName
+	default:
+		s.Name = f.OldName
+	}
+
+	s.LinesAdded = f.Additions()
+	s.LinesDeleted = f.Deletions()
+	return s
+}
+
+// Additions returns the number of lines added to the file by all of its text
+// fragments. It returns 0 for binary files.
+func (f *File) Additions() int64 {
+	var n int64
+	if !f.IsBinary {
+		for _, frag := range f.TextFragments {
+			n += frag.LinesAdded
+		}
+	}
+	return n
+}
+
+// Deletions returns the number of lines deleted from the file by all of its
+// text fragments. It returns 0 for binary files.
+func (f *File) Deletions() int64 {
+	var n int64
+	if !f.IsBinary {
+		for _, frag := range f.TextFragments {
+			n += frag.LinesDeleted
+		}
+	}
+	return n
+}
+
+// Stat returns a summary of the changes to all files.
+func Stat(files []*File) PatchStat {
+	var s PatchStat
+	for _, f := range files {
+		fs := f.Stat()
+		s.Files = append(s.Files, fs)
+		s.LinesAdded += fs.LinesAdded
+		s.LinesDeleted += fs.LinesDeleted
+	}
+	return s
+}
+
+// Summary returns the summary line of the stat, like
+//
+//	2 files changed, 5 insertions(+), 1 deletion(-)
+//
+// Like Git, the number of insertions is omitted if it is zero and there are
+// deletions and the number of deletions is omitted if it is zero and there
+// are insertions.
+func (s PatchStat) Summary() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%d %s changed", len(s.Files), plural(int64(len(s.Files)), "file", "files"))
+	if len(s.Files) == 0 {
+		return b.String()
+	}
+	if s.LinesAdded > 0 || s.LinesDeleted == 0 {
+		fmt.Fprintf(&b, ", %d %s(+)", s.LinesAdded, plural(s.LinesAdded, "insertion", "insertions"))
+	}
+	if s.LinesDeleted > 0 || s.LinesAdded == 0 {
+		fmt.Fprintf(&b, ", %d %s(-)", s.LinesDeleted, plural(s.LinesDeleted, "deletion", "deletions"))
+	}
+	return b.String()
+}
+
+// String formats the stat like the output of `git diff --stat` for a
+// terminal that is 80 columns wide. It includes a line for each file followed
+// by the summary line.
+func (s PatchStat) String() string {
+	var maxChange int64
+	var nameWidth int
+	var hasBinary bool
+
+	for _, fs := range s.Files {
+		if len(fs.Name) > nameWidth {
+			nameWidth = len(fs.Name)
+		}
+		if fs.IsBinary {
+			hasBinary = true
+			continue
+		}
+		maxChange = max(maxChange, fs.LinesAdded+fs.LinesDeleted)
+	}
+
+	numberWidth := len(strconv.FormatInt(maxChange, 10))
+	if hasBinary && numberWidth < len("Bin") {
+		numberWidth = len("Bin")
+	}
+
+	graphWidth := int(maxChange)
+	if nameWidth+numberWidth+6+graphWidth > statWidth {
+		if limit := statWidth*3/8 - numberWidth - 6; graphWidth > limit {
+			graphWidth = limit
+			if graphWidth < 6 {
+				graphWidth = 6
+			}
+		}
+		if limit := statWidth - numberWidth - 6 - graphWidth; nameWidth > limit {
+			nameWidth = limit
+		} else {
+			graphWidth = statWidth - numberWidth - 6 - nameWidth
+		}
+	}
+
+	var b strings.Builder
+	for _, fs := range s.Files {
+		name := fs.Name
+		if len(name) > nameWidth {
+			name = name[len(name)-(nameWidth-3):]
+			if slash := strings.IndexByte(name, '/'); slash >= 0 {
+				name = name[slash:]
+			}
+			name = "..." + name
+		}
+		fmt.Fprintf(&b, " %-*s |", nameWidth, name)
+
+		if fs.IsBinary {
+			fmt.Fprintf(&b, " %*s\n", numberWidth, "Bin")
+			continue
+		}
+
+		added, deleted := fs.LinesAdded, fs.LinesDeleted
+		fmt.Fprintf(&b, " %*d", numberWidth, added+deleted)
+		if added+deleted > 0 {
+			b.WriteByte(' ')
+		}
+
+		if int64(graphWidth) < maxChange {
+			total := scaleStat(added+deleted, graphWidth, maxChange)
+			if total < 2 && added > 0 && deleted > 0 {
+				total = 2
+			}
+			if added < deleted {
+				added = scaleStat(added, graphWidth, maxChange)
+				deleted = total - added
+			} else {
+				deleted = scaleStat(deleted, graphWidth, maxChange)
+				added = total - deleted
+			}
+		}
+		b.WriteString(strings.Repeat("+", int(added)))
+		b.WriteString(strings.Repeat("-", int(deleted)))
+		b.WriteByte('\n')
+	}
+
+	b.WriteByte(' ')
+	b.WriteString(s.Summary())
+	b.WriteByte('\n')
+	return b.String()
+}
+
+// scaleStat scales n, which is at most maxChange, to fit in width. Non-zero
+// values always scale to at least 1.
+func scaleStat(n int64, width int, maxChange int64) int64 {
+	if n == 0 {
+		return 0
+	}
+	return 1 + (n*int64(width-1))/maxChange
+}
+
+// statRenameName returns the display name for a renamed or copied file. If
+// the names share leading or trailing path components, only the differing
+// parts are shown in braces, like "dir/{old => new}/file.txt".
+func statRenameName(oldName, newName string) string {
+	at := func(s string, i int) byte {
+		if i >= len(s) {
+			return 0
+		}
+		return s[i]
+	}
+
+	var pfxLen int
+	for i := 0; i < len(oldName) && i < len(newName) && oldName[i] == newName[i]; i++ {
+		if oldName[i] == '/' {
+			pfxLen = i + 1
+		}
+	}
+
+	// If there is a common prefix, it ends in a slash. Let the suffix search
+	// see this slash so that it can find a suffix that starts at the prefix.
+	pfxAdjust := 0
+	if pfxLen > 0 {
+		pfxAdjust = 1
+	}
+
+	var sfxLen int
+	for i, j := len(oldName), len(newName); i >= pfxLen-pfxAdjust && j >= pfxLen-pfxAdjust && at(oldName, i) == at(newName, j); i, j = i-1, j-1 {
+		if at(oldName, i) == '/' {
+			sfxLen = len(oldName) - i
+		}
+	}
+
+	if pfxLen+sfxLen == 0 {
+		return oldName + " => " + newName
+	}
+
+	midName := func(name string) string {
+		if end := len(name) - sfxLen; end > pfxLen {
+			return name[pfxLen:end]
+		}
+		return ""
+	}
+	return oldName[:pfxLen] + "{" + midName(oldName) + " => " + midName(newName) + "}" + oldName[len(oldName)-sfxLen:]
+}
+
+func plural(n int64, singular, plural string) string {
+	if n == 1 {
+		return singular
+	}
+	return plural
+}
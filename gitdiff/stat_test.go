@@ -0,0 +1,183 @@
+package gitdiff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFileStat(t *testing.T) {
+	tests := map[string]struct {
+		File *File
+		Stat FileStat
+	}{
+		"modify": {
+			File: &File{
+				OldName: "file.txt",
+				NewName: "file.txt",
+				TextFragments: []*TextFragment{
+					{LinesAdded: 2, LinesDeleted: 1},
+					{LinesAdded: 3},
+				},
+			},
+			Stat: FileStat{Name: "file.txt", LinesAdded: 5, LinesDeleted: 1},
+		},
+		"delete": {
+			File: &File{
+				OldName:  "file.txt",
+				IsDelete: true,
+				TextFragments: []*TextFragment{
+					{LinesDeleted: 4},
+				},
+			},
+			Stat: FileStat{Name: "file.txt", LinesDeleted: 4},
+		},
+		"rename": {
+			File: &File{
+				OldName:  "dir/old.txt",
+				NewName:  "dir/new.txt",
+				IsRename: true,
+			},
+			Stat: FileStat{Name: "dir/{old.txt => new.txt}"},
+		},
+		"binary": {
+			File: &File{
+				OldName:  "file.bin",
+				NewName:  "file.bin",
+				IsBinary: true,
+			},
+			Stat: FileStat{Name: "file.bin", IsBinary: true},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			stat := test.File.Stat()
+			if !reflect.DeepEqual(test.Stat, stat) {
+				t.Errorf("incorrect stat\nexpected: %+v\n  actual: %+v", test.Stat, stat)
+			}
+			if n := test.File.Additions(); n != test.Stat.LinesAdded {
+				t.Errorf("incorrect additions: expected %d, actual %d", test.Stat.LinesAdded, n)
+			}
+			if n := test.File.Deletions(); n != test.Stat.LinesDeleted {
+				t.Errorf("incorrect deletions: expected %d, actual %d", test.Stat.LinesDeleted, n)
+			}
+		})
+	}
+}
+
+func TestStatRenameName(t *testing.T) {
+	tests := []struct {
+		OldName, NewName string
+		Expected         string
+	}{
+		{"old.txt", "new.txt", "old.txt => new.txt"},
+		{"dir/old.txt", "dir/new.txt", "dir/{old.txt => new.txt}"},
+		{"old/file.txt", "new/file.txt", "{old => new}/file.txt"},
+		{"a/b/file.txt", "a/c/file.txt", "a/{b => c}/file.txt"},
+		{"a/file.txt", "a/b/file.txt", "a/{ => b}/file.txt"},
+		{"a/b/file.txt", "a/file.txt", "a/{b => }/file.txt"},
+	}
+
+	for _, test := range tests {
+		if name := statRenameName(test.OldName, test.NewName); name != test.Expected {
+			t.Errorf("incorrect name for %q => %q: expected %q, actual %q", test.OldName, test.NewName, test.Expected, name)
+		}
+	}
+}
+
+func TestPatchStatSummary(t *testing.T) {
+	tests := map[string]struct {
+		Stat     PatchStat
+		Expected string
+	}{
+		"empty": {
+			Stat:     PatchStat{},
+			Expected: "0 files changed",
+		},
+		"singular": {
+			Stat:     PatchStat{Files: make([]FileStat, 1), LinesAdded: 1, LinesDeleted: 1},
+			Expected: "1 file changed, 1 insertion(+), 1 deletion(-)",
+		},
+		"insertionsOnly": {
+			Stat:     PatchStat{Files: make([]FileStat, 2), LinesAdded: 5},
+			Expected: "2 files changed, 5 insertions(+)",
+		},
+		"deletionsOnly": {
+			Stat:     PatchStat{Files: make([]FileStat, 2), LinesDeleted: 5},
+			Expected: "2 files changed, 5 deletions(-)",
+		},
+		"noLines": {
+			Stat:     PatchStat{Files: make([]FileStat, 1)},
+			Expected: "1 file changed, 0 insertions(+), 0 deletions(-)",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if s := test.Stat.Summary(); s != test.Expected {
+				t.Errorf("incorrect summary\nexpected: %q\n  actual: %q", test.Expected, s)
+			}
+		})
+	}
+}
+
+func TestPatchStatString(t *testing.T) {
+	tests := map[string]struct {
+		Files    []FileStat
+		Expected string
+	}{
+		"simple": {
+			Files: []FileStat{
+				{Name: "bin.dat", IsBinary: true},
+				{Name: "dir/{old.txt => new.txt}"},
+				{Name: "file.txt", LinesAdded: 2, LinesDeleted: 1},
+			},
+			Expected: `
+ bin.dat                  | Bin
+ dir/{old.txt => new.txt} |   0
+ file.txt                 |   3 ++-
+ 3 files changed, 2 insertions(+), 1 deletion(-)
+`,
+		},
+		"scaled": {
+			Files: []FileStat{
+				{Name: "lib/{name.txt => renamed.txt}"},
+				{Name: "small.txt", LinesAdded: 1, LinesDeleted: 2},
+				{Name: "src/new/big.txt", LinesAdded: 200},
+				{Name: "src/old/big.txt", LinesDeleted: 200},
+			},
+			Expected: `
+ lib/{name.txt => renamed.txt} |   0
+ small.txt                     |   3 +-
+ src/new/big.txt               | 200 ` + strings.Repeat("+", 42) + `
+ src/old/big.txt               | 200 ` + strings.Repeat("-", 42) + `
+ 4 files changed, 201 insertions(+), 202 deletions(-)
+`,
+		},
+		"truncated": {
+			Files: []FileStat{
+				{Name: strings.Repeat("long/", 16) + "file.txt", LinesAdded: 100},
+			},
+			Expected: `
+ .../` + strings.Repeat("long/", 7) + `file.txt    | 100 ` + strings.Repeat("+", 21) + `
+ 1 file changed, 100 insertions(+)
+`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			stat := PatchStat{Files: test.Files}
+			for _, fs := range test.Files {
+				stat.LinesAdded += fs.LinesAdded
+				stat.LinesDeleted += fs.LinesDeleted
+			}
+
+			expected := strings.TrimPrefix(test.Expected, "\n")
+			if s := stat.String(); s != expected {
+				t.Errorf("incorrect stat\nexpected:\n%s\n  actual:\n%s", expected, s)
+			}
+		})
+	}
+}
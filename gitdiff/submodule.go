@@ -0,0 +1,49 @@
+package gitdiff
+
+import (
+	"os"
+	"strings"
+)
+
+const (
+	submoduleMode   = os.FileMode(0160000)
+	subprojectLine  = "Subproject commit "
+	subprojectDirty = "-dirty"
+)
+
+// setSubmoduleCommits sets IsSubmodule if either mode of f is the submodule
+// mode and then sets the old and new submodule commits from the "Subproject
+// commit" lines in the text fragments of f.
+func setSubmoduleCommits(f *File) {
+	if f.OldMode != submoduleMode && f.NewMode != submoduleMode {
+		return
+	}
+	f.IsSubmodule = true
+
+	for _, frag := range f.TextFragments {
+		for _, line := range frag.Lines {
+			commit, ok := parseSubprojectLine(line.Line)
+			if !ok {
+				continue
+			}
+			if line.Old() {
+				f.OldSubmoduleCommit = commit
+			}
+			if line.New() {
+				f.NewSubmoduleCommit = commit
+			}
+		}
+	}
+}
+
+// parseSubprojectLine returns the commit ID from a line like "Subproject
+// commit <id>". Git adds a "-dirty" suffix to the ID if the submodule has
+// uncommitted changes; the suffix is not part of the result.
+func parseSubprojectLine(line string) (string, bool) {
+	if !strings.HasPrefix(line, subprojectLine) {
+		return "", false
+	}
+	commit := strings.TrimSpace(line[len(subprojectLine):])
+	commit = strings.TrimSuffix(commit, subprojectDirty)
+	return commit, commit != ""
+}
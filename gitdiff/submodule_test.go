@@ -0,0 +1,102 @@
+package gitdiff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseSubmodule(t *testing.T) {
+	tests := map[string]struct {
+		Input       string
+		IsSubmodule bool
+		OldCommit   string
+		NewCommit   string
+	}{
+		"update": {
+			Input: `diff --git a/lib/dep b/lib/dep
+index 5f3e2a1..9c4b7d0 160000
+--- a/lib/dep
++++ b/lib/dep
+@@ -1 +1 @@
+-Subproject commit 5f3e2a1b8c1e2d3f4a5b6c7d8e9f0a1b2c3d4e5f
++Subproject commit 9c4b7d0e1f2a3b4c5d6e7f8091a2b3c4d5e6f708
+`,
+			IsSubmodule: true,
+			OldCommit:   "5f3e2a1b8c1e2d3f4a5b6c7d8e9f0a1b2c3d4e5f",
+			NewCommit:   "9c4b7d0e1f2a3b4c5d6e7f8091a2b3c4d5e6f708",
+		},
+		"add": {
+			Input: `diff --git a/lib/dep b/lib/dep
+new file mode 160000
+index 0000000..9c4b7d0
+--- /dev/null
++++ b/lib/dep
+@@ -0,0 +1 @@
++Subproject commit 9c4b7d0e1f2a3b4c5d6e7f8091a2b3c4d5e6f708
+`,
+			IsSubmodule: true,
+			NewCommit:   "9c4b7d0e1f2a3b4c5d6e7f8091a2b3c4d5e6f708",
+		},
+		"remove": {
+			Input: `diff --git a/lib/dep b/lib/dep
+deleted file mode 160000
+index 5f3e2a1..0000000
+--- a/lib/dep
++++ /dev/null
+@@ -1 +0,0 @@
+-Subproject commit 5f3e2a1b8c1e2d3f4a5b6c7d8e9f0a1b2c3d4e5f
+`,
+			IsSubmodule: true,
+			OldCommit:   "5f3e2a1b8c1e2d3f4a5b6c7d8e9f0a1b2c3d4e5f",
+		},
+		"dirty": {
+			Input: `diff --git a/lib/dep b/lib/dep
+--- a/lib/dep
++++ b/lib/dep
+@@ -1 +1 @@
+-Subproject commit 5f3e2a1b8c1e2d3f4a5b6c7d8e9f0a1b2c3d4e5f
++Subproject commit 5f3e2a1b8c1e2d3f4a5b6c7d8e9f0a1b2c3d4e5f-dirty
+`,
+		},
+		"dirtySubmoduleMode": {
+			Input: `diff --git a/lib/dep b/lib/dep
+index 5f3e2a1..5f3e2a1 160000
+--- a/lib/dep
++++ b/lib/dep
+@@ -1 +1 @@
+-Subproject commit 5f3e2a1b8c1e2d3f4a5b6c7d8e9f0a1b2c3d4e5f
++Subproject commit 5f3e2a1b8c1e2d3f4a5b6c7d8e9f0a1b2c3d4e5f-dirty
+`,
+			IsSubmodule: true,
+			OldCommit:   "5f3e2a1b8c1e2d3f4a5b6c7d8e9f0a1b2c3d4e5f",
+			NewCommit:   "5f3e2a1b8c1e2d3f4a5b6c7d8e9f0a1b2c3d4e5f",
+		},
+		"regularFile": {
+			Input: `diff --git a/notes.txt b/notes.txt
+index 5f3e2a1..9c4b7d0 100644
+--- a/notes.txt
++++ b/notes.txt
+@@ -1 +1 @@
+-Subproject commit 5f3e2a1b8c1e2d3f4a5b6c7d8e9f0a1b2c3d4e5f
++Subproject commit 9c4b7d0e1f2a3b4c5d6e7f8091a2b3c4d5e6f708
+`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			files, _, err := Parse(strings.NewReader(test.Input))
+			if err != nil {
+				t.Fatalf("unexpected error parsing patch: %v", err)
+			}
+			if len(files) != 1 {
+				t.Fatalf("expected 1 file, but found %d", len(files))
+			}
+
+			f := files[0]
+			assertEqual(t, test.IsSubmodule, f.IsSubmodule, "IsSubmodule")
+			assertEqual(t, test.OldCommit, f.OldSubmoduleCommit, "OldSubmoduleCommit")
+			assertEqual(t, test.NewCommit, f.NewSubmoduleCommit, "NewSubmoduleCommit")
+		})
+	}
+}
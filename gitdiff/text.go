@@ -19,6 +19,9 @@ func (p *parser) ParseTextFragments(f *File) (n int, err error) {
 		if frag == nil {
 			return n, nil
 		}
+		if err := p.checkFragmentLimit(n); err != nil {
+			return n, err
+		}
 
 		if f.IsNew && frag.OldLines > 0 {
 			return n, p.Errorf(-1, "new file depends on old contents")
@@ -37,16 +40,22 @@ func (p *parser) ParseTextFragments(f *File) (n int, err error) {
 }
 
 func (p *parser) ParseTextFragmentHeader() (*TextFragment, error) {
-	const (
-		startMark = "@@ -"
-		endMark   = " @@"
-	)
+	line := p.Line(0)
 
-	if !strings.HasPrefix(p.Line(0), startMark) {
+	// regular fragments start with "@@", fragments in combined diffs start
+	// with one more "@" than the number of parents
+	n := 0
+	for n < len(line) && line[n] == '@' {
+		n++
+	}
+	if n < 2 || !strings.HasPrefix(line[n:], " -") {
 		return nil, nil
 	}
 
-	parts := strings.SplitAfterN(p.Line(0), endMark, 2)
+	startMark := line[:n] + " "
+	endMark := " " + line[:n]
+
+	parts := strings.SplitAfterN(line, endMark, 2)
 	if len(parts) < 2 {
 		return nil, p.Errorf(0, "invalid fragment header")
 	}
@@ -55,17 +64,35 @@ func (p *parser) ParseTextFragmentHeader() (*TextFragment, error) {
 	f.Comment = strings.TrimSpace(parts[1])
 
 	header := parts[0][len(startMark) : len(parts[0])-len(endMark)]
-	ranges := strings.Split(header, " +")
-	if len(ranges) != 2 {
+	ranges := strings.Split(header, " ")
+	if len(ranges) != n {
 		return nil, p.Errorf(0, "invalid fragment header")
 	}
 
-	var err error
-	if f.OldPosition, f.OldLines, err = parseRange(ranges[0]); err != nil {
-		return nil, p.Errorf(0, "invalid fragment header: %v", err)
-	}
-	if f.NewPosition, f.NewLines, err = parseRange(ranges[1]); err != nil {
-		return nil, p.Errorf(0, "invalid fragment header: %v", err)
+	for i, r := range ranges {
+		sign := byte('-')
+		if i == len(ranges)-1 {
+			sign = '+'
+		}
+		if len(r) == 0 || r[0] != sign {
+			return nil, p.Errorf(0, "invalid fragment header")
+		}
+
+		start, lines, err := parseRange(r[1:])
+		if err != nil {
+			return nil, p.Errorf(0, "invalid fragment header: %v", err)
+		}
+
+		if i == len(ranges)-1 {
+			f.NewPosition, f.NewLines = start, lines
+			continue
+		}
+		if i == 0 {
+			f.OldPosition, f.OldLines = start, lines
+		}
+		if n > 2 {
+			f.ParentRanges = append(f.ParentRanges, Range{Position: start, Lines: lines})
+		}
 	}
 
 	if err := p.Next(); err != nil && err != io.EOF {
@@ -74,20 +101,56 @@ func (p *parser) ParseTextFragmentHeader() (*TextFragment, error) {
 	return f, nil
 }
 
+// MiscountError is returned when parsing a text fragment if the number of
+// old or new lines in the fragment does not match the counts in the fragment
+// header. The actual counts are lower than the reported counts if the input
+// ends before the end of the fragment.
+type MiscountError struct {
+	// Line is the line number of the fragment header in the input
+	Line int64
+
+	// Parent is set for fragments in combined diffs. It is the number of the
+	// first parent with a wrong line count, starting at 1, and the old counts
+	// are for that parent. If only the new count is wrong, Parent is 1.
+	Parent int
+
+	ReportedOld int64
+	ReportedNew int64
+	ActualOld   int64
+	ActualNew   int64
+}
+
+func (e *MiscountError) Error() string {
+	if e.Parent > 0 {
+		return fmt.Sprintf("gitdiff: line %d: fragment header miscounts lines: %+d parent %d, %+d new",
+			e.Line, e.ActualOld-e.ReportedOld, e.Parent, e.ActualNew-e.ReportedNew)
+	}
+	return fmt.Sprintf("gitdiff: line %d: fragment header miscounts lines: %+d old, %+d new",
+		e.Line, e.ActualOld-e.ReportedOld, e.ActualNew-e.ReportedNew)
+}
+
 func (p *parser) ParseTextChunk(frag *TextFragment) error {
 	if p.Line(0) == "" {
 		return p.Errorf(0, "no content following fragment header")
 	}
+	if len(frag.ParentRanges) > 0 {
+		return p.parseCombinedTextChunk(frag)
+	}
+
+	// the parser starts on the line after the fragment header
+	hdrLine := p.lineno - 1
 
 	oldLines, newLines := frag.OldLines, frag.NewLines
 	for oldLines > 0 || newLines > 0 {
-		line := p.Line(0)
+		line := p.ContentLine(0)
 		op, data := line[0], line[1:]
+		bare := p.Line(0) == "\n"
+		if bare {
+			// newer GNU diff versions create empty context lines
+			op, data = ' ', line
+		}
 
 		switch op {
-		case '\n':
-			data = "\n"
-			fallthrough // newer GNU diff versions create empty context lines
 		case ' ':
 			oldLines--
 			newLines--
@@ -96,17 +159,17 @@ func (p *parser) ParseTextChunk(frag *TextFragment) error {
 			} else {
 				frag.TrailingContext++
 			}
-			frag.Lines = append(frag.Lines, Line{OpContext, data})
+			p.appendLine(frag, Line{Op: OpContext, Line: data, BareContext: bare && p.opts.bareContextLines})
 		case '-':
 			oldLines--
 			frag.LinesDeleted++
 			frag.TrailingContext = 0
-			frag.Lines = append(frag.Lines, Line{OpDelete, data})
+			p.appendLine(frag, Line{Op: OpDelete, Line: data})
 		case '+':
 			newLines--
 			frag.LinesAdded++
 			frag.TrailingContext = 0
-			frag.Lines = append(frag.Lines, Line{OpAdd, data})
+			p.appendLine(frag, Line{Op: OpAdd, Line: data})
 		case '\\':
 			// this may appear in middle of fragment if it's for a deleted line
 			if isNoNewlineMarker(line) {
@@ -132,12 +195,18 @@ func (p *parser) ParseTextChunk(frag *TextFragment) error {
 	}
 
 	if oldLines != 0 || newLines != 0 {
-		hdr := max(frag.OldLines-oldLines, frag.NewLines-newLines) + 1
-		return p.Errorf(-hdr, "fragment header miscounts lines: %+d old, %+d new", -oldLines, -newLines)
+		return &MiscountError{
+			Line:        hdrLine,
+			ReportedOld: frag.OldLines,
+			ReportedNew: frag.NewLines,
+			ActualOld:   frag.OldLines - oldLines,
+			ActualNew:   frag.NewLines - newLines,
+		}
 	}
 	if frag.LinesAdded == 0 && frag.LinesDeleted == 0 {
 		return p.Errorf(0, "fragment contains no changes")
 	}
+	setLineNumbers(frag)
 
 	// check for a final "no newline" marker since it is not included in the
 	// counters used to stop the loop above
@@ -151,31 +220,184 @@ func (p *parser) ParseTextChunk(frag *TextFragment) error {
 	return nil
 }
 
+// parseCombinedTextChunk parses the content of a fragment from a combined
+// diff. Each line starts with one operation character for each parent.
+func (p *parser) parseCombinedTextChunk(frag *TextFragment) error {
+	parents := len(frag.ParentRanges)
+
+	// the parser starts on the line after the fragment header
+	hdrLine := p.lineno - 1
+
+	oldLines := make([]int64, parents)
+	for i, r := range frag.ParentRanges {
+		oldLines[i] = r.Lines
+	}
+	newLines := frag.NewLines
+
+	remaining := func() bool {
+		for _, n := range oldLines {
+			if n > 0 {
+				return true
+			}
+		}
+		return newLines > 0
+	}
+
+	for remaining() {
+		line := p.ContentLine(0)
+		if isNoNewlineMarker(line) {
+			removeLastNewline(frag)
+		} else {
+			if len(line) <= parents {
+				return p.Errorf(0, "invalid line operation: %q", line)
+			}
+
+			fl := Line{Op: OpContext, Line: line[parents:], ParentOps: make([]LineOp, parents)}
+			for i := 0; i < parents; i++ {
+				switch line[i] {
+				case ' ':
+					fl.ParentOps[i] = OpContext
+				case '-':
+					fl.ParentOps[i] = OpDelete
+				case '+':
+					fl.ParentOps[i] = OpAdd
+				default:
+					return p.Errorf(0, "invalid line operation: %q", line[i])
+				}
+				if fl.ParentOps[i] != OpContext {
+					if fl.Op != OpContext && fl.Op != fl.ParentOps[i] {
+						return p.Errorf(0, "invalid line operation: %q", line[:parents])
+					}
+					fl.Op = fl.ParentOps[i]
+				}
+			}
+
+			for i := range oldLines {
+				if fl.inParent(i) {
+					oldLines[i]--
+				}
+			}
+			if fl.New() {
+				newLines--
+			}
+
+			switch fl.Op {
+			case OpContext:
+				if frag.LinesAdded == 0 && frag.LinesDeleted == 0 {
+					frag.LeadingContext++
+				} else {
+					frag.TrailingContext++
+				}
+			case OpDelete:
+				frag.LinesDeleted++
+				frag.TrailingContext = 0
+			case OpAdd:
+				frag.LinesAdded++
+				frag.TrailingContext = 0
+			}
+			p.appendLine(frag, fl)
+		}
+
+		if err := p.Next(); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+	}
+
+	parent := -1
+	for i, n := range oldLines {
+		if n != 0 {
+			parent = i
+			break
+		}
+	}
+	if parent >= 0 || newLines != 0 {
+		if parent < 0 {
+			parent = 0
+		}
+		return &MiscountError{
+			Line:        hdrLine,
+			Parent:      parent + 1,
+			ReportedOld: frag.ParentRanges[parent].Lines,
+			ReportedNew: frag.NewLines,
+			ActualOld:   frag.ParentRanges[parent].Lines - oldLines[parent],
+			ActualNew:   frag.NewLines - newLines,
+		}
+	}
+	if frag.LinesAdded == 0 && frag.LinesDeleted == 0 {
+		return p.Errorf(0, "fragment contains no changes")
+	}
+	setLineNumbers(frag)
+
+	if isNoNewlineMarker(p.Line(0)) {
+		removeLastNewline(frag)
+		if err := p.Next(); err != nil && err != io.EOF {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// appendLine adds line to frag unless the parser only keeps headers.
+func (p *parser) appendLine(frag *TextFragment, line Line) {
+	if !p.opts.headersOnly {
+		frag.Lines = append(frag.Lines, line)
+	}
+}
+
+// setLineNumbers sets the old and new line numbers of each line in frag based
+// on the positions of the fragment.
+func setLineNumbers(frag *TextFragment) {
+	oldLineNo, newLineNo := max(frag.OldPosition, 1), max(frag.NewPosition, 1)
+	for i := range frag.Lines {
+		line := &frag.Lines[i]
+		line.OldLineNo, line.NewLineNo = 0, 0
+
+		inOld := line.Old()
+		if len(line.ParentOps) > 0 {
+			inOld = line.inParent(0)
+		}
+		if inOld {
+			line.OldLineNo = oldLineNo
+			oldLineNo++
+		}
+		if line.New() {
+			line.NewLineNo = newLineNo
+			newLineNo++
+		}
+	}
+}
+
 func isNoNewlineMarker(s string) bool {
 	// test for "\ No newline at end of file" by prefix because the text
-	// changes by locale (git claims all versions are at least 12 chars)
-	return len(s) >= 12 && s[:2] == "\\ "
+	// changes by locale and some tools shorten it or omit it entirely. Lines
+	// in a fragment always start with an operation character, so content
+	// lines never match.
+	s = strings.TrimSuffix(normalizeEOL(s), "\n")
+	return s == "\\" || strings.HasPrefix(s, "\\ ")
 }
 
 func removeLastNewline(frag *TextFragment) {
 	if len(frag.Lines) > 0 {
 		last := &frag.Lines[len(frag.Lines)-1]
 		last.Line = strings.TrimSuffix(last.Line, "\n")
+		last.NoNewlineAtEOF = true
 	}
 }
 
 func parseRange(s string) (start int64, end int64, err error) {
 	parts := strings.SplitN(s, ",", 2)
 
-	if start, err = strconv.ParseInt(parts[0], 10, 64); err != nil {
-		nerr := err.(*strconv.NumError)
-		return 0, 0, fmt.Errorf("bad start of range: %s: %v", parts[0], nerr.Err)
+	if start, err = parseRangeNumber(parts[0]); err != nil {
+		return 0, 0, fmt.Errorf("bad start of range: %s: %v", parts[0], err)
 	}
 
 	if len(parts) > 1 {
-		if end, err = strconv.ParseInt(parts[1], 10, 64); err != nil {
-			nerr := err.(*strconv.NumError)
-			return 0, 0, fmt.Errorf("bad end of range: %s: %v", parts[1], nerr.Err)
+		if end, err = parseRangeNumber(parts[1]); err != nil {
+			return 0, 0, fmt.Errorf("bad end of range: %s: %v", parts[1], err)
 		}
 	} else {
 		end = 1
@@ -184,6 +406,16 @@ func parseRange(s string) (start int64, end int64, err error) {
 	return
 }
 
+// parseRangeNumber parses a position or line count in a fragment header.
+// Unlike strconv.ParseInt, it rejects signs, so negative values are errors.
+func parseRangeNumber(s string) (int64, error) {
+	n, err := strconv.ParseUint(s, 10, 63)
+	if err != nil {
+		return 0, err.(*strconv.NumError).Err
+	}
+	return int64(n), nil
+}
+
 func max(a, b int64) int64 {
 	if a > b {
 		return a
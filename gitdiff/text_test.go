@@ -1,8 +1,11 @@
 package gitdiff
 
 import (
+	"errors"
+	"fmt"
 	"io"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -40,6 +43,82 @@ func TestParseTextFragmentHeader(t *testing.T) {
 				NewLines:    9,
 			},
 		},
+		"commentInternalSpacing": {
+			Input: "@@ -21,5 +28,9 @@   func  test(n int,\tm int) {  \n",
+			Output: &TextFragment{
+				Comment:     "func  test(n int,\tm int) {",
+				OldPosition: 21,
+				OldLines:    5,
+				NewPosition: 28,
+				NewLines:    9,
+			},
+		},
+		"combined": {
+			Input: "@@@ -1,5 -1,4 +1,6 @@@ func test(n int) {\n",
+			Output: &TextFragment{
+				Comment:     "func test(n int) {",
+				OldPosition: 1,
+				OldLines:    5,
+				NewPosition: 1,
+				NewLines:    6,
+				ParentRanges: []Range{
+					{Position: 1, Lines: 5},
+					{Position: 1, Lines: 4},
+				},
+			},
+		},
+		"newFileOmittedCount": {
+			Input: "@@ -0,0 +1 @@\n",
+			Output: &TextFragment{
+				OldPosition: 0,
+				OldLines:    0,
+				NewPosition: 1,
+				NewLines:    1,
+			},
+		},
+		"deletedFileOmittedCount": {
+			Input: "@@ -1 +0,0 @@\n",
+			Output: &TextFragment{
+				OldPosition: 1,
+				OldLines:    1,
+				NewPosition: 0,
+				NewLines:    0,
+			},
+		},
+		"zeroCounts": {
+			Input: "@@ -1,0 +0,0 @@\n",
+			Output: &TextFragment{
+				OldPosition: 1,
+				OldLines:    0,
+				NewPosition: 0,
+				NewLines:    0,
+			},
+		},
+		"zeroPositionOmittedCount": {
+			Input: "@@ -0 +0 @@\n",
+			Output: &TextFragment{
+				OldPosition: 0,
+				OldLines:    1,
+				NewPosition: 0,
+				NewLines:    1,
+			},
+		},
+		"negativeCount": {
+			Input: "@@ -1,-1 +1 @@\n",
+			Err:   true,
+		},
+		"signedPosition": {
+			Input: "@@ -+1,2 +1,2 @@\n",
+			Err:   true,
+		},
+		"emptyCount": {
+			Input: "@@ -1, +1 @@\n",
+			Err:   true,
+		},
+		"combinedWrongRangeCount": {
+			Input: "@@@ -1,5 +1,6 @@@\n",
+			Err:   true,
+		},
 		"incomplete": {
 			Input: "@@ -12,3 +2\n",
 			Err:   true,
@@ -94,10 +173,10 @@ func TestParseTextChunk(t *testing.T) {
 				OldLines: 2,
 				NewLines: 4,
 				Lines: []Line{
-					{OpContext, "context line\n"},
-					{OpAdd, "new line 1\n"},
-					{OpAdd, "new line 2\n"},
-					{OpContext, "context line\n"},
+					{Op: OpContext, Line: "context line\n", OldLineNo: 1, NewLineNo: 1},
+					{Op: OpAdd, Line: "new line 1\n", NewLineNo: 2},
+					{Op: OpAdd, Line: "new line 2\n", NewLineNo: 3},
+					{Op: OpContext, Line: "context line\n", OldLineNo: 2, NewLineNo: 4},
 				},
 				LinesAdded:      2,
 				LeadingContext:  1,
@@ -118,10 +197,10 @@ func TestParseTextChunk(t *testing.T) {
 				OldLines: 4,
 				NewLines: 2,
 				Lines: []Line{
-					{OpContext, "context line\n"},
-					{OpDelete, "old line 1\n"},
-					{OpDelete, "old line 2\n"},
-					{OpContext, "context line\n"},
+					{Op: OpContext, Line: "context line\n", OldLineNo: 1, NewLineNo: 1},
+					{Op: OpDelete, Line: "old line 1\n", OldLineNo: 2},
+					{Op: OpDelete, Line: "old line 2\n", OldLineNo: 3},
+					{Op: OpContext, Line: "context line\n", OldLineNo: 4, NewLineNo: 2},
 				},
 				LinesDeleted:    2,
 				LeadingContext:  1,
@@ -142,10 +221,10 @@ func TestParseTextChunk(t *testing.T) {
 				OldLines: 3,
 				NewLines: 3,
 				Lines: []Line{
-					{OpContext, "context line\n"},
-					{OpDelete, "old line 1\n"},
-					{OpAdd, "new line 1\n"},
-					{OpContext, "context line\n"},
+					{Op: OpContext, Line: "context line\n", OldLineNo: 1, NewLineNo: 1},
+					{Op: OpDelete, Line: "old line 1\n", OldLineNo: 2},
+					{Op: OpAdd, Line: "new line 1\n", NewLineNo: 2},
+					{Op: OpContext, Line: "context line\n", OldLineNo: 3, NewLineNo: 3},
 				},
 				LinesDeleted:    1,
 				LinesAdded:      1,
@@ -168,11 +247,11 @@ func TestParseTextChunk(t *testing.T) {
 				OldLines: 4,
 				NewLines: 4,
 				Lines: []Line{
-					{OpContext, "context line\n"},
-					{OpDelete, "old line 1\n"},
-					{OpContext, "context line\n"},
-					{OpAdd, "new line 1\n"},
-					{OpContext, "context line\n"},
+					{Op: OpContext, Line: "context line\n", OldLineNo: 1, NewLineNo: 1},
+					{Op: OpDelete, Line: "old line 1\n", OldLineNo: 2},
+					{Op: OpContext, Line: "context line\n", OldLineNo: 3, NewLineNo: 2},
+					{Op: OpAdd, Line: "new line 1\n", NewLineNo: 3},
+					{Op: OpContext, Line: "context line\n", OldLineNo: 4, NewLineNo: 4},
 				},
 				LinesDeleted:    1,
 				LinesAdded:      1,
@@ -194,9 +273,9 @@ func TestParseTextChunk(t *testing.T) {
 				OldLines: 2,
 				NewLines: 2,
 				Lines: []Line{
-					{OpContext, "context line\n"},
-					{OpDelete, "old line 1\n"},
-					{OpAdd, "new line 1"},
+					{Op: OpContext, Line: "context line\n", OldLineNo: 1, NewLineNo: 1},
+					{Op: OpDelete, Line: "old line 1\n", OldLineNo: 2},
+					{Op: OpAdd, Line: "new line 1", NewLineNo: 2, NoNewlineAtEOF: true},
 				},
 				LinesDeleted:   1,
 				LinesAdded:     1,
@@ -217,15 +296,123 @@ func TestParseTextChunk(t *testing.T) {
 				OldLines: 2,
 				NewLines: 2,
 				Lines: []Line{
-					{OpContext, "context line\n"},
-					{OpDelete, "old line 1"},
-					{OpAdd, "new line 1\n"},
+					{Op: OpContext, Line: "context line\n", OldLineNo: 1, NewLineNo: 1},
+					{Op: OpDelete, Line: "old line 1", OldLineNo: 2, NoNewlineAtEOF: true},
+					{Op: OpAdd, Line: "new line 1\n", NewLineNo: 2},
+				},
+				LinesDeleted:   1,
+				LinesAdded:     1,
+				LeadingContext: 1,
+			},
+		},
+		"bothNoFinalNewline": {
+			Input: ` context line
+-old line 1
+\ No newline at end of file
++new line 1
+\ No newline at end of file
+`,
+			Fragment: TextFragment{
+				OldLines: 2,
+				NewLines: 2,
+			},
+			Output: &TextFragment{
+				OldLines: 2,
+				NewLines: 2,
+				Lines: []Line{
+					{Op: OpContext, Line: "context line\n", OldLineNo: 1, NewLineNo: 1},
+					{Op: OpDelete, Line: "old line 1", OldLineNo: 2, NoNewlineAtEOF: true},
+					{Op: OpAdd, Line: "new line 1", NewLineNo: 2, NoNewlineAtEOF: true},
 				},
 				LinesDeleted:   1,
 				LinesAdded:     1,
 				LeadingContext: 1,
 			},
 		},
+		"localizedNoNewlineMarker": {
+			Input: `-old line 1
+\ Kein Zeilenumbruch am Dateiende.
++new line 1
+\ Kein Zeilenumbruch am Dateiende.
+`,
+			Fragment: TextFragment{
+				OldLines: 1,
+				NewLines: 1,
+			},
+			Output: &TextFragment{
+				OldLines: 1,
+				NewLines: 1,
+				Lines: []Line{
+					{Op: OpDelete, Line: "old line 1", OldLineNo: 1, NoNewlineAtEOF: true},
+					{Op: OpAdd, Line: "new line 1", NewLineNo: 1, NoNewlineAtEOF: true},
+				},
+				LinesDeleted: 1,
+				LinesAdded:   1,
+			},
+		},
+		"shortNoNewlineMarker": {
+			Input: "-old line 1\n\\ \n+new line 1\n\\\n",
+			Fragment: TextFragment{
+				OldLines: 1,
+				NewLines: 1,
+			},
+			Output: &TextFragment{
+				OldLines: 1,
+				NewLines: 1,
+				Lines: []Line{
+					{Op: OpDelete, Line: "old line 1", OldLineNo: 1, NoNewlineAtEOF: true},
+					{Op: OpAdd, Line: "new line 1", NewLineNo: 1, NoNewlineAtEOF: true},
+				},
+				LinesDeleted: 1,
+				LinesAdded:   1,
+			},
+		},
+		"contentStartingWithBackslash": {
+			Input: ` \ context line
++\ new line
+`,
+			Fragment: TextFragment{
+				OldLines: 1,
+				NewLines: 2,
+			},
+			Output: &TextFragment{
+				OldLines: 1,
+				NewLines: 2,
+				Lines: []Line{
+					{Op: OpContext, Line: "\\ context line\n", OldLineNo: 1, NewLineNo: 1},
+					{Op: OpAdd, Line: "\\ new line\n", NewLineNo: 2},
+				},
+				LinesAdded:     1,
+				LeadingContext: 1,
+			},
+		},
+		"bothNoFinalNewlineMultipleLines": {
+			Input: `-old line 1
+-old line 2
+\ No newline at end of file
++new line 1
++new line 2
++new line 3
+\ No newline at end of file
+`,
+			Fragment: TextFragment{
+				OldLines: 2,
+				NewLines: 3,
+			},
+			Output: &TextFragment{
+				OldLines: 2,
+				NewLines: 3,
+				Lines: []Line{
+					{Op: OpDelete, Line: "old line 1\n", OldLineNo: 1},
+					{Op: OpDelete, Line: "old line 2", OldLineNo: 2, NoNewlineAtEOF: true},
+					{Op: OpAdd, Line: "new line 1\n", NewLineNo: 1},
+					{Op: OpAdd, Line: "new line 2\n", NewLineNo: 2},
+					{Op: OpAdd, Line: "new line 3", NewLineNo: 3, NoNewlineAtEOF: true},
+				},
+				LinesDeleted: 2,
+				LinesAdded:   3,
+			},
+		},
 		"addAll": {
 			Input: `+new line 1
 +new line 2
@@ -239,9 +426,9 @@ func TestParseTextChunk(t *testing.T) {
 				OldLines: 0,
 				NewLines: 3,
 				Lines: []Line{
-					{OpAdd, "new line 1\n"},
-					{OpAdd, "new line 2\n"},
-					{OpAdd, "new line 3\n"},
+					{Op: OpAdd, Line: "new line 1\n", NewLineNo: 1},
+					{Op: OpAdd, Line: "new line 2\n", NewLineNo: 2},
+					{Op: OpAdd, Line: "new line 3\n", NewLineNo: 3},
 				},
 				LinesAdded: 3,
 			},
@@ -259,9 +446,9 @@ func TestParseTextChunk(t *testing.T) {
 				OldLines: 3,
 				NewLines: 0,
 				Lines: []Line{
-					{OpDelete, "old line 1\n"},
-					{OpDelete, "old line 2\n"},
-					{OpDelete, "old line 3\n"},
+					{Op: OpDelete, Line: "old line 1\n", OldLineNo: 1},
+					{Op: OpDelete, Line: "old line 2\n", OldLineNo: 2},
+					{Op: OpDelete, Line: "old line 3\n", OldLineNo: 3},
 				},
 				LinesDeleted: 3,
 			},
@@ -280,10 +467,10 @@ func TestParseTextChunk(t *testing.T) {
 				OldLines: 3,
 				NewLines: 4,
 				Lines: []Line{
-					{OpContext, "context line\n"},
-					{OpContext, "\n"},
-					{OpAdd, "new line\n"},
-					{OpContext, "context line\n"},
+					{Op: OpContext, Line: "context line\n", OldLineNo: 1, NewLineNo: 1},
+					{Op: OpContext, Line: "\n", OldLineNo: 2, NewLineNo: 2},
+					{Op: OpAdd, Line: "new line\n", NewLineNo: 3},
+					{Op: OpContext, Line: "context line\n", OldLineNo: 3, NewLineNo: 4},
 				},
 				LinesAdded:      1,
 				LeadingContext:  2,
@@ -327,6 +514,53 @@ func TestParseTextChunk(t *testing.T) {
 			},
 			Err: true,
 		},
+		"combined": {
+			Input: `  line 1
+- line 2
+ -line 2 side
+++line 2 merged
+ +line 3 main
+`,
+			Fragment: TextFragment{
+				OldLines: 3,
+				NewLines: 3,
+				ParentRanges: []Range{
+					{Position: 1, Lines: 3},
+					{Position: 1, Lines: 2},
+				},
+			},
+			Output: &TextFragment{
+				OldLines: 3,
+				NewLines: 3,
+				ParentRanges: []Range{
+					{Position: 1, Lines: 3},
+					{Position: 1, Lines: 2},
+				},
+				Lines: []Line{
+					{Op: OpContext, Line: "line 1\n", ParentOps: []LineOp{OpContext, OpContext}, OldLineNo: 1, NewLineNo: 1},
+					{Op: OpDelete, Line: "line 2\n", ParentOps: []LineOp{OpDelete, OpContext}, OldLineNo: 2},
+					{Op: OpDelete, Line: "line 2 side\n", ParentOps: []LineOp{OpContext, OpDelete}},
+					{Op: OpAdd, Line: "line 2 merged\n", ParentOps: []LineOp{OpAdd, OpAdd}, NewLineNo: 2},
+					{Op: OpAdd, Line: "line 3 main\n", ParentOps: []LineOp{OpContext, OpAdd}, OldLineNo: 3, NewLineNo: 3},
+				},
+				LinesAdded:     2,
+				LinesDeleted:   2,
+				LeadingContext: 1,
+			},
+		},
+		"combinedMixedOperations": {
+			Input: `+-line 1
+`,
+			Fragment: TextFragment{
+				OldLines: 0,
+				NewLines: 1,
+				ParentRanges: []Range{
+					{Position: 0, Lines: 0},
+					{Position: 1, Lines: 1},
+				},
+			},
+			Err: true,
+		},
 		"unexpectedNoNewlineMarker": {
 			Input: `\ No newline at end of file`,
 			Fragment: TextFragment{
@@ -360,6 +594,69 @@ func TestParseTextChunk(t *testing.T) {
 	}
 }
 
+func TestParseTextChunkLargeContext(t *testing.T) {
+	// patches created with --function-context can have long runs of context
+	const (
+		leading  = 200
+		inner    = 75
+		trailing = 150
+	)
+
+	var b strings.Builder
+	for i := 0; i < leading; i++ {
+		fmt.Fprintf(&b, " leading %d\n", i)
+	}
+	b.WriteString("-old line 1\n+new line 1\n")
+	for i := 0; i < inner; i++ {
+		fmt.Fprintf(&b, " inner %d\n", i)
+	}
+	b.WriteString("+new line 2\n")
+	for i := 0; i < trailing; i++ {
+		fmt.Fprintf(&b, " trailing %d\n", i)
+	}
+
+	context := int64(leading + inner + trailing)
+	frag := TextFragment{
+		OldPosition: 1,
+		OldLines:    context + 1,
+		NewPosition: 1,
+		NewLines:    context + 2,
+	}
+
+	p := newTestParser(b.String(), true)
+	if err := p.ParseTextChunk(&frag); err != nil {
+		t.Fatalf("unexpected error parsing text chunk: %v", err)
+	}
+
+	if frag.LeadingContext != leading {
+		t.Errorf("incorrect leading context: expected %d, actual %d", leading, frag.LeadingContext)
+	}
+	if frag.TrailingContext != trailing {
+		t.Errorf("incorrect trailing context: expected %d, actual %d", trailing, frag.TrailingContext)
+	}
+	if frag.LinesAdded != 2 || frag.LinesDeleted != 1 {
+		t.Errorf("incorrect changes: expected +2 -1, actual +%d -%d", frag.LinesAdded, frag.LinesDeleted)
+	}
+	if n := len(frag.LeadingContextLines()); n != leading {
+		t.Errorf("incorrect number of leading context lines: expected %d, actual %d", leading, n)
+	}
+	if n := len(frag.TrailingContextLines()); n != trailing {
+		t.Errorf("incorrect number of trailing context lines: expected %d, actual %d", trailing, n)
+	}
+
+	last := frag.Lines[len(frag.Lines)-1]
+	if last.OldLineNo != context+1 || last.NewLineNo != context+2 {
+		t.Errorf("incorrect numbers for last line: expected %d/%d, actual %d/%d", context+1, context+2, last.OldLineNo, last.NewLineNo)
+	}
+
+	if err := frag.Validate(); err != nil {
+		t.Errorf("unexpected error validating fragment: %v", err)
+	}
+	if err := frag.Reverse().Validate(); err != nil {
+		t.Errorf("unexpected error validating reversed fragment: %v", err)
+	}
+}
+
 func TestParseTextFragments(t *testing.T) {
 	tests := map[string]struct {
 		Input string
@@ -392,9 +689,9 @@ func TestParseTextFragments(t *testing.T) {
 					NewPosition: 1,
 					NewLines:    2,
 					Lines: []Line{
-						{OpContext, "context line\n"},
-						{OpDelete, "old line 1\n"},
-						{OpContext, "context line\n"},
+						{Op: OpContext, Line: "context line\n", OldLineNo: 1, NewLineNo: 1},
+						{Op: OpDelete, Line: "old line 1\n", OldLineNo: 2},
+						{Op: OpContext, Line: "context line\n", OldLineNo: 3, NewLineNo: 2},
 					},
 					LinesDeleted:    1,
 					LeadingContext:  1,
@@ -406,10 +703,10 @@ func TestParseTextFragments(t *testing.T) {
 					NewPosition: 7,
 					NewLines:    3,
 					Lines: []Line{
-						{OpContext, "context line\n"},
-						{OpDelete, "old line 2\n"},
-						{OpAdd, "new line 1\n"},
-						{OpContext, "context line\n"},
+						{Op: OpContext, Line: "context line\n", OldLineNo: 8, NewLineNo: 7},
+						{Op: OpDelete, Line: "old line 2\n", OldLineNo: 9},
+						{Op: OpAdd, Line: "new line 1\n", NewLineNo: 8},
+						{Op: OpContext, Line: "context line\n", OldLineNo: 10, NewLineNo: 9},
 					},
 					LinesDeleted:    1,
 					LinesAdded:      1,
@@ -422,11 +719,11 @@ func TestParseTextFragments(t *testing.T) {
 					NewPosition: 14,
 					NewLines:    4,
 					Lines: []Line{
-						{OpContext, "context line\n"},
-						{OpDelete, "old line 3\n"},
-						{OpAdd, "new line 2\n"},
-						{OpAdd, "new line 3\n"},
-						{OpContext, "context line\n"},
+						{Op: OpContext, Line: "context line\n", OldLineNo: 15, NewLineNo: 14},
+						{Op: OpDelete, Line: "old line 3\n", OldLineNo: 16},
+						{Op: OpAdd, Line: "new line 2\n", NewLineNo: 15},
+						{Op: OpAdd, Line: "new line 3\n", NewLineNo: 16},
+						{Op: OpContext, Line: "context line\n", OldLineNo: 17, NewLineNo: 17},
 					},
 					LinesDeleted:    1,
 					LinesAdded:      2,
@@ -435,6 +732,46 @@ func TestParseTextFragments(t *testing.T) {
 				},
 			},
 		},
+		"newFileOmittedCount": {
+			Input: `@@ -0,0 +1 @@
++new line
+`,
+			File: File{
+				IsNew: true,
+			},
+			Fragments: []*TextFragment{
+				{
+					OldPosition: 0,
+					OldLines:    0,
+					NewPosition: 1,
+					NewLines:    1,
+					Lines: []Line{
+						{Op: OpAdd, Line: "new line\n", NewLineNo: 1},
+					},
+					LinesAdded: 1,
+				},
+			},
+		},
+		"deletedFileOmittedCount": {
+			Input: `@@ -1 +0,0 @@
+-old line
+`,
+			File: File{
+				IsDelete: true,
+			},
+			Fragments: []*TextFragment{
+				{
+					OldPosition: 1,
+					OldLines:    1,
+					NewPosition: 0,
+					NewLines:    0,
+					Lines: []Line{
+						{Op: OpDelete, Line: "old line\n", OldLineNo: 1},
+					},
+					LinesDeleted: 1,
+				},
+			},
+		},
 		"badNewFile": {
 			Input: `@@ -1 +1,2 @@
 -old line 1
@@ -486,3 +823,78 @@ func TestParseTextFragments(t *testing.T) {
 		})
 	}
 }
+
+func TestParseTextChunkMiscount(t *testing.T) {
+	tests := map[string]struct {
+		Input    string
+		Expected MiscountError
+	}{
+		"tooFewLines": {
+			Input: `diff --git a/file.txt b/file.txt
+--- a/file.txt
++++ b/file.txt
+@@ -1,3 +1,3 @@
+ line 1
+-line 2
++line 2 changed
+`,
+			Expected: MiscountError{Line: 4, ReportedOld: 3, ReportedNew: 3, ActualOld: 2, ActualNew: 2},
+		},
+		"tooManyDeletes": {
+			Input: `diff --git a/file.txt b/file.txt
+--- a/file.txt
++++ b/file.txt
+@@ -1,2 +1,3 @@
+ line 1
+-line 2
+-line 3
++line 2 changed
++line 3 changed
+`,
+			Expected: MiscountError{Line: 4, ReportedOld: 2, ReportedNew: 3, ActualOld: 3, ActualNew: 3},
+		},
+		"combinedTooFewLines": {
+			Input: `diff --cc file.txt
+index 1c23fcc,3b8c5e1..40a1b33
+--- a/file.txt
++++ b/file.txt
+@@@ -1,3 -1,3 +1,3 @@@
+  line 1
+- line 2
+ -line 2 theirs
+++line 2 merged
+`,
+			Expected: MiscountError{Line: 5, Parent: 1, ReportedOld: 3, ReportedNew: 3, ActualOld: 2, ActualNew: 2},
+		},
+		"combinedParentMiscount": {
+			Input: `diff --cc file.txt
+index 1c23fcc,3b8c5e1..40a1b33
+--- a/file.txt
++++ b/file.txt
+@@@ -1,2 -1,3 +1,2 @@@
+  line 1
+- line 2
+ -line 2 theirs
+++line 2 merged
+`,
+			Expected: MiscountError{Line: 5, Parent: 2, ReportedOld: 3, ReportedNew: 2, ActualOld: 2, ActualNew: 2},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, _, err := Parse(strings.NewReader(test.Input))
+
+			var merr *MiscountError
+			if !errors.As(err, &merr) {
+				t.Fatalf("expected *MiscountError, but got %T: %v", err, err)
+			}
+			if *merr != test.Expected {
+				t.Errorf("incorrect error\nexpected: %+v\n  actual: %+v", test.Expected, *merr)
+			}
+			if !strings.Contains(err.Error(), "fragment header miscounts lines") {
+				t.Errorf("incorrect error message: %s", err.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package gitdiff
+
+import (
+	"strings"
+)
+
+// Trailer is a key-value pair from the trailer block at the end of a commit
+// message, like "Signed-off-by: Morton Haypenny <mhaypenny@example.com>".
+type Trailer struct {
+	Key   string
+	Value string
+}
+
+func (t Trailer) String() string {
+	return t.Key + ": " + t.Value
+}
+
+// Trailers parses the trailers in the last paragraph of the body. It returns
+// nil if the body does not end with a trailer block. The body is not modified.
+//
+// Like `git interpret-trailers`, a paragraph is a trailer block if all of its
+// lines are trailers, or if at least 25% of its lines are trailers and at
+// least one trailer was generated by Git, like "Signed-off-by". Lines that
+// start with whitespace continue the value of the previous trailer and are
+// joined to it with a single space. Lines in the block that are not trailers
+// are ignored.
+func (h *PatchHeader) Trailers() []Trailer {
+	if h == nil {
+		return nil
+	}
+	return parseTrailers(h.Body)
+}
+
+func parseTrailers(body string) []Trailer {
+	body = strings.TrimRight(body, " \t\r\n")
+	if body == "" {
+		return nil
+	}
+
+	block := body
+	if idx := lastParagraphStart(body); idx >= 0 {
+		block = body[idx:]
+	}
+
+	var trailers []Trailer
+	var lines, matched int
+	var hasGitTrailer, inTrailer bool
+
+	for _, line := range strings.Split(block, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+
+		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') {
+			if inTrailer {
+				last := &trailers[len(trailers)-1]
+				last.Value = strings.TrimSpace(last.Value + " " + strings.TrimSpace(line))
+			}
+			continue
+		}
+
+		lines++
+		if isGitGeneratedTrailer(line) {
+			hasGitTrailer = true
+		}
+
+		key, value, ok := parseTrailerLine(line)
+		if !ok {
+			inTrailer = false
+			continue
+		}
+
+		trailers = append(trailers, Trailer{Key: key, Value: value})
+		matched++
+		inTrailer = true
+	}
+
+	if matched == 0 {
+		return nil
+	}
+	if matched < lines && !(hasGitTrailer && 4*matched >= lines) {
+		return nil
+	}
+	return trailers
+}
+
+// lastParagraphStart returns the index of the first character of the last
+// paragraph in s, or -1 if s contains a single paragraph.
+func lastParagraphStart(s string) int {
+	lines := strings.SplitAfter(s, "\n")
+
+	end := len(s)
+	for i := len(lines) - 1; i >= 0; i-- {
+		end -= len(lines[i])
+		if strings.TrimSpace(lines[i]) == "" {
+			return end + len(lines[i])
+		}
+	}
+	return -1
+}
+
+// parseTrailerLine parses a line of the form "Key: Value". Keys may only
+// contain alphanumeric characters and hyphens, but may be followed by
+// whitespace before the separator.
+func parseTrailerLine(line string) (key, value string, ok bool) {
+	idx := strings.IndexByte(line, ':')
+	if idx <= 0 {
+		return "", "", false
+	}
+
+	key = strings.TrimRight(line[:idx], " \t")
+	if key == "" {
+		return "", "", false
+	}
+	for _, c := range key {
+		if !isTrailerKeyChar(c) {
+			return "", "", false
+		}
+	}
+
+	return key, strings.TrimSpace(line[idx+1:]), true
+}
+
+func isTrailerKeyChar(c rune) bool {
+	return c == '-' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
+
+func isGitGeneratedTrailer(line string) bool {
+	return strings.HasPrefix(line, "Signed-off-by: ") || strings.HasPrefix(line, "(cherry picked from commit ")
+}
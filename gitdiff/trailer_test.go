@@ -0,0 +1,104 @@
+package gitdiff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPatchHeaderTrailers(t *testing.T) {
+	tests := map[string]struct {
+		Body     string
+		Trailers []Trailer
+	}{
+		"noBody": {
+			Body: "",
+		},
+		"noTrailers": {
+			Body: "The body of the commit message.\n\nAnother paragraph.",
+		},
+		"onlyTrailers": {
+			Body: "Signed-off-by: Morton Haypenny <mhaypenny@example.com>",
+			Trailers: []Trailer{
+				{Key: "Signed-off-by", Value: "Morton Haypenny <mhaypenny@example.com>"},
+			},
+		},
+		"multipleTrailers": {
+			Body: `The body of the commit message.
+
+Co-authored-by: Joe Smith <joe.smith@example.com>
+Co-authored-by: Jane Doe <jane.doe@example.com>
+Signed-off-by: Morton Haypenny <mhaypenny@example.com>`,
+			Trailers: []Trailer{
+				{Key: "Co-authored-by", Value: "Joe Smith <joe.smith@example.com>"},
+				{Key: "Co-authored-by", Value: "Jane Doe <jane.doe@example.com>"},
+				{Key: "Signed-off-by", Value: "Morton Haypenny <mhaypenny@example.com>"},
+			},
+		},
+		"lastParagraphOnly": {
+			Body: `Reviewed-by: Joe Smith <joe.smith@example.com>
+
+Fixes: 1234`,
+			Trailers: []Trailer{
+				{Key: "Fixes", Value: "1234"},
+			},
+		},
+		"foldedValue": {
+			Body: `The body of the commit message.
+
+Note: this value is long
+  and continues on the next line
+Acked-by: Joe Smith <joe.smith@example.com>`,
+			Trailers: []Trailer{
+				{Key: "Note", Value: "this value is long and continues on the next line"},
+				{Key: "Acked-by", Value: "Joe Smith <joe.smith@example.com>"},
+			},
+		},
+		"spaceBeforeSeparator": {
+			Body: "Bug : 42",
+			Trailers: []Trailer{
+				{Key: "Bug", Value: "42"},
+			},
+		},
+		"mixedWithoutGitTrailer": {
+			Body: `The body of the commit message.
+
+This paragraph has text.
+Reviewed-by: Joe Smith <joe.smith@example.com>`,
+		},
+		"mixedWithGitTrailer": {
+			Body: `The body of the commit message.
+
+[mhaypenny: resolved conflicts]
+Signed-off-by: Morton Haypenny <mhaypenny@example.com>`,
+			Trailers: []Trailer{
+				{Key: "Signed-off-by", Value: "Morton Haypenny <mhaypenny@example.com>"},
+			},
+		},
+		"tooFewTrailers": {
+			Body: `The body of the commit message.
+
+This paragraph has text.
+It spans multiple lines
+that do not look like
+trailers at all.
+Signed-off-by: Morton Haypenny <mhaypenny@example.com>`,
+		},
+		"invalidKey": {
+			Body: "Not a trailer: because the key has spaces",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			h := &PatchHeader{Body: test.Body}
+
+			trailers := h.Trailers()
+			if !reflect.DeepEqual(test.Trailers, trailers) {
+				t.Errorf("incorrect trailers\nexpected: %+v\n  actual: %+v", test.Trailers, trailers)
+			}
+			if h.Body != test.Body {
+				t.Errorf("body was modified: %q", h.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package gitdiff
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// LineChange is a deleted line and an added line that replaces it, with the
+// parts of each line that changed. OldIndex and NewIndex are the indices of
+// the lines in the Lines slice of the fragment.
+type LineChange struct {
+	OldIndex int
+	NewIndex int
+
+	// OldSpans are the parts of the deleted line that are not in the added
+	// line and NewSpans are the parts of the added line that are not in the
+	// deleted line.
+	OldSpans []Span
+	NewSpans []Span
+}
+
+// Span is a range of bytes in a line. Start is inclusive and End is
+// exclusive.
+type Span struct {
+	Start int
+	End   int
+}
+
+// WordDiff finds the changes within modified lines in the fragment. It pairs
+// each run of deleted lines with the run of added lines that immediately
+// follows it, matching the first deleted line with the first added line and
+// so on, and compares the words in each pair. Words are runs of letters and
+// digits, runs of whitespace, and single punctuation characters. Lines that
+// are not part of a pair are not included in the result. Trailing newlines
+// are never part of a span.
+func (f *TextFragment) WordDiff() []LineChange {
+	var changes []LineChange
+
+	for i := 0; i < len(f.Lines); {
+		if f.Lines[i].Op != OpDelete {
+			i++
+			continue
+		}
+
+		delStart := i
+		for i < len(f.Lines) && f.Lines[i].Op == OpDelete {
+			i++
+		}
+		addStart := i
+		for i < len(f.Lines) && f.Lines[i].Op == OpAdd {
+			i++
+		}
+
+		for j := 0; delStart+j < addStart && addStart+j < i; j++ {
+			oldIdx, newIdx := delStart+j, addStart+j
+			oldSpans, newSpans := wordDiffSpans(f.Lines[oldIdx].Line, f.Lines[newIdx].Line)
+			changes = append(changes, LineChange{
+				OldIndex: oldIdx,
+				NewIndex: newIdx,
+				OldSpans: oldSpans,
+				NewSpans: newSpans,
+			})
+		}
+	}
+
+	return changes
+}
+
+func wordDiffSpans(oldLine, newLine string) (oldSpans, newSpans []Span) {
+	oldWords := splitWords(strings.TrimSuffix(oldLine, "\n"))
+	newWords := splitWords(strings.TrimSuffix(newLine, "\n"))
+
+	oldChanged := make([]bool, len(oldWords))
+	newChanged := make([]bool, len(newWords))
+	for j := range newChanged {
+		newChanged[j] = true
+	}
+	for i, j := range matchLines(oldWords, newWords) {
+		if j < 0 {
+			oldChanged[i] = true
+		} else {
+			newChanged[j] = false
+		}
+	}
+
+	return wordSpans(oldWords, oldChanged), wordSpans(newWords, newChanged)
+}
+
+// wordSpans returns the spans covered by the words where changed is true,
+// merging adjacent words into a single span.
+func wordSpans(words []string, changed []bool) []Span {
+	var spans []Span
+	pos := 0
+	for i, w := range words {
+		if changed[i] {
+			if n := len(spans); n > 0 && spans[n-1].End == pos {
+				spans[n-1].End += len(w)
+			} else {
+				spans = append(spans, Span{Start: pos, End: pos + len(w)})
+			}
+		}
+		pos += len(w)
+	}
+	return spans
+}
+
+// splitWords splits s into runs of letters and digits, runs of whitespace,
+// and single other characters. Joining the result produces s.
+func splitWords(s string) []string {
+	class := func(r rune) int {
+		switch {
+		case r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+			return 1
+		case unicode.IsSpace(r):
+			return 2
+		}
+		return 0
+	}
+
+	var words []string
+	for len(s) > 0 {
+		r, size := utf8.DecodeRuneInString(s)
+		end := size
+		if c := class(r); c != 0 {
+			for end < len(s) {
+				r, size := utf8.DecodeRuneInString(s[end:])
+				if class(r) != c {
+					break
+				}
+				end += size
+			}
+		}
+		words = append(words, s[:end])
+		s = s[end:]
+	}
+	return words
+}
@@ -0,0 +1,90 @@
+package gitdiff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTextFragmentWordDiff(t *testing.T) {
+	tests := map[string]struct {
+		Lines   []Line
+		Changes []LineChange
+	}{
+		"singleWord": {
+			Lines: []Line{
+				{Op: OpContext, Line: "func main() {\n"},
+				{Op: OpDelete, Line: "\tfmt.Println(\"hello\")\n"},
+				{Op: OpAdd, Line: "\tfmt.Println(\"goodbye\")\n"},
+				{Op: OpContext, Line: "}\n"},
+			},
+			Changes: []LineChange{
+				{
+					OldIndex: 1,
+					NewIndex: 2,
+					OldSpans: []Span{{Start: 14, End: 19}},
+					NewSpans: []Span{{Start: 14, End: 21}},
+				},
+			},
+		},
+		"adjacentWords": {
+			Lines: []Line{
+				{Op: OpDelete, Line: "a := b + c\n"},
+				{Op: OpAdd, Line: "a := b - d\n"},
+			},
+			Changes: []LineChange{
+				{
+					OldIndex: 0,
+					NewIndex: 1,
+					OldSpans: []Span{{Start: 7, End: 8}, {Start: 9, End: 10}},
+					NewSpans: []Span{{Start: 7, End: 8}, {Start: 9, End: 10}},
+				},
+			},
+		},
+		"insertion": {
+			Lines: []Line{
+				{Op: OpDelete, Line: "one three\n"},
+				{Op: OpAdd, Line: "one two three"},
+			},
+			Changes: []LineChange{
+				{
+					OldIndex: 0,
+					NewIndex: 1,
+					NewSpans: []Span{{Start: 4, End: 8}},
+				},
+			},
+		},
+		"unpairedLines": {
+			Lines: []Line{
+				{Op: OpDelete, Line: "first\n"},
+				{Op: OpDelete, Line: "second\n"},
+				{Op: OpAdd, Line: "FIRST\n"},
+				{Op: OpContext, Line: "context\n"},
+				{Op: OpAdd, Line: "added\n"},
+			},
+			Changes: []LineChange{
+				{
+					OldIndex: 0,
+					NewIndex: 2,
+					OldSpans: []Span{{Start: 0, End: 5}},
+					NewSpans: []Span{{Start: 0, End: 5}},
+				},
+			},
+		},
+		"noChanges": {
+			Lines: []Line{
+				{Op: OpContext, Line: "context\n"},
+				{Op: OpAdd, Line: "added\n"},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			frag := &TextFragment{Lines: test.Lines}
+			changes := frag.WordDiff()
+			if !reflect.DeepEqual(test.Changes, changes) {
+				t.Errorf("incorrect changes\nexpected: %+v\n  actual: %+v", test.Changes, changes)
+			}
+		})
+	}
+}